@@ -32,10 +32,30 @@ func (c *CfClient) get(path string) ([]byte, error) {
 	return c.do(http.MethodGet, path, nil)
 }
 
+// getPage is get for a paginated list: the page's result and where it sits in the list.
+func (c *CfClient) getPage(path string) (json.RawMessage, cfResultInfo, error) {
+	env, err := c.send(http.MethodGet, path, nil)
+	if err != nil {
+		return nil, cfResultInfo{}, err
+	}
+	var info cfResultInfo
+	if env.ResultInfo != nil {
+		info = *env.ResultInfo
+	}
+	return env.Result, info, nil
+}
+
 func (c *CfClient) post(path string, body []byte) ([]byte, error) {
 	return c.do(http.MethodPost, path, bytes.NewReader(body))
 }
 
+// Post sends an authenticated JSON POST to the Cloudflare API and returns the envelope's
+// "result". A failure is the same parsed API error the deploy reports. It is what
+// d1.NewRemote drives.
+func (c *CfClient) Post(path string, body []byte) ([]byte, error) {
+	return c.post(path, body)
+}
+
 func (c *CfClient) put(path string, body []byte) ([]byte, error) {
 	return c.do(http.MethodPut, path, bytes.NewReader(body))
 }
@@ -66,6 +86,14 @@ func (c *CfClient) doMultipart(method, path string, body io.Reader, contentType
 }
 
 func (c *CfClient) do(method, path string, body io.Reader) ([]byte, error) {
+	env, err := c.send(method, path, body)
+	if err != nil {
+		return nil, err
+	}
+	return env.Result, nil
+}
+
+func (c *CfClient) send(method, path string, body io.Reader) (*cfEnvelope, error) {
 	req, err := http.NewRequest(method, c.BaseURL+path, body)
 	if err != nil {
 		return nil, err
@@ -81,7 +109,7 @@ func (c *CfClient) do(method, path string, body io.Reader) ([]byte, error) {
 	}
 	defer resp.Body.Close()
 
-	return parseCFResponse(method, path, resp)
+	return parseCFEnvelope(method, path, resp)
 }
 
 // DeployPages uploads the Pages build output (from config.OutputDir) to Cloudflare Pages.
@@ -378,6 +406,14 @@ func (g *Goflare) DeployWorker() error {
 		})
 	}
 
+	if g.Config.KVNamespaceID != "" {
+		bindings = append(bindings, map[string]string{
+			"type":         "kv_namespace",
+			"name":         g.Config.kvBinding(),
+			"namespace_id": g.Config.KVNamespaceID,
+		})
+	}
+
 	if len(bindings) > 0 {
 		metadata["bindings"] = bindings
 	}
@@ -413,9 +449,19 @@ func (g *Goflare) DeployWorker() error {
 // ── internal helpers ──────────────────────────────────────────────────────────
 
 type cfEnvelope struct {
-	Success bool            `json:"success"`
-	Errors  []cfAPIError    `json:"errors"`
-	Result  json.RawMessage `json:"result"`
+	Success    bool            `json:"success"`
+	Errors     []cfAPIError    `json:"errors"`
+	Result     json.RawMessage `json:"result"`
+	ResultInfo *cfResultInfo   `json:"result_info"`
+}
+
+// cfResultInfo is the pagination of a list response. Some endpoints leave out TotalPages.
+type cfResultInfo struct {
+	Page       int `json:"page"`
+	PerPage    int `json:"per_page"`
+	Count      int `json:"count"`
+	TotalCount int `json:"total_count"`
+	TotalPages int `json:"total_pages"`
 }
 
 type cfAPIError struct {
@@ -449,6 +495,15 @@ func (e *cfError) alreadyExists() bool {
 }
 
 func parseCFResponse(method, path string, resp *http.Response) (json.RawMessage, error) {
+	env, err := parseCFEnvelope(method, path, resp)
+	if err != nil {
+		return nil, err
+	}
+	return env.Result, nil
+}
+
+// parseCFEnvelope reads a response envelope, turning a failure into a *cfError.
+func parseCFEnvelope(method, path string, resp *http.Response) (*cfEnvelope, error) {
 	data, err := io.ReadAll(resp.Body)
 	if err != nil {
 		return nil, fmt.Errorf("read CF response: %w", err)
@@ -478,7 +533,7 @@ func parseCFResponse(method, path string, resp *http.Response) (json.RawMessage,
 		}
 		return nil, ce
 	}
-	return env.Result, nil
+	return &env, nil
 }
 
 func truncate(s string, n int) string {
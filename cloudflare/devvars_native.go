@@ -0,0 +1,148 @@
+//go:build !wasm
+
+package cloudflare
+
+import (
+	"os"
+	"strings"
+	"sync"
+)
+
+// DefaultDevVarsPath is the file Wrangler reads local secrets from. It holds secrets — add it
+// to .gitignore.
+const DefaultDevVarsPath = ".dev.vars"
+
+var (
+	devVarsMu     sync.Mutex
+	devVarsPath   = DefaultDevVarsPath
+	devVars       map[string]string
+	devVarsLoaded bool
+)
+
+// DevVarsPath returns the file the native Env reads under the OS environment.
+func DevVarsPath() string {
+	devVarsMu.Lock()
+	defer devVarsMu.Unlock()
+	return devVarsPath
+}
+
+// SetDevVarsPath points the native Env at another file — a fixture in a test, a per-env file
+// like .dev.vars.staging. An empty path restores the default. The file is re-read on next use.
+func SetDevVarsPath(path string) {
+	if path == "" {
+		path = DefaultDevVarsPath
+	}
+	devVarsMu.Lock()
+	devVarsPath = path
+	devVars, devVarsLoaded = nil, false
+	devVarsMu.Unlock()
+}
+
+// lookupDevVar reads key from the dev vars file, loading it once. A missing file is no vars,
+// not an error: .dev.vars is optional, as it is for Wrangler.
+func lookupDevVar(key string) (string, bool) {
+	devVarsMu.Lock()
+	defer devVarsMu.Unlock()
+	if !devVarsLoaded {
+		if raw, err := os.ReadFile(devVarsPath); err == nil {
+			devVars = parseDevVars(string(raw))
+		}
+		devVarsLoaded = true
+	}
+	v, ok := devVars[key]
+	return v, ok
+}
+
+// parseDevVars reads the dotenv format Wrangler accepts: KEY=VALUE lines, an optional
+// "export " prefix, # comments, and values that are bare, 'single-quoted' (literal) or
+// "double-quoted" (with \n, \t, \" and \\ escapes, and free to span lines).
+func parseDevVars(src string) map[string]string {
+	vars := map[string]string{}
+	src = strings.ReplaceAll(src, "\r\n", "\n")
+	for len(src) > 0 {
+		var line string
+		line, src = cutLine(src)
+		line = strings.TrimSpace(line)
+		if line == "" || line[0] == '#' {
+			continue
+		}
+		line = strings.TrimPrefix(line, "export ")
+		eq := strings.IndexByte(line, '=')
+		if eq <= 0 {
+			continue
+		}
+		key := strings.TrimSpace(line[:eq])
+		val := strings.TrimLeft(line[eq+1:], " \t")
+
+		switch {
+		case strings.HasPrefix(val, `"`):
+			// A double-quoted value may continue on the following lines until its closing quote.
+			for !closedQuote(val[1:]) && len(src) > 0 {
+				var next string
+				next, src = cutLine(src)
+				val += "\n" + next
+			}
+			vars[key] = unquoteDouble(val[1:])
+		case strings.HasPrefix(val, "'"):
+			if end := strings.IndexByte(val[1:], '\''); end >= 0 {
+				vars[key] = val[1 : end+1]
+			} else {
+				vars[key] = val[1:]
+			}
+		default:
+			if i := strings.Index(val, " #"); i >= 0 {
+				val = val[:i]
+			}
+			vars[key] = strings.TrimSpace(val)
+		}
+	}
+	return vars
+}
+
+func cutLine(s string) (line, rest string) {
+	if i := strings.IndexByte(s, '\n'); i >= 0 {
+		return s[:i], s[i+1:]
+	}
+	return s, ""
+}
+
+// closedQuote reports whether s (a double-quoted value after its opening quote) contains an
+// unescaped closing quote.
+func closedQuote(s string) bool {
+	for i := 0; i < len(s); i++ {
+		switch s[i] {
+		case '\\':
+			i++
+		case '"':
+			return true
+		}
+	}
+	return false
+}
+
+// unquoteDouble decodes a double-quoted value up to its closing quote; anything after it (a
+// trailing comment) is dropped.
+func unquoteDouble(s string) string {
+	var b strings.Builder
+	for i := 0; i < len(s); i++ {
+		c := s[i]
+		if c == '"' {
+			break
+		}
+		if c == '\\' && i+1 < len(s) {
+			i++
+			switch s[i] {
+			case 'n':
+				c = '\n'
+			case 't':
+				c = '\t'
+			case 'r':
+				c = '\r'
+			default:
+				c = s[i]
+			}
+		}
+		b.WriteByte(c)
+	}
+	return b.String()
+}
@@ -0,0 +1,22 @@
+package cloudflare
+
+import (
+	. "github.com/tinywasm/fmt"
+	"github.com/tinywasm/json"
+	"github.com/tinywasm/model"
+)
+
+// EnvJSON decodes a JSON var into into. At the edge a var declared as a table in
+// wrangler.toml arrives as an object and one declared as a string arrives as text; natively
+// (.dev.vars or the OS environment) it is always text. EnvJSON accepts all three, so the
+// same config struct loads in both builds.
+func EnvJSON(key string, into model.Decodable) error {
+	raw, ok := lookupJSON(key)
+	if !ok {
+		return Errf("cloudflare: var %s is not set", key)
+	}
+	if err := json.Decode([]byte(raw), into); err != nil {
+		return Errf("cloudflare: var %s: %s", key, err.Error())
+	}
+	return nil
+}
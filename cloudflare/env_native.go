@@ -8,7 +8,8 @@ import (
 
 // Env returns the value of an environment variable or secret.
 func Env(key string) string {
-	return os.Getenv(key)
+	val, _ := Lookup(key)
+	return val
 }
 
 // EnvOr returns the value of an environment variable or secret, or a fallback.
@@ -20,6 +21,17 @@ func EnvOr(key, fallback string) string {
 }
 
 // Lookup returns the value of an environment variable or secret and a boolean indicating if it was found.
+// The OS environment wins; the dev vars file (see SetDevVarsPath) fills in what it does not
+// set — so a secret in .dev.vars reaches the code the same way it does under Wrangler, and an
+// exported variable still overrides it for one run.
 func Lookup(key string) (string, bool) {
-	return os.LookupEnv(key)
+	if val, ok := os.LookupEnv(key); ok {
+		return val, true
+	}
+	return lookupDevVar(key)
+}
+
+// lookupJSON returns the raw JSON text of a var. Natively every var is text, so it is the value.
+func lookupJSON(key string) (string, bool) {
+	return Lookup(key)
 }
@@ -37,3 +37,20 @@ func Lookup(key string) (string, bool) {
 
 	return val.String(), true
 }
+
+// lookupJSON returns the raw JSON text of a var: a string var as is, an object var (a table
+// in wrangler.toml) serialized back with JSON.stringify.
+func lookupJSON(key string) (string, bool) {
+	jsEnv := js.Global().Get("context").Get("env")
+	if jsEnv.IsNull() || jsEnv.IsUndefined() {
+		return "", false
+	}
+	val := jsEnv.Get(key)
+	if val.IsNull() || val.IsUndefined() {
+		return "", false
+	}
+	if val.Type() == js.TypeString {
+		return val.String(), true
+	}
+	return js.Global().Get("JSON").Call("stringify", val).String(), true
+}
@@ -0,0 +1,62 @@
+//go:build !wasm
+
+package cloudflare
+
+import "sync"
+
+// DefaultStateDir is where the native stand-ins for the edge bindings keep their data: one
+// directory per R2 bucket, one SQLite file per D1 database. It is local state, never
+// committed — add it to .gitignore.
+const DefaultStateDir = ".goflare/state"
+
+// DefaultD1Binding is the name a deploy binds the D1 database under when D1_DATABASE_NAME
+// does not choose one.
+const DefaultD1Binding = "DB"
+
+var (
+	stateMu   sync.RWMutex
+	stateDir  = DefaultStateDir
+	d1Binding string
+)
+
+// StateDir returns the directory the local bindings resolve under. At the edge a binding is
+// whatever Cloudflare injected under that name; natively it is this directory plus the name.
+func StateDir() string {
+	stateMu.RLock()
+	defer stateMu.RUnlock()
+	return stateDir
+}
+
+// SetStateDir points the local bindings somewhere else — a t.TempDir() in a test, a shared
+// directory in a team setup. An empty dir restores the default.
+func SetStateDir(dir string) {
+	if dir == "" {
+		dir = DefaultStateDir
+	}
+	stateMu.Lock()
+	stateDir = dir
+	stateMu.Unlock()
+}
+
+// D1Binding is the one D1 binding the local stand-in answers to: the name the deploy
+// declares — SetD1Binding's, else D1_DATABASE_NAME, else DefaultD1Binding. At the edge a
+// misspelt binding is simply absent; natively it must be too, not a new, empty database.
+func D1Binding() string {
+	stateMu.RLock()
+	name := d1Binding
+	stateMu.RUnlock()
+	if name != "" {
+		return name
+	}
+	if name = Env("D1_DATABASE_NAME"); name != "" {
+		return name
+	}
+	return DefaultD1Binding
+}
+
+// SetD1Binding sets the name D1Binding reports. An empty name restores the default.
+func SetD1Binding(name string) {
+	stateMu.Lock()
+	d1Binding = name
+	stateMu.Unlock()
+}
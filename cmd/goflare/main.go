@@ -40,12 +40,64 @@ func main() {
 	case "deploy":
 		fs := flag.NewFlagSet("deploy", flag.ExitOnError)
 		env := fs.String("env", ".env", "path to .env file")
+		provision := fs.Bool("provision", false, "create the configured D1, R2 and KV resources that do not exist")
 		fs.Parse(args)
+		if *provision {
+			os.Setenv(goflare.EnvKeyProvision, "true")
+		}
 		if err := goflare.RunDeploy(*env, os.Stdout); err != nil {
 			fmt.Fprintln(os.Stderr, "Error:", err)
 			os.Exit(1)
 		}
 
+	case "dev":
+		if err := goflare.RunDev(os.Stdout); err != nil {
+			fmt.Fprintln(os.Stderr, "Error:", err)
+			os.Exit(1)
+		}
+
+	case "d1":
+		if len(args) >= 1 && args[0] == "drift" {
+			if err := goflare.RunDriftEntry(args[1:], os.Stdout); err != nil {
+				fmt.Fprintln(os.Stderr, "Error:", err)
+				os.Exit(1)
+			}
+			return
+		}
+		if len(args) >= 1 && (args[0] == "export" || args[0] == "import") {
+			fs := flag.NewFlagSet("d1 "+args[0], flag.ExitOnError)
+			env := fs.String("env", ".env", "path to .env file")
+			remote := fs.Bool("remote", false, "use the deployed database")
+			path := fs.String("o", "", "dump file (export: default a timestamped file in D1_BACKUP_DIR)")
+			fs.Parse(args[1:])
+			run := goflare.RunExport
+			if args[0] == "import" {
+				run = goflare.RunImport
+				if *path == "" {
+					*path = fs.Arg(0)
+				}
+			}
+			if err := run(*env, os.Stdout, *remote, *path); err != nil {
+				fmt.Fprintln(os.Stderr, "Error:", err)
+				os.Exit(1)
+			}
+			return
+		}
+		if len(args) < 2 || args[0] != "migrate" {
+			fmt.Println(goflare.Usage())
+			os.Exit(1)
+		}
+		action := args[1]
+		fs := flag.NewFlagSet("d1 migrate", flag.ExitOnError)
+		env := fs.String("env", ".env", "path to .env file")
+		remote := fs.Bool("remote", false, "run against the deployed database")
+		backup := fs.Bool("backup", false, "export the database to D1_BACKUP_DIR before migrating")
+		fs.Parse(args[2:])
+		if err := goflare.RunMigrate(*env, os.Stdout, action, *remote, *backup, fs.Arg(0)); err != nil {
+			fmt.Fprintln(os.Stderr, "Error:", err)
+			os.Exit(1)
+		}
+
 	case "help", "-h", "--help":
 		fmt.Println(goflare.Usage())
 
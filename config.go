@@ -11,15 +11,22 @@ import (
 )
 
 const (
-	EnvKeyProjectName    = "PROJECT_NAME"
-	EnvKeyAccountID      = "CLOUDFLARE_ACCOUNT_ID"
-	EnvKeyWorkerName     = "WORKER_NAME"
-	EnvKeyDomain         = "DOMAIN"
-	EnvKeyCompilerMode   = "COMPILER_MODE"
-	EnvKeyD1DatabaseID   = "D1_DATABASE_ID"
-	EnvKeyD1DatabaseName = "D1_DATABASE_NAME"
-	EnvKeyR2BucketID     = "R2_BUCKET_ID"
-	EnvKeyR2BucketName   = "R2_BUCKET_NAME"
+	EnvKeyProjectName         = "PROJECT_NAME"
+	EnvKeyAccountID           = "CLOUDFLARE_ACCOUNT_ID"
+	EnvKeyWorkerName          = "WORKER_NAME"
+	EnvKeyDomain              = "DOMAIN"
+	EnvKeyCompilerMode        = "COMPILER_MODE"
+	EnvKeyD1DatabaseID        = "D1_DATABASE_ID"
+	EnvKeyD1DatabaseName      = "D1_DATABASE_NAME"
+	EnvKeyR2BucketID          = "R2_BUCKET_ID"
+	EnvKeyR2BucketName        = "R2_BUCKET_NAME"
+	EnvKeyKVNamespaceID       = "KV_NAMESPACE_ID"
+	EnvKeyKVNamespaceName     = "KV_NAMESPACE_NAME"
+	EnvKeyProvision           = "PROVISION"
+	EnvKeyMigrationsDir       = "D1_MIGRATIONS_DIR"
+	EnvKeyRequireMigrated     = "D1_REQUIRE_MIGRATED"
+	EnvKeyBackupDir           = "D1_BACKUP_DIR"
+	EnvKeyBackupBeforeMigrate = "D1_BACKUP_BEFORE_MIGRATE"
 )
 
 // LoadConfigFromEnv reads a .env file and populates Config.
@@ -71,6 +78,20 @@ func LoadConfigFromEnv(path string) (*Config, error) {
 					cfg.R2BucketID = value
 				case EnvKeyR2BucketName:
 					cfg.R2BucketName = value
+				case EnvKeyKVNamespaceID:
+					cfg.KVNamespaceID = value
+				case EnvKeyKVNamespaceName:
+					cfg.KVNamespaceName = value
+				case EnvKeyProvision:
+					cfg.Provision = value == "true"
+				case EnvKeyMigrationsDir:
+					cfg.MigrationsDir = value
+				case EnvKeyRequireMigrated:
+					cfg.RequireMigrated = value == "true"
+				case EnvKeyBackupDir:
+					cfg.BackupDir = value
+				case EnvKeyBackupBeforeMigrate:
+					cfg.BackupBeforeMigrate = value == "true"
 				}
 			}
 			if err := scanner.Err(); err != nil {
@@ -107,6 +128,27 @@ func LoadConfigFromEnv(path string) (*Config, error) {
 	if cfg.R2BucketName == "" {
 		cfg.R2BucketName = os.Getenv(EnvKeyR2BucketName)
 	}
+	if cfg.KVNamespaceID == "" {
+		cfg.KVNamespaceID = os.Getenv(EnvKeyKVNamespaceID)
+	}
+	if cfg.KVNamespaceName == "" {
+		cfg.KVNamespaceName = os.Getenv(EnvKeyKVNamespaceName)
+	}
+	if !cfg.Provision {
+		cfg.Provision = os.Getenv(EnvKeyProvision) == "true"
+	}
+	if cfg.MigrationsDir == "" {
+		cfg.MigrationsDir = os.Getenv(EnvKeyMigrationsDir)
+	}
+	if !cfg.RequireMigrated {
+		cfg.RequireMigrated = os.Getenv(EnvKeyRequireMigrated) == "true"
+	}
+	if cfg.BackupDir == "" {
+		cfg.BackupDir = os.Getenv(EnvKeyBackupDir)
+	}
+	if !cfg.BackupBeforeMigrate {
+		cfg.BackupBeforeMigrate = os.Getenv(EnvKeyBackupBeforeMigrate) == "true"
+	}
 
 	cfg.applyDefaults()
 	return cfg, nil
@@ -148,6 +190,12 @@ func (c *Config) applyDefaults() {
 	if c.CompilerMode == "" {
 		c.CompilerMode = "S"
 	}
+	if c.MigrationsDir == "" {
+		c.MigrationsDir = "migrations"
+	}
+	if c.BackupDir == "" {
+		c.BackupDir = filepath.Join(".goflare", "backups")
+	}
 
 	// Auto-detect edge function entry (convention).
 	if c.Entry == "" {
@@ -36,26 +36,31 @@ func (a *adapter) CompileDDL(s ddl.Stmt, m model.Model) (string, []any, error) {
 	return sqlt.NewCompiler().CompileDDL(s, m)
 }
 
-// DDLCompiler returns the ddl.Compiler half of the connection, for callers wiring up
-// ddl.New(conn, d1.DDLCompiler(conn)) — mirrors sqlite.DDLCompiler.
-func DDLCompiler(conn storage.Conn) (ddl.Compiler, bool) {
-	c, ok := conn.(ddl.Compiler)
-	return c, ok
+func (a *adapter) Exec(query string, args ...any) error {
+	_, err := a.run(query, args)
+	return err
 }
 
-func (a *adapter) Exec(query string, args ...any) error {
+func (a *adapter) run(query string, args []any) (Result, error) {
 	stmt := a.dbObj.Call("prepare", query)
-	_, err := await.Promise(bindArgs(stmt, args).Call("run"))
-	return err
+	start := now()
+	v, err := await.Promise(bindArgs(stmt, args).Call("run"))
+	observe(query, now()-start)
+	if err != nil {
+		return Result{}, classify(err)
+	}
+	return resultOf(v), nil
 }
 
 func (a *adapter) QueryRow(query string, args ...any) storage.Scanner {
 	stmt := a.dbObj.Call("prepare", query)
 	opts := js.Global().Get("Object").New()
 	opts.Set("columnNames", true)
+	start := now()
 	arr, err := await.Promise(bindArgs(stmt, args).Call("raw", opts))
+	observe(query, now()-start)
 	if err != nil {
-		return &errScanner{err}
+		return &errScanner{classify(err)}
 	}
 	if arr.Length() < 2 {
 		return &errScanner{orm.ErrNotFound}
@@ -67,9 +72,11 @@ func (a *adapter) Query(query string, args ...any) (storage.Rows, error) {
 	stmt := a.dbObj.Call("prepare", query)
 	opts := js.Global().Get("Object").New()
 	opts.Set("columnNames", true)
+	start := now()
 	arr, err := await.Promise(bindArgs(stmt, args).Call("raw", opts))
+	observe(query, now()-start)
 	if err != nil {
-		return nil, err
+		return nil, classify(err)
 	}
 	if arr.Length() == 0 {
 		return &d1Rows{}, nil
@@ -84,6 +91,53 @@ func (a *adapter) Query(query string, args ...any) (storage.Rows, error) {
 
 func (a *adapter) Close() error { return nil }
 
+// batch sends every statement in one db.batch() call, which D1 runs as a single transaction:
+// if any statement fails, none is applied.
+func (a *adapter) batch(stmts []Stmt) ([]Result, error) {
+	arr := js.Global().Get("Array").New(len(stmts))
+	for i, s := range stmts {
+		arr.SetIndex(i, bindArgs(a.dbObj.Call("prepare", s.Query), s.Args))
+	}
+	start := now()
+	out, err := await.Promise(a.dbObj.Call("batch", arr))
+	observe(batchLabel(stmts), now()-start)
+	if err != nil {
+		return nil, classify(err)
+	}
+	results := make([]Result, out.Length())
+	for i := range results {
+		results[i] = resultOf(out.Index(i))
+	}
+	return results, nil
+}
+
+// resultOf reads the meta D1 reports for one statement.
+func resultOf(v js.Value) Result {
+	meta := v.Get("meta")
+	if meta.IsUndefined() || meta.IsNull() {
+		return Result{}
+	}
+	return Result{
+		Changes:     int64(jsNumber(meta.Get("changes"))),
+		LastRowID:   int64(jsNumber(meta.Get("last_row_id"))),
+		RowsWritten: int64(jsNumber(meta.Get("rows_written"))),
+		DurationMs:  jsNumber(meta.Get("duration")),
+	}
+}
+
+// now is the Worker clock in milliseconds. It only advances across I/O — which is exactly
+// what a query is, so the difference around an await is the time the handler waited.
+func now() int64 {
+	return int64(js.Global().Get("Date").Call("now").Float())
+}
+
+func jsNumber(v js.Value) float64 {
+	if v.Type() != js.TypeNumber {
+		return 0
+	}
+	return v.Float()
+}
+
 func bindArgs(stmt js.Value, args []any) js.Value {
 	if len(args) == 0 {
 		return stmt
@@ -0,0 +1,54 @@
+package d1
+
+import (
+	. "github.com/tinywasm/fmt"
+	"github.com/tinywasm/orm"
+)
+
+// Stmt is one statement of a batch: SQL with ? placeholders and its arguments.
+type Stmt struct {
+	Query string
+	Args  []any
+}
+
+// Statement builds a Stmt, reading like the Exec call it replaces.
+func Statement(query string, args ...any) Stmt {
+	return Stmt{Query: query, Args: args}
+}
+
+// Result is what D1 reports for one statement, from Exec or from a batch.
+type Result struct {
+	Changes     int64   // rows inserted, updated or deleted
+	LastRowID   int64   // rowid of the last inserted row — the ID of an autoincrement insert
+	RowsWritten int64   // rows written including index updates; locally, equal to Changes
+	DurationMs  float64 // time spent executing, as D1 reports it; locally, measured
+}
+
+// batcher is implemented by both adapters: db.batch() at the edge, a transaction locally.
+type batcher interface {
+	batch(stmts []Stmt) ([]Result, error)
+}
+
+// Batch runs stmts as one unit: they all commit, in order, or none does. D1 has no
+// interactive transactions, so this is how a multi-statement write — an order and its line
+// items — avoids half-applying. The results are per statement, in the order given.
+//
+//	res, err := d1.Batch(db,
+//	    d1.Statement(`INSERT INTO orders (customer) VALUES (?)`, c),
+//	    d1.Statement(`INSERT INTO order_items (order_id, sku) SELECT last_insert_rowid(), ?`, sku),
+//	)
+func Batch(db *orm.DB, stmts ...Stmt) ([]Result, error) {
+	b, ok := db.RawConn().(batcher)
+	if !ok {
+		return nil, Err(errPrefix + "batch: not a d1 database")
+	}
+	if len(stmts) == 0 {
+		return nil, nil
+	}
+	return b.batch(stmts)
+}
+
+// batchLabel is how a batch is named in the slow-query log: its size and first statement.
+func batchLabel(stmts []Stmt) string {
+	return "batch of " + Convert(len(stmts)).String() + ": " + stmts[0].Query
+}
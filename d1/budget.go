@@ -0,0 +1,11 @@
+//go:build wasm
+
+package d1
+
+// slowBudget is the slow-query budget in milliseconds. A Worker isolate runs one goroutine,
+// so a plain variable is enough here; natively it is atomic (budget_native.go).
+var slowBudget int64 = DefaultSlowQueryBudget
+
+func setSlowBudget(ms int64) { slowBudget = ms }
+
+func loadSlowBudget() int64 { return slowBudget }
@@ -0,0 +1,15 @@
+//go:build !wasm
+
+package d1
+
+import "sync/atomic"
+
+// slowBudget is the slow-query budget in milliseconds. A native server handles requests on
+// many goroutines while the budget may still be changed, so it is read and written atomically.
+var slowBudget atomic.Int64
+
+func init() { slowBudget.Store(DefaultSlowQueryBudget) }
+
+func setSlowBudget(ms int64) { slowBudget.Store(ms) }
+
+func loadSlowBudget() int64 { return slowBudget.Load() }
@@ -0,0 +1,24 @@
+// Package d1 connects a Cloudflare D1 database to tinywasm/orm.
+//
+// At the edge (wasm) NewEdge reads the binding from the Worker env. On the host (!wasm) the
+// same NewEdge opens a local SQLite file named after the binding, and NewLocal opens any
+// path: D1 is SQLite, and orm compiles through sqlt in both, so models and migrations run
+// identically in local dev, in unit tests and at the edge.
+package d1
+
+import (
+	"github.com/tinywasm/ddl"
+	"github.com/tinywasm/storage"
+)
+
+// DDLCompiler returns the ddl.Compiler half of the connection, for callers wiring up
+// ddl.New(conn, d1.DDLCompiler(conn)) — mirrors sqlite.DDLCompiler.
+func DDLCompiler(conn storage.Conn) (ddl.Compiler, bool) {
+	c, ok := conn.(ddl.Compiler)
+	return c, ok
+}
+
+// errScanner is the Scanner QueryRow returns when the query itself failed.
+type errScanner struct{ err error }
+
+func (e *errScanner) Scan(...any) error { return e.err }
@@ -0,0 +1,238 @@
+//go:build !wasm
+
+package d1
+
+import (
+	"sort"
+	"strings"
+
+	. "github.com/tinywasm/fmt"
+	"github.com/tinywasm/model"
+	"github.com/tinywasm/orm"
+)
+
+// Column is one column as SQLite describes it (pragma table_info).
+type Column struct {
+	Name    string
+	Type    string
+	NotNull bool
+	Default string // the default's SQL text; empty when there is none
+	PK      bool
+}
+
+// Table is one table of a schema: its columns in order and the statement that creates it.
+type Table struct {
+	Name    string
+	Columns []Column
+	SQL     string
+}
+
+// DriftKind names how a database differs from the models.
+type DriftKind string
+
+const (
+	MissingTable  DriftKind = "missing table"  // a model has no table
+	MissingColumn DriftKind = "missing column" // a model field has no column — the one that breaks writes
+	ExtraColumn   DriftKind = "extra column"   // a column no model field maps to
+	ColumnChanged DriftKind = "column changed" // same name, another type, nullability or key
+)
+
+// Drift is one difference between a database and the schema its models compile to.
+type Drift struct {
+	Kind   DriftKind
+	Table  string
+	Column string
+	Want   string // what the models compile to
+	Got    string // what the database has
+	want   Column
+	table  *Table
+}
+
+func (d Drift) String() string {
+	s := string(d.Kind) + ": " + d.Table
+	if d.Column != "" {
+		s += "." + d.Column
+	}
+	if d.Want != "" || d.Got != "" {
+		s += " (models: " + d.Want + ", database: " + d.Got + ")"
+	}
+	return s
+}
+
+// Introspect reads the schema of db: every table but SQLite's, D1's and the migrations record.
+func Introspect(db *orm.DB) ([]Table, error) {
+	conn := db.RawConn()
+	rows, err := conn.Query(`SELECT name, sql FROM sqlite_master WHERE type = 'table'
+		AND name NOT LIKE 'sqlite_%' AND name NOT LIKE '_cf_%' AND name != '` + MigrationsTable + `' ORDER BY name`)
+	if err != nil {
+		return nil, err
+	}
+	var tables []Table
+	for rows.Next() {
+		var t Table
+		var sql any
+		if err := rows.Scan(&t.Name, &sql); err != nil {
+			rows.Close()
+			return nil, err
+		}
+		t.SQL = Convert(sql).String()
+		tables = append(tables, t)
+	}
+	rows.Close()
+
+	for i := range tables {
+		cols, err := tableInfo(db, tables[i].Name)
+		if err != nil {
+			return nil, err
+		}
+		tables[i].Columns = cols
+	}
+	return tables, nil
+}
+
+func tableInfo(db *orm.DB, table string) ([]Column, error) {
+	rows, err := db.RawConn().Query(`PRAGMA table_info("` + strings.ReplaceAll(table, `"`, `""`) + `")`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var cols []Column
+	for rows.Next() {
+		var cid, notNull, pk int64
+		var name, typ string
+		var dflt any
+		if err := rows.Scan(&cid, &name, &typ, &notNull, &dflt, &pk); err != nil {
+			return nil, err
+		}
+		c := Column{Name: name, Type: strings.ToUpper(typ), NotNull: notNull != 0, PK: pk != 0}
+		if dflt != nil {
+			c.Default = Convert(dflt).String()
+		}
+		cols = append(cols, c)
+	}
+	return cols, rows.Err()
+}
+
+// ModelSchema is the schema models compile to: their tables are created, through the same
+// CompileDDL the edge uses, in a scratch in-memory database and read back. Comparing two
+// introspections — rather than DDL text against a database — makes the comparison immune to
+// spelling: "INTEGER NOT NULL" and "integer not null" are one column.
+func ModelSchema(models ...model.Model) ([]Table, error) {
+	scratch, err := NewLocal(":memory:")
+	if err != nil {
+		return nil, err
+	}
+	defer scratch.Close()
+	if err := CreateTables(models...)(scratch); err != nil {
+		return nil, err
+	}
+	return Introspect(scratch)
+}
+
+// Diff compares db with the schema models compile to. Tables the models do not mention are
+// not reported: a database may hold more than one app's tables.
+func Diff(db *orm.DB, models ...model.Model) ([]Drift, error) {
+	want, err := ModelSchema(models...)
+	if err != nil {
+		return nil, err
+	}
+	got, err := Introspect(db)
+	if err != nil {
+		return nil, err
+	}
+	have := map[string]Table{}
+	for _, t := range got {
+		have[t.Name] = t
+	}
+
+	var out []Drift
+	for i := range want {
+		w := &want[i]
+		g, ok := have[w.Name]
+		if !ok {
+			out = append(out, Drift{Kind: MissingTable, Table: w.Name, table: w})
+			continue
+		}
+		gotCols := map[string]Column{}
+		for _, c := range g.Columns {
+			gotCols[c.Name] = c
+		}
+		wantCols := map[string]bool{}
+		for _, wc := range w.Columns {
+			wantCols[wc.Name] = true
+			gc, ok := gotCols[wc.Name]
+			switch {
+			case !ok:
+				out = append(out, Drift{Kind: MissingColumn, Table: w.Name, Column: wc.Name, Want: describeColumn(wc), want: wc})
+			case gc.Type != wc.Type || gc.NotNull != wc.NotNull || gc.PK != wc.PK:
+				out = append(out, Drift{Kind: ColumnChanged, Table: w.Name, Column: wc.Name, Want: describeColumn(wc), Got: describeColumn(gc)})
+			}
+		}
+		for _, gc := range g.Columns {
+			if !wantCols[gc.Name] {
+				out = append(out, Drift{Kind: ExtraColumn, Table: w.Name, Column: gc.Name, Got: describeColumn(gc)})
+			}
+		}
+	}
+	sort.SliceStable(out, func(i, j int) bool { return out[i].Table < out[j].Table })
+	return out, nil
+}
+
+func describeColumn(c Column) string {
+	s := c.Type
+	if c.PK {
+		s += " PRIMARY KEY"
+	}
+	if c.NotNull {
+		s += " NOT NULL"
+	}
+	if c.Default != "" {
+		s += " DEFAULT " + c.Default
+	}
+	return strings.TrimSpace(s)
+}
+
+// DriftSQL is a migration that closes drift: missing tables are created and missing columns
+// added. What SQLite cannot alter in place — a changed column, a column to drop — is left
+// as a comment to resolve by hand; guessing would risk data.
+func DriftSQL(drift []Drift) string {
+	var b strings.Builder
+	for _, d := range drift {
+		switch d.Kind {
+		case MissingTable:
+			b.WriteString(strings.TrimRight(d.table.SQL, ";") + ";\n")
+		case MissingColumn:
+			b.WriteString(`ALTER TABLE "` + d.Table + `" ADD COLUMN ` + addColumn(d.want) + ";\n")
+		default:
+			b.WriteString("-- review by hand: " + d.String() + "\n")
+		}
+	}
+	return b.String()
+}
+
+// addColumn is the definition ADD COLUMN accepts: SQLite refuses NOT NULL without a default
+// on a table that may have rows, so the type's zero value is supplied.
+func addColumn(c Column) string {
+	def := `"` + c.Name + `" ` + c.Type
+	if c.NotNull {
+		dflt := c.Default
+		if dflt == "" {
+			dflt = zeroDefault(c.Type)
+		}
+		def += " NOT NULL DEFAULT " + dflt
+	} else if c.Default != "" {
+		def += " DEFAULT " + c.Default
+	}
+	return def
+}
+
+func zeroDefault(typ string) string {
+	switch {
+	case strings.Contains(typ, "INT"), strings.Contains(typ, "REAL"), strings.Contains(typ, "FLOA"),
+		strings.Contains(typ, "DOUB"), strings.Contains(typ, "NUM"), strings.Contains(typ, "BOOL"):
+		return "0"
+	case strings.Contains(typ, "BLOB"):
+		return "X''"
+	}
+	return "''"
+}
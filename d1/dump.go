@@ -0,0 +1,128 @@
+//go:build !wasm
+
+package d1
+
+import (
+	"encoding/hex"
+	"encoding/json"
+	"io"
+	"strconv"
+	"strings"
+	"time"
+
+	. "github.com/tinywasm/fmt"
+	"github.com/tinywasm/orm"
+)
+
+// Dump writes db as a SQL script that rebuilds it: every table with its rows, then its
+// indexes, triggers and views — the shape of a D1 export, so either restores with Restore
+// or with `goflare d1 import`. The migrations record is included: a restored database knows
+// which migrations it already has.
+func Dump(db *orm.DB, w io.Writer) error {
+	conn := db.RawConn()
+	rows, err := conn.Query(`SELECT type, name, sql FROM sqlite_master
+		WHERE sql IS NOT NULL AND name NOT LIKE 'sqlite_%' AND name NOT LIKE '_cf_%'
+		ORDER BY CASE type WHEN 'table' THEN 0 ELSE 1 END, name`)
+	if err != nil {
+		return err
+	}
+	type object struct{ typ, name, sql string }
+	var objects []object
+	for rows.Next() {
+		var o object
+		if err := rows.Scan(&o.typ, &o.name, &o.sql); err != nil {
+			rows.Close()
+			return err
+		}
+		objects = append(objects, o)
+	}
+	rows.Close()
+
+	if _, err := io.WriteString(w, "PRAGMA defer_foreign_keys=TRUE;\n"); err != nil {
+		return err
+	}
+	for _, o := range objects {
+		if _, err := io.WriteString(w, strings.TrimRight(o.sql, ";")+";\n"); err != nil {
+			return err
+		}
+		if o.typ == "table" {
+			if err := dumpRows(db, w, o.name); err != nil {
+				return Errf(errPrefix+"dump %s: %s", o.name, err.Error())
+			}
+		}
+	}
+	return nil
+}
+
+func dumpRows(db *orm.DB, w io.Writer, table string) error {
+	name := `"` + strings.ReplaceAll(table, `"`, `""`) + `"`
+	rows, err := db.RawConn().Query("SELECT * FROM " + name)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+	cols, err := rows.Columns()
+	if err != nil {
+		return err
+	}
+	vals := make([]any, len(cols))
+	ptrs := make([]any, len(cols))
+	for i := range vals {
+		ptrs[i] = &vals[i]
+	}
+	for rows.Next() {
+		if err := rows.Scan(ptrs...); err != nil {
+			return err
+		}
+		var b strings.Builder
+		b.WriteString("INSERT INTO " + name + " VALUES(")
+		for i, v := range vals {
+			if i > 0 {
+				b.WriteByte(',')
+			}
+			b.WriteString(sqlLiteral(v))
+		}
+		b.WriteString(");\n")
+		if _, err := io.WriteString(w, b.String()); err != nil {
+			return err
+		}
+	}
+	return rows.Err()
+}
+
+// sqlLiteral writes a scanned value back as SQL.
+func sqlLiteral(v any) string {
+	switch x := v.(type) {
+	case nil:
+		return "NULL"
+	case int64:
+		return strconv.FormatInt(x, 10)
+	case float64:
+		return strconv.FormatFloat(x, 'g', -1, 64)
+	case bool:
+		if x {
+			return "1"
+		}
+		return "0"
+	case json.Number:
+		return x.String()
+	case []byte:
+		return "X'" + hex.EncodeToString(x) + "'"
+	case string:
+		return quote(x)
+	case time.Time:
+		// The driver parses DATETIME-declared text; write it back as text.
+		return quote(x.Format("2006-01-02 15:04:05.999999999Z07:00"))
+	}
+	return quote(Convert(v).String())
+}
+
+// Restore runs a dump — from Dump or a D1 export — against db, as one unit: it applies
+// entirely or not at all. Restore into an empty database; a dump creates its tables.
+func Restore(db *orm.DB, sql string) error {
+	s, ok := db.RawConn().(scripter)
+	if !ok {
+		return Err(errPrefix + "restore needs a local or remote database")
+	}
+	return s.script(sql)
+}
@@ -1,7 +1,77 @@
 package d1
 
-import . "github.com/tinywasm/fmt"
+import (
+	. "github.com/tinywasm/fmt"
+	"github.com/tinywasm/goflare/internal/errs"
+	"github.com/tinywasm/orm"
+)
 
 const errPrefix = "d1: "
 
 var ErrDatabaseNotFound = Err(errPrefix + "database not found")
+
+// The classes a D1 failure falls into. An error returned by this package matches one of these
+// with errors.Is while still carrying D1's own message, so a handler branches on the class
+// and the log keeps the detail (the table and column, the offending token).
+var (
+	ErrUniqueViolation = Err(errPrefix + "unique constraint violated")
+	ErrForeignKey      = Err(errPrefix + "foreign key constraint violated")
+	ErrNotNull         = Err(errPrefix + "not null constraint violated")
+	ErrSyntax          = Err(errPrefix + "sql syntax error")
+	ErrBusy            = Err(errPrefix + "database busy or overloaded")
+)
+
+// errorClasses maps what D1 — and SQLite locally — says to the class it belongs to. D1
+// reports failures as text (e.g. "D1_ERROR: UNIQUE constraint failed: users.email:
+// SQLITE_CONSTRAINT"), so the text is all there is to go by. The API names a database that
+// is gone or mistyped with code 7404 ("The database <id> could not be found (code: 7404)").
+var errorClasses = []struct {
+	marker string
+	class  error
+}{
+	{"UNIQUE constraint failed", ErrUniqueViolation},
+	{"FOREIGN KEY constraint failed", ErrForeignKey},
+	{"NOT NULL constraint failed", ErrNotNull},
+	{"syntax error", ErrSyntax},
+	{"incomplete input", ErrSyntax},
+	{"SQLITE_BUSY", ErrBusy},
+	{"database is locked", ErrBusy},
+	{"overloaded", ErrBusy},
+	{"Too many requests queued", ErrBusy},
+	{"database not found", ErrDatabaseNotFound},
+	{"could not be found (code: 7404)", ErrDatabaseNotFound},
+}
+
+// classify tags err with its class, leaving it untouched when it has none.
+func classify(err error) error {
+	if err == nil {
+		return nil
+	}
+	msg := err.Error()
+	for _, c := range errorClasses {
+		if Contains(msg, c.marker) {
+			return ErrType(err, c.class)
+		}
+	}
+	return err
+}
+
+// Status is the HTTP status a handler should answer for a D1 error: 409 for a duplicate or a
+// dangling reference — the request conflicts with what is stored — 404 for orm.ErrNotFound,
+// 400 for a missing required value, 503 when D1 is busy (the client may retry), and 500 for
+// everything else, which is a bug or an outage on our side.
+func Status(err error) int {
+	switch {
+	case err == nil:
+		return 200
+	case errs.Is(err, ErrUniqueViolation), errs.Is(err, ErrForeignKey):
+		return 409
+	case errs.Is(err, orm.ErrNotFound):
+		return 404
+	case errs.Is(err, ErrNotNull):
+		return 400
+	case errs.Is(err, ErrBusy):
+		return 503
+	}
+	return 500
+}
@@ -0,0 +1,175 @@
+//go:build !wasm
+
+package d1
+
+import (
+	"database/sql"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/tinywasm/ddl"
+	. "github.com/tinywasm/fmt"
+	"github.com/tinywasm/goflare/cloudflare"
+	"github.com/tinywasm/model"
+	"github.com/tinywasm/orm"
+	"github.com/tinywasm/sqlt"
+	"github.com/tinywasm/storage"
+
+	_ "modernc.org/sqlite"
+)
+
+// localAdapter is the host twin of adapter: the same orm.Executor and DDL forwarding, over
+// database/sql and a pure-Go SQLite instead of the D1 JS API.
+type localAdapter struct {
+	db *sql.DB
+	storage.Compiler
+}
+
+// NewEdge opens the local database standing in for the named D1 binding:
+// <cloudflare.StateDir()>/d1/<binding>.sqlite, created on first use. It is the same call the
+// edge code makes, so that code runs unchanged under devserver and in native tests.
+// Returns ErrDatabaseNotFound, as the edge does, for any name but the configured binding
+// (cloudflare.D1Binding) — a typo must not get a database of its own — and for a name that
+// cannot be a binding: empty, or a path.
+func NewEdge(bindingName string) (*orm.DB, error) {
+	if bindingName == "" || strings.ContainsAny(bindingName, `/\`) || strings.HasPrefix(bindingName, ".") {
+		return nil, ErrDatabaseNotFound
+	}
+	if bindingName != cloudflare.D1Binding() {
+		return nil, ErrDatabaseNotFound
+	}
+	dir := filepath.Join(cloudflare.StateDir(), "d1")
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return nil, Errf(errPrefix+"open %s: %s", bindingName, err.Error())
+	}
+	return NewLocal(filepath.Join(dir, bindingName+".sqlite"))
+}
+
+// NewLocal opens a local SQLite database for host use (dev + tests) — no Cloudflare
+// credentials, no network. path is a file, created if missing, or ":memory:".
+//
+// The connection is configured the way D1 runs: foreign keys enforced, and a single
+// connection, so statements are serialized as they are at the edge and ":memory:" is one
+// database rather than one per pooled connection.
+func NewLocal(path string) (*orm.DB, error) {
+	dsn := "file:" + path
+	if path == ":memory:" {
+		dsn = "file::memory:"
+	}
+	db, err := sql.Open("sqlite", dsn+"?_pragma=foreign_keys(1)&_pragma=busy_timeout(5000)")
+	if err != nil {
+		return nil, Errf(errPrefix+"open %s: %s", path, err.Error())
+	}
+	db.SetMaxOpenConns(1)
+	if err := db.Ping(); err != nil {
+		db.Close()
+		return nil, Errf(errPrefix+"open %s: %s", path, err.Error())
+	}
+	return orm.New(&localAdapter{db: db, Compiler: sqlt.NewCompiler()}), nil
+}
+
+// CompileDDL forwards to the sqlt compiler, exactly as the edge adapter does.
+func (a *localAdapter) CompileDDL(s ddl.Stmt, m model.Model) (string, []any, error) {
+	return sqlt.NewCompiler().CompileDDL(s, m)
+}
+
+func (a *localAdapter) Exec(query string, args ...any) error {
+	_, err := a.run(query, args)
+	return err
+}
+
+func (a *localAdapter) run(query string, args []any) (Result, error) {
+	start := time.Now()
+	res, err := a.db.Exec(query, args...)
+	elapsed := time.Since(start)
+	observe(query, elapsed.Milliseconds())
+	if err != nil {
+		return Result{}, classify(err)
+	}
+	return localResult(res, elapsed), nil
+}
+
+func (a *localAdapter) QueryRow(query string, args ...any) storage.Scanner {
+	start := time.Now()
+	row := a.db.QueryRow(query, args...)
+	observe(query, time.Since(start).Milliseconds())
+	return &localRow{row}
+}
+
+func (a *localAdapter) Query(query string, args ...any) (storage.Rows, error) {
+	start := time.Now()
+	rows, err := a.db.Query(query, args...)
+	observe(query, time.Since(start).Milliseconds())
+	if err != nil {
+		return nil, classify(err)
+	}
+	return rows, nil
+}
+
+func (a *localAdapter) Close() error { return a.db.Close() }
+
+// batch runs the statements in one transaction, which is what D1's db.batch() is: if any
+// statement fails, the transaction is rolled back and none is applied.
+func (a *localAdapter) batch(stmts []Stmt) ([]Result, error) {
+	batchStart := time.Now()
+	defer func() { observe(batchLabel(stmts), time.Since(batchStart).Milliseconds()) }()
+
+	tx, err := a.db.Begin()
+	if err != nil {
+		return nil, err
+	}
+	results := make([]Result, len(stmts))
+	for i, s := range stmts {
+		start := time.Now()
+		res, err := tx.Exec(s.Query, s.Args...)
+		if err != nil {
+			tx.Rollback()
+			return nil, classify(Errf(errPrefix+"batch statement %d: %s", i, err.Error()))
+		}
+		results[i] = localResult(res, time.Since(start))
+	}
+	if err := tx.Commit(); err != nil {
+		return nil, classify(err)
+	}
+	return results, nil
+}
+
+// script runs a multi-statement script in one transaction.
+func (a *localAdapter) script(sql string) error {
+	tx, err := a.db.Begin()
+	if err != nil {
+		return err
+	}
+	if _, err := tx.Exec(sql); err != nil {
+		tx.Rollback()
+		return classify(err)
+	}
+	return classify(tx.Commit())
+}
+
+// localResult fills a Result from database/sql. SQLite does not count index writes, so
+// RowsWritten is the changed rows — a floor of what D1 would bill.
+func localResult(res sql.Result, elapsed time.Duration) Result {
+	r := Result{DurationMs: float64(elapsed.Microseconds()) / 1000}
+	r.Changes, _ = res.RowsAffected()
+	r.LastRowID, _ = res.LastInsertId()
+	r.RowsWritten = r.Changes
+	return r
+}
+
+// localRow reports a missing row as orm.ErrNotFound, as the edge adapter does, rather than
+// database/sql's own sentinel.
+type localRow struct{ row *sql.Row }
+
+func (r *localRow) Scan(dest ...any) error {
+	err := r.row.Scan(dest...)
+	if err == sql.ErrNoRows {
+		return orm.ErrNotFound
+	}
+	return classify(err)
+}
+
+// compile-time check
+var _ storage.Conn = (*localAdapter)(nil)
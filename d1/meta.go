@@ -0,0 +1,41 @@
+package d1
+
+import (
+	. "github.com/tinywasm/fmt"
+	"github.com/tinywasm/goflare/log"
+	"github.com/tinywasm/orm"
+)
+
+// DefaultSlowQueryBudget is how long, in milliseconds, a statement may take before it is
+// logged as slow. A D1 query answering from the same region takes a few milliseconds; one
+// that takes this long is missing an index or scanning a table.
+const DefaultSlowQueryBudget = 100
+
+// SetSlowQueryBudget sets the slow-query budget in milliseconds. Statements that take longer
+// — measured from the call to the answer, as the handler waits for it — are reported through
+// log.Slow with their SQL (never their arguments). 0 or less turns the report off. It is
+// safe to call while requests are being served.
+func SetSlowQueryBudget(ms int64) { setSlowBudget(ms) }
+
+// runner is implemented by both adapters: the statement is run and what D1 reports is kept.
+type runner interface {
+	run(query string, args []any) (Result, error)
+}
+
+// Exec runs one statement and returns what D1 reports for it — the inserted ID of an
+// autoincrement insert, the rows changed. orm's Exec has nowhere to put these, so it drops
+// them; reach for this when you need them.
+func Exec(db *orm.DB, query string, args ...any) (Result, error) {
+	r, ok := db.RawConn().(runner)
+	if !ok {
+		return Result{}, Err(errPrefix + "exec: not a d1 database")
+	}
+	return r.run(query, args)
+}
+
+// observe reports query through log.Slow when it took longer than the budget.
+func observe(query string, elapsedMs int64) {
+	if budget := loadSlowBudget(); budget > 0 && elapsedMs > budget {
+		log.Slow("d1", elapsedMs, budget, query)
+	}
+}
@@ -0,0 +1,280 @@
+//go:build !wasm
+
+package d1
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+
+	. "github.com/tinywasm/fmt"
+	"github.com/tinywasm/model"
+	"github.com/tinywasm/orm"
+)
+
+// MigrationsTable records every migration applied to a database: its version, name and
+// checksum, and when it ran.
+const MigrationsTable = "_goflare_migrations"
+
+// Migration is one ordered schema step. A SQL step is a file in the migrations directory,
+// NNNN_name.sql; a Go step sets Up instead — CreateTables(&User{}) for a model — and is
+// passed to Migrate by the program that owns the models.
+type Migration struct {
+	Version  string // "0001": the order, and the identity recorded once applied
+	Name     string // "create_users"
+	SQL      string
+	Up       func(db *orm.DB) error
+	Checksum string // of SQL; of version and name for a Go step
+}
+
+// ID is the migration as its file is named: 0001_create_users.
+func (m Migration) ID() string { return m.Version + "_" + m.Name }
+
+// MigrationState is a migration as a database sees it.
+type MigrationState struct {
+	Migration
+	Applied bool
+	Changed bool // applied with another checksum: the step was edited after it ran
+}
+
+// CreateTables is a Go step that creates the tables of models, as ddl does.
+func CreateTables(models ...model.Model) func(db *orm.DB) error {
+	return func(db *orm.DB) error {
+		for _, m := range models {
+			if err := db.CreateTable(m); err != nil {
+				return Errf(errPrefix+"create table %s: %s", m.ModelName(), err.Error())
+			}
+		}
+		return nil
+	}
+}
+
+// GoMigration builds a Go step. Its checksum covers version and name only — code cannot be
+// hashed — so changing what an applied Go step does goes unnoticed; add a new step instead.
+func GoMigration(version, name string, up func(db *orm.DB) error) Migration {
+	return Migration{Version: version, Name: name, Up: up, Checksum: checksum("go:" + version + "_" + name)}
+}
+
+// LoadMigrations reads the NNNN_name.sql files of dir, in version order. A missing dir is no
+// migrations. Two files with the same version are an error: the order would be a guess.
+func LoadMigrations(dir string) ([]Migration, error) {
+	entries, err := os.ReadDir(dir)
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, Errf(errPrefix+"migrations: %s", err.Error())
+	}
+	var out []Migration
+	for _, e := range entries {
+		if e.IsDir() || filepath.Ext(e.Name()) != ".sql" {
+			continue
+		}
+		version, name, ok := parseMigrationName(e.Name())
+		if !ok {
+			return nil, Errf(errPrefix+"migrations: %s is not named NNNN_name.sql", e.Name())
+		}
+		raw, err := os.ReadFile(filepath.Join(dir, e.Name()))
+		if err != nil {
+			return nil, Errf(errPrefix+"migrations: %s", err.Error())
+		}
+		out = append(out, Migration{Version: version, Name: name, SQL: string(raw), Checksum: checksum(string(raw))})
+	}
+	return sortMigrations(out)
+}
+
+// CreateMigration writes an empty SQL step to dir, numbered after the last one, and returns
+// its path.
+func CreateMigration(dir, name string) (string, error) {
+	name = slug(name)
+	if name == "" {
+		return "", Err(errPrefix + "migrations: a name is required")
+	}
+	existing, err := LoadMigrations(dir)
+	if err != nil {
+		return "", err
+	}
+	next := 1
+	if len(existing) > 0 {
+		last, _ := strconv.Atoi(existing[len(existing)-1].Version)
+		next = last + 1
+	}
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return "", Errf(errPrefix+"migrations: %s", err.Error())
+	}
+	path := filepath.Join(dir, Sprintf("%04d_%s.sql", next, name))
+	body := "-- " + name + "\n-- Applied once, in order, by `goflare d1 migrate up`. Never edit it after it ran.\n\n"
+	if err := os.WriteFile(path, []byte(body), 0o644); err != nil {
+		return "", Errf(errPrefix+"migrations: %s", err.Error())
+	}
+	return path, nil
+}
+
+// MigrationStatus reports each migration against db, in order.
+func MigrationStatus(db *orm.DB, migrations []Migration) ([]MigrationState, error) {
+	applied, err := appliedMigrations(db)
+	if err != nil {
+		return nil, err
+	}
+	out := make([]MigrationState, len(migrations))
+	for i, m := range migrations {
+		sum, ok := applied[m.Version]
+		out[i] = MigrationState{Migration: m, Applied: ok, Changed: ok && sum != m.Checksum}
+	}
+	return out, nil
+}
+
+// Pending returns the migrations db has not applied yet.
+func Pending(db *orm.DB, migrations []Migration) ([]Migration, error) {
+	states, err := MigrationStatus(db, migrations)
+	if err != nil {
+		return nil, err
+	}
+	var out []Migration
+	for _, s := range states {
+		if !s.Applied {
+			out = append(out, s.Migration)
+		}
+	}
+	return out, nil
+}
+
+// Migrate applies the pending migrations in order and returns those it applied. It refuses
+// to start when an applied step has changed since it ran: the database and the directory no
+// longer describe the same schema, and carrying on would build on a guess.
+//
+// A SQL step and its record are committed together — locally in one transaction, remotely in
+// one /query call — so a failing step leaves nothing behind and is retried on the next run.
+// A Go step is recorded after Up returns.
+func Migrate(db *orm.DB, migrations []Migration) ([]Migration, error) {
+	migrations, err := sortMigrations(migrations)
+	if err != nil {
+		return nil, err
+	}
+	states, err := MigrationStatus(db, migrations)
+	if err != nil {
+		return nil, err
+	}
+	for _, s := range states {
+		if s.Changed {
+			return nil, Errf(errPrefix+"migration %s was edited after it was applied (checksum differs); add a new migration instead", s.ID())
+		}
+	}
+
+	var done []Migration
+	for _, s := range states {
+		if s.Applied {
+			continue
+		}
+		if err := apply(db, s.Migration); err != nil {
+			return done, Errf(errPrefix+"migration %s: %s", s.ID(), err.Error())
+		}
+		done = append(done, s.Migration)
+	}
+	return done, nil
+}
+
+// scripter runs a multi-statement script atomically: a transaction locally, one /query call
+// remotely. The edge adapter has no such call — migrations are not run from a request.
+type scripter interface {
+	script(sql string) error
+}
+
+func apply(db *orm.DB, m Migration) error {
+	record := "INSERT INTO " + MigrationsTable + " (version, name, checksum, applied_at) VALUES (" +
+		quote(m.Version) + ", " + quote(m.Name) + ", " + quote(m.Checksum) + ", datetime('now'));"
+
+	if m.Up != nil {
+		if err := m.Up(db); err != nil {
+			return err
+		}
+		return db.RawConn().Exec(record)
+	}
+	s, ok := db.RawConn().(scripter)
+	if !ok {
+		return Err(errPrefix + "migrations need a local or remote database")
+	}
+	return s.script(strings.TrimRight(strings.TrimSpace(m.SQL), ";") + ";\n" + record)
+}
+
+func appliedMigrations(db *orm.DB) (map[string]string, error) {
+	conn := db.RawConn()
+	if err := conn.Exec("CREATE TABLE IF NOT EXISTS " + MigrationsTable + ` (
+	version    TEXT PRIMARY KEY,
+	name       TEXT NOT NULL,
+	checksum   TEXT NOT NULL,
+	applied_at TEXT NOT NULL
+)`); err != nil {
+		return nil, err
+	}
+	rows, err := conn.Query("SELECT version, checksum FROM " + MigrationsTable)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	applied := map[string]string{}
+	for rows.Next() {
+		var version, sum string
+		if err := rows.Scan(&version, &sum); err != nil {
+			return nil, err
+		}
+		applied[version] = sum
+	}
+	return applied, rows.Err()
+}
+
+func sortMigrations(ms []Migration) ([]Migration, error) {
+	sort.SliceStable(ms, func(i, j int) bool { return versionLess(ms[i].Version, ms[j].Version) })
+	for i := 1; i < len(ms); i++ {
+		if ms[i].Version == ms[i-1].Version {
+			return nil, Errf(errPrefix+"migrations %s and %s share version %s", ms[i-1].ID(), ms[i].ID(), ms[i].Version)
+		}
+	}
+	return ms, nil
+}
+
+// versionLess orders versions numerically, so 10 follows 9 however they are padded.
+func versionLess(a, b string) bool {
+	na, errA := strconv.Atoi(a)
+	nb, errB := strconv.Atoi(b)
+	if errA == nil && errB == nil && na != nb {
+		return na < nb
+	}
+	return a < b
+}
+
+func parseMigrationName(file string) (version, name string, ok bool) {
+	base := strings.TrimSuffix(file, ".sql")
+	version, name, ok = strings.Cut(base, "_")
+	if !ok || version == "" || name == "" {
+		return "", "", false
+	}
+	if _, err := strconv.Atoi(version); err != nil {
+		return "", "", false
+	}
+	return version, name, true
+}
+
+func slug(s string) string {
+	var b strings.Builder
+	for _, r := range strings.ToLower(strings.TrimSpace(s)) {
+		switch {
+		case r >= 'a' && r <= 'z', r >= '0' && r <= '9':
+			b.WriteRune(r)
+		case b.Len() > 0 && !strings.HasSuffix(b.String(), "_"):
+			b.WriteByte('_')
+		}
+	}
+	return strings.TrimSuffix(b.String(), "_")
+}
+
+func checksum(s string) string {
+	sum := sha256.Sum256([]byte(s))
+	return hex.EncodeToString(sum[:])
+}
+
+func quote(s string) string { return "'" + strings.ReplaceAll(s, "'", "''") + "'" }
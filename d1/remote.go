@@ -0,0 +1,484 @@
+//go:build !wasm
+
+package d1
+
+import (
+	"bytes"
+	"encoding/json"
+	"io"
+	"math"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/tinywasm/ddl"
+	. "github.com/tinywasm/fmt"
+	"github.com/tinywasm/model"
+	"github.com/tinywasm/orm"
+	"github.com/tinywasm/sqlt"
+	"github.com/tinywasm/storage"
+)
+
+// Poster sends an authenticated POST to the Cloudflare API and returns the "result" of the
+// response envelope, or the API's error. *goflare.CfClient is one — use Goflare.RemoteD1 to
+// get a database wired to it.
+type Poster interface {
+	Post(path string, body []byte) ([]byte, error)
+}
+
+// remoteAdapter is a third orm.Executor: the same SQL and DDL compilation, sent to a D1
+// database over the Cloudflare HTTP API instead of through a Worker binding.
+type remoteAdapter struct {
+	client Poster
+	path   string
+	storage.Compiler
+}
+
+// NewRemote opens a production (or preview) D1 database from outside Cloudflare — a CI job,
+// an admin script — through its /query HTTP endpoint. The same orm models and ddl
+// migrations the edge code uses run against it unchanged.
+//
+// Every statement is one HTTP round trip, so this is for administration, not a request path.
+// Blob arguments are refused: the HTTP API carries parameters as JSON, where bytes have no
+// faithful form. Batch sends its statements as one multi-statement query, the arguments
+// written into the SQL as literals, since the endpoint binds parameters to one statement only.
+func NewRemote(client Poster, accountID, databaseID string) (*orm.DB, error) {
+	if client == nil || accountID == "" || databaseID == "" {
+		return nil, ErrDatabaseNotFound
+	}
+	return orm.New(&remoteAdapter{
+		client:   client,
+		path:     "/accounts/" + accountID + "/d1/database/" + databaseID + "/query",
+		Compiler: sqlt.NewCompiler(),
+	}), nil
+}
+
+// CompileDDL forwards to the sqlt compiler, exactly as the edge adapter does.
+func (a *remoteAdapter) CompileDDL(s ddl.Stmt, m model.Model) (string, []any, error) {
+	return sqlt.NewCompiler().CompileDDL(s, m)
+}
+
+// remoteResult is one element of the /query result array.
+type remoteResult struct {
+	Results json.RawMessage `json:"results"`
+	Meta    struct {
+		Changes     int64   `json:"changes"`
+		LastRowID   int64   `json:"last_row_id"`
+		RowsWritten int64   `json:"rows_written"`
+		Duration    float64 `json:"duration"`
+	} `json:"meta"`
+}
+
+// query sends one statement and returns its result, classified on failure.
+func (a *remoteAdapter) query(query string, args []any) (*remoteResult, error) {
+	for _, arg := range args {
+		if _, ok := arg.([]byte); ok {
+			return nil, Err(errPrefix + "remote: blob parameters cannot be sent over the HTTP API")
+		}
+	}
+	if args == nil {
+		args = []any{}
+	}
+	results, err := a.send(query, query, args)
+	if err != nil {
+		return nil, err
+	}
+	if len(results) == 0 {
+		return &remoteResult{}, nil
+	}
+	// A multi-statement script answers one result per statement; the last one is the answer.
+	return &results[len(results)-1], nil
+}
+
+// send posts sql with its params and returns one result per statement. label is what the
+// slow-query log names it by.
+func (a *remoteAdapter) send(label, sql string, params []any) ([]remoteResult, error) {
+	body, err := json.Marshal(map[string]any{"sql": sql, "params": params})
+	if err != nil {
+		return nil, Errf(errPrefix+"remote: %s", err.Error())
+	}
+
+	start := time.Now()
+	raw, err := a.client.Post(a.path, body)
+	observe(label, time.Since(start).Milliseconds())
+	if err != nil {
+		return nil, classify(err)
+	}
+
+	var results []remoteResult
+	if err := json.Unmarshal(raw, &results); err != nil {
+		return nil, Errf(errPrefix+"remote: unexpected response: %s", err.Error())
+	}
+	return results, nil
+}
+
+// batch sends every statement in one /query call, as one script, which D1 runs as one unit.
+// The endpoint takes params for a single statement, so each statement's arguments are
+// written into its SQL as literals first.
+func (a *remoteAdapter) batch(stmts []Stmt) ([]Result, error) {
+	var script []byte
+	for i, st := range stmts {
+		sql, err := inline(st.Query, st.Args)
+		if err != nil {
+			return nil, Errf(errPrefix+"remote: batch statement %d: %s", i, err.Error())
+		}
+		if i > 0 {
+			script = append(script, ";\n"...)
+		}
+		script = append(script, sql...)
+	}
+
+	results, err := a.send(batchLabel(stmts), string(script), []any{})
+	if err != nil {
+		return nil, err
+	}
+	if len(results) != len(stmts) {
+		return nil, Errf(errPrefix+"remote: batch of %d statements answered %d results", len(stmts), len(results))
+	}
+	out := make([]Result, len(results))
+	for i, r := range results {
+		out[i] = Result{
+			Changes:     r.Meta.Changes,
+			LastRowID:   r.Meta.LastRowID,
+			RowsWritten: r.Meta.RowsWritten,
+			DurationMs:  r.Meta.Duration,
+		}
+	}
+	return out, nil
+}
+
+// inline replaces each ? placeholder of query with its argument as an SQL literal, and drops
+// a trailing semicolon so statements can be joined. A ? inside a string, a quoted identifier
+// or a comment is not a placeholder.
+func inline(query string, args []any) (string, error) {
+	q := bytes.TrimRight([]byte(query), " \t\r\n;")
+	out := make([]byte, 0, len(q))
+	n := 0
+	for i := 0; i < len(q); i++ {
+		c := q[i]
+		switch {
+		case c == '\'' || c == '"' || c == '`' || c == '[':
+			end := c
+			if c == '[' {
+				end = ']'
+			}
+			j := bytes.IndexByte(q[i+1:], end)
+			if j < 0 {
+				return "", Err("unterminated quote")
+			}
+			out = append(out, q[i:i+j+2]...)
+			i += j + 1
+		case c == '-' && i+1 < len(q) && q[i+1] == '-':
+			j := bytes.IndexByte(q[i:], '\n')
+			if j < 0 {
+				j = len(q) - i
+			}
+			out = append(out, q[i:i+j]...)
+			i += j - 1
+		case c == '/' && i+1 < len(q) && q[i+1] == '*':
+			j := bytes.Index(q[i+2:], []byte("*/"))
+			if j < 0 {
+				return "", Err("unterminated comment")
+			}
+			out = append(out, q[i:i+j+4]...)
+			i += j + 3
+		case c == '?':
+			if n >= len(args) {
+				return "", Errf("more placeholders than the %d arguments", len(args))
+			}
+			lit, err := literal(args[n])
+			if err != nil {
+				return "", err
+			}
+			out = append(out, lit...)
+			n++
+		default:
+			out = append(out, c)
+		}
+	}
+	if n != len(args) {
+		return "", Errf("%d placeholders for %d arguments", n, len(args))
+	}
+	return string(out), nil
+}
+
+// literal writes v the way SQLite reads it back as the same value. A doubled quote is the
+// only escape an SQL string has, so a string argument cannot end its literal early.
+func literal(v any) (string, error) {
+	switch x := v.(type) {
+	case nil:
+		return "NULL", nil
+	case string:
+		return "'" + strings.ReplaceAll(x, "'", "''") + "'", nil
+	case bool:
+		if x {
+			return "1", nil
+		}
+		return "0", nil
+	case int, int8, int16, int32, int64, uint, uint8, uint16, uint32, uint64:
+		return Convert(x).String(), nil
+	case float32:
+		return literal(float64(x))
+	case float64:
+		if math.IsNaN(x) || math.IsInf(x, 0) {
+			return "", Errf("%v has no SQL literal", x)
+		}
+		return strconv.FormatFloat(x, 'g', -1, 64), nil
+	case []byte:
+		return "", Err("blob parameters cannot be sent over the HTTP API")
+	}
+	return "", Errf("unsupported argument %T", v)
+}
+
+func (a *remoteAdapter) Exec(query string, args ...any) error {
+	_, err := a.run(query, args)
+	return err
+}
+
+func (a *remoteAdapter) run(query string, args []any) (Result, error) {
+	r, err := a.query(query, args)
+	if err != nil {
+		return Result{}, err
+	}
+	return Result{
+		Changes:     r.Meta.Changes,
+		LastRowID:   r.Meta.LastRowID,
+		RowsWritten: r.Meta.RowsWritten,
+		DurationMs:  r.Meta.Duration,
+	}, nil
+}
+
+func (a *remoteAdapter) QueryRow(query string, args ...any) storage.Scanner {
+	rows, err := a.Query(query, args...)
+	if err != nil {
+		return &errScanner{err}
+	}
+	if !rows.Next() {
+		return &errScanner{orm.ErrNotFound}
+	}
+	return rows
+}
+
+func (a *remoteAdapter) Query(query string, args ...any) (storage.Rows, error) {
+	r, err := a.query(query, args)
+	if err != nil {
+		return nil, err
+	}
+	cols, vals, err := decodeRows(r.Results)
+	if err != nil {
+		return nil, Errf(errPrefix+"remote: unexpected rows: %s", err.Error())
+	}
+	return &remoteRows{cols: cols, vals: vals}, nil
+}
+
+func (a *remoteAdapter) Close() error { return nil }
+
+// script sends a multi-statement script in one /query call, which D1 runs as one unit.
+func (a *remoteAdapter) script(sql string) error {
+	_, err := a.query(sql, nil)
+	return err
+}
+
+// decodeRows reads the /query rows — JSON objects, one per row — keeping the column order
+// the objects were written in. A map would lose it, and Scan is positional.
+func decodeRows(raw json.RawMessage) ([]string, [][]any, error) {
+	if len(raw) == 0 || string(raw) == "null" {
+		return nil, nil, nil
+	}
+	dec := json.NewDecoder(bytes.NewReader(raw))
+	dec.UseNumber()
+	if _, err := dec.Token(); err != nil { // [
+		return nil, nil, err
+	}
+	var cols []string
+	var rows [][]any
+	for dec.More() {
+		if _, err := dec.Token(); err != nil { // {
+			return nil, nil, err
+		}
+		var row []any
+		for i := 0; dec.More(); i++ {
+			tok, err := dec.Token()
+			if err != nil {
+				return nil, nil, err
+			}
+			name, _ := tok.(string)
+			if len(rows) == 0 {
+				cols = append(cols, name)
+			}
+			var v any
+			if err := dec.Decode(&v); err != nil {
+				return nil, nil, err
+			}
+			row = append(row, v)
+		}
+		if _, err := dec.Token(); err != nil { // }
+			return nil, nil, err
+		}
+		rows = append(rows, row)
+	}
+	if _, err := dec.Token(); err != nil && err != io.EOF { // ]
+		return nil, nil, err
+	}
+	return cols, rows, nil
+}
+
+// remoteRows is a decoded result set. It is also the Scanner QueryRow returns, positioned on
+// its first row.
+type remoteRows struct {
+	cols []string
+	vals [][]any
+	cur  int
+}
+
+func (r *remoteRows) Columns() ([]string, error) { return r.cols, nil }
+
+func (r *remoteRows) Next() bool {
+	if r.cur < len(r.vals) {
+		r.cur++
+		return true
+	}
+	return false
+}
+
+func (r *remoteRows) Scan(dest ...any) error {
+	if r.cur == 0 || r.cur > len(r.vals) {
+		return Err(errPrefix + "invalid row cursor")
+	}
+	row := r.vals[r.cur-1]
+	if len(dest) > len(row) {
+		return Err(errPrefix + "scan destination count mismatch")
+	}
+	for i, ptr := range dest {
+		if err := assign(ptr, row[i]); err != nil {
+			return Errf(errPrefix+"scan column %s: %s", r.cols[i], err.Error())
+		}
+	}
+	return nil
+}
+
+func (r *remoteRows) Close() error { return nil }
+func (r *remoteRows) Err() error   { return nil }
+
+// assign stores a JSON value (json.Number, string, bool or nil) into dest, converting the
+// way database/sql does for the types orm models use.
+func assign(dest any, v any) error {
+	if p, ok := dest.(*any); ok {
+		*p = v
+		return nil
+	}
+	var s string
+	switch x := v.(type) {
+	case nil:
+		return assignNull(dest)
+	case json.Number:
+		s = x.String()
+	case string:
+		s = x
+	case bool:
+		s = "0"
+		if x {
+			s = "1"
+		}
+	default:
+		return Errf("unsupported value %v", v)
+	}
+
+	var err error
+	switch p := dest.(type) {
+	case *string:
+		*p = s
+	case *[]byte:
+		*p = []byte(s)
+	case *bool:
+		*p, err = strconv.ParseBool(s)
+	case *int:
+		var n int64
+		n, err = strconv.ParseInt(s, 10, 0)
+		*p = int(n)
+	case *int8:
+		var n int64
+		n, err = strconv.ParseInt(s, 10, 8)
+		*p = int8(n)
+	case *int16:
+		var n int64
+		n, err = strconv.ParseInt(s, 10, 16)
+		*p = int16(n)
+	case *int32:
+		var n int64
+		n, err = strconv.ParseInt(s, 10, 32)
+		*p = int32(n)
+	case *int64:
+		*p, err = strconv.ParseInt(s, 10, 64)
+	case *uint:
+		var n uint64
+		n, err = strconv.ParseUint(s, 10, 0)
+		*p = uint(n)
+	case *uint8:
+		var n uint64
+		n, err = strconv.ParseUint(s, 10, 8)
+		*p = uint8(n)
+	case *uint16:
+		var n uint64
+		n, err = strconv.ParseUint(s, 10, 16)
+		*p = uint16(n)
+	case *uint32:
+		var n uint64
+		n, err = strconv.ParseUint(s, 10, 32)
+		*p = uint32(n)
+	case *uint64:
+		*p, err = strconv.ParseUint(s, 10, 64)
+	case *float32:
+		var f float64
+		f, err = strconv.ParseFloat(s, 32)
+		*p = float32(f)
+	case *float64:
+		*p, err = strconv.ParseFloat(s, 64)
+	default:
+		return Errf("unsupported destination %T", dest)
+	}
+	return err
+}
+
+// assignNull zeroes dest for a NULL column, as D1's JS API does.
+func assignNull(dest any) error {
+	switch p := dest.(type) {
+	case *string:
+		*p = ""
+	case *[]byte:
+		*p = nil
+	case *bool:
+		*p = false
+	case *int:
+		*p = 0
+	case *int8:
+		*p = 0
+	case *int16:
+		*p = 0
+	case *int32:
+		*p = 0
+	case *int64:
+		*p = 0
+	case *uint:
+		*p = 0
+	case *uint8:
+		*p = 0
+	case *uint16:
+		*p = 0
+	case *uint32:
+		*p = 0
+	case *uint64:
+		*p = 0
+	case *float32:
+		*p = 0
+	case *float64:
+		*p = 0
+	default:
+		return Errf("unsupported destination %T", dest)
+	}
+	return nil
+}
+
+// compile-time checks
+var _ storage.Conn = (*remoteAdapter)(nil)
+var _ batcher = (*remoteAdapter)(nil)
@@ -57,10 +57,6 @@ func (r *d1Rows) Scan(dest ...any) error {
 func (r *d1Rows) Close() error { return nil }
 func (r *d1Rows) Err() error   { return nil }
 
-type errScanner struct{ err error }
-
-func (e *errScanner) Scan(...any) error { return e.err }
-
 type rowScanner struct{ row js.Value }
 
 func (s *rowScanner) Scan(dest ...any) error {
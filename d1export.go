@@ -0,0 +1,247 @@
+//go:build !wasm
+
+package goflare
+
+import (
+	"bytes"
+	"crypto/md5"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/tinywasm/goflare/d1"
+)
+
+// d1PollAttempts bounds how long export and import wait for D1: attempts × RetryBackoff.
+const d1PollAttempts = 120
+
+// ExportD1 writes a SQL dump of the project's database to w: the deployed one through the
+// D1 export API when remote is set, otherwise the local one.
+func (g *Goflare) ExportD1(w io.Writer, remote bool) error {
+	if !remote {
+		db, err := g.OpenD1(false)
+		if err != nil {
+			return err
+		}
+		defer db.Close()
+		return d1.Dump(db, w)
+	}
+
+	client, path, err := g.d1APIClient("export")
+	if err != nil {
+		return err
+	}
+	// The export runs asynchronously: the first call starts it, later calls with its
+	// bookmark poll it, and the finished one carries a signed URL to the dump.
+	req := map[string]any{"output_format": "polling"}
+	for i := 0; i < d1PollAttempts; i++ {
+		body, _ := json.Marshal(req)
+		raw, err := client.post(path, body)
+		if err != nil {
+			return fmt.Errorf("d1 export: %w", err)
+		}
+		var res struct {
+			AtBookmark string `json:"at_bookmark"`
+			Status     string `json:"status"`
+			Error      string `json:"error"`
+			Result     struct {
+				SignedURL string `json:"signed_url"`
+			} `json:"result"`
+		}
+		if err := json.Unmarshal(raw, &res); err != nil {
+			return fmt.Errorf("d1 export: unexpected response: %w", err)
+		}
+		switch res.Status {
+		case "complete":
+			return download(client.HttpClient, res.Result.SignedURL, w)
+		case "error":
+			return fmt.Errorf("d1 export failed: %s", res.Error)
+		}
+		req["current_bookmark"] = res.AtBookmark
+		time.Sleep(g.RetryBackoff)
+	}
+	return fmt.Errorf("d1 export: still running after %d polls", d1PollAttempts)
+}
+
+// ImportD1 runs a SQL dump against the project's database: the deployed one through the D1
+// import API when remote is set, otherwise the local one.
+func (g *Goflare) ImportD1(sql []byte, remote bool) error {
+	if !remote {
+		db, err := g.OpenD1(false)
+		if err != nil {
+			return err
+		}
+		defer db.Close()
+		return d1.Restore(db, string(sql))
+	}
+
+	client, path, err := g.d1APIClient("import")
+	if err != nil {
+		return err
+	}
+	sum := md5.Sum(sql)
+	etag := hex.EncodeToString(sum[:])
+
+	// 1. init: D1 answers with where to upload the file.
+	var initRes struct {
+		UploadURL string `json:"upload_url"`
+		Filename  string `json:"filename"`
+	}
+	if err := postJSON(client, path, map[string]any{"action": "init", "etag": etag}, &initRes); err != nil {
+		return fmt.Errorf("d1 import: %w", err)
+	}
+
+	// 2. upload the dump, unauthenticated, to the presigned URL.
+	if initRes.UploadURL != "" {
+		req, err := http.NewRequest(http.MethodPut, initRes.UploadURL, bytes.NewReader(sql))
+		if err != nil {
+			return err
+		}
+		resp, err := client.HttpClient.Do(req)
+		if err != nil {
+			return fmt.Errorf("d1 import: upload: %w", err)
+		}
+		resp.Body.Close()
+		if resp.StatusCode >= 300 {
+			return fmt.Errorf("d1 import: upload: HTTP %d", resp.StatusCode)
+		}
+	}
+
+	// 3. ingest, then poll until D1 has applied it.
+	req := map[string]any{"action": "ingest", "etag": etag, "filename": initRes.Filename}
+	for i := 0; i < d1PollAttempts; i++ {
+		var res struct {
+			AtBookmark string `json:"at_bookmark"`
+			Status     string `json:"status"`
+			Error      string `json:"error"`
+		}
+		if err := postJSON(client, path, req, &res); err != nil {
+			return fmt.Errorf("d1 import: %w", err)
+		}
+		switch res.Status {
+		case "complete":
+			return nil
+		case "error":
+			return fmt.Errorf("d1 import failed: %s", res.Error)
+		}
+		req = map[string]any{"action": "poll", "current_bookmark": res.AtBookmark}
+		time.Sleep(g.RetryBackoff)
+	}
+	return fmt.Errorf("d1 import: still running after %d polls", d1PollAttempts)
+}
+
+// BackupD1 exports the project's database to a new file in BackupDir and returns its path.
+func (g *Goflare) BackupD1(remote bool) (string, error) {
+	where := "local"
+	if remote {
+		where = "remote"
+	}
+	name := fmt.Sprintf("%s-%s-%s.sql", g.Config.d1Binding(), where, time.Now().UTC().Format("20060102T150405Z"))
+	path := filepath.Join(g.Config.BackupDir, name)
+	return path, g.exportTo(path, remote)
+}
+
+// exportTo writes the dump to path, leaving no partial file behind on failure.
+func (g *Goflare) exportTo(path string, remote bool) error {
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return err
+	}
+	f, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	err = g.ExportD1(f, remote)
+	if cerr := f.Close(); err == nil {
+		err = cerr
+	}
+	if err != nil {
+		os.Remove(path)
+	}
+	return err
+}
+
+// RunExport runs `goflare d1 export`: the dump goes to path, or to a timestamped file in
+// BackupDir when path is empty.
+func RunExport(envPath string, out io.Writer, remote bool, path string) error {
+	cfg, err := LoadConfigFromEnv(envPath)
+	if err != nil {
+		return err
+	}
+	g := New(cfg)
+	if path == "" {
+		path, err = g.BackupD1(remote)
+	} else {
+		err = g.exportTo(path, remote)
+	}
+	if err != nil {
+		return err
+	}
+	fmt.Fprintln(out, "Exported", path)
+	return nil
+}
+
+// RunImport runs `goflare d1 import`: path is a dump from `d1 export` or the dashboard.
+func RunImport(envPath string, out io.Writer, remote bool, path string) error {
+	if path == "" {
+		return fmt.Errorf("d1 import: the dump file is required")
+	}
+	sql, err := os.ReadFile(path)
+	if err != nil {
+		return err
+	}
+	cfg, err := LoadConfigFromEnv(envPath)
+	if err != nil {
+		return err
+	}
+	if err := New(cfg).ImportD1(sql, remote); err != nil {
+		return err
+	}
+	fmt.Fprintln(out, "Imported", path)
+	return nil
+}
+
+func (g *Goflare) d1APIClient(op string) (*CfClient, string, error) {
+	if g.Config.D1DatabaseID == "" {
+		return nil, "", fmt.Errorf("D1_DATABASE_ID is not set in .env: %w", d1.ErrDatabaseNotFound)
+	}
+	token, err := g.token()
+	if err != nil {
+		return nil, "", err
+	}
+	client := &CfClient{Token: token, BaseURL: g.BaseURL, HttpClient: http.DefaultClient}
+	return client, fmt.Sprintf("/accounts/%s/d1/database/%s/%s", g.Config.AccountID, g.Config.D1DatabaseID, op), nil
+}
+
+func postJSON(client *CfClient, path string, req, into any) error {
+	body, _ := json.Marshal(req)
+	raw, err := client.post(path, body)
+	if err != nil {
+		return err
+	}
+	if err := json.Unmarshal(raw, into); err != nil {
+		return fmt.Errorf("unexpected response: %w", err)
+	}
+	return nil
+}
+
+// download copies a signed URL's body to w. The URL carries its own authorization.
+func download(hc *http.Client, url string, w io.Writer) error {
+	if url == "" {
+		return fmt.Errorf("d1 export: finished without a download URL")
+	}
+	resp, err := hc.Get(url)
+	if err != nil {
+		return fmt.Errorf("d1 export: download: %w", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("d1 export: download: HTTP %d", resp.StatusCode)
+	}
+	_, err = io.Copy(w, resp.Body)
+	return err
+}
@@ -0,0 +1,30 @@
+//go:build !wasm
+
+package goflare
+
+import (
+	"fmt"
+	"net/http"
+
+	"github.com/tinywasm/goflare/d1"
+	"github.com/tinywasm/orm"
+)
+
+// RemoteD1 opens the project's D1 database (D1_DATABASE_ID in .env) over the Cloudflare
+// HTTP API, with CLOUDFLARE_API_TOKEN — for CI jobs and admin scripts that run the app's orm
+// models and migrations against the deployed database.
+func (g *Goflare) RemoteD1() (*orm.DB, error) {
+	if g.Config.D1DatabaseID == "" {
+		return nil, fmt.Errorf("D1_DATABASE_ID is not set in .env: %w", d1.ErrDatabaseNotFound)
+	}
+	token, err := g.token()
+	if err != nil {
+		return nil, err
+	}
+	client := &CfClient{
+		Token:      token,
+		BaseURL:    g.BaseURL,
+		HttpClient: http.DefaultClient,
+	}
+	return d1.NewRemote(client, g.Config.AccountID, g.Config.D1DatabaseID)
+}
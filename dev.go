@@ -0,0 +1,118 @@
+//go:build !wasm
+
+package goflare
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"os/signal"
+	"path/filepath"
+	"time"
+)
+
+// DevEntry is the project's native dev server: web/server.go, built with !wasm. It registers
+// the app's routes on a devserver.Edge — the same registration the edge deploys.
+const DevEntry = "web"
+
+// devPoll is how often RunDev looks for changed Go files.
+const devPoll = 500 * time.Millisecond
+
+// devSkip are directories RunDev never watches: generated output, local state, VCS.
+var devSkip = map[string]bool{
+	".git":         true,
+	".build":       true,
+	".goflare":     true,
+	"functions":    true,
+	"node_modules": true,
+}
+
+// RunDev runs the dev command: it builds and starts DevEntry, and rebuilds and restarts it
+// whenever a .go file in the project changes. It returns on interrupt.
+func RunDev(out io.Writer) error {
+	if _, err := os.Stat(filepath.Join(DevEntry, "server.go")); err != nil {
+		return fmt.Errorf("no dev server: %s/server.go is missing (see README → Local development)", DevEntry)
+	}
+
+	bin := filepath.Join(os.TempDir(), fmt.Sprintf("goflare-dev-%d", os.Getpid()))
+	defer os.Remove(bin)
+
+	interrupt := make(chan os.Signal, 1)
+	signal.Notify(interrupt, os.Interrupt)
+	defer signal.Stop(interrupt)
+
+	var proc *exec.Cmd
+	stop := func() {
+		if proc != nil && proc.Process != nil {
+			proc.Process.Kill()
+			proc.Wait()
+		}
+		proc = nil
+	}
+	defer stop()
+
+	start := func() {
+		build := exec.Command("go", "build", "-o", bin, "./"+DevEntry)
+		build.Stdout, build.Stderr = out, out
+		if err := build.Run(); err != nil {
+			// A broken build is part of the loop, not the end of it: wait for the next save.
+			fmt.Fprintln(out, "dev: build failed — waiting for changes")
+			return
+		}
+		proc = exec.Command(bin)
+		proc.Stdout, proc.Stderr = out, out
+		if err := proc.Start(); err != nil {
+			fmt.Fprintln(out, "dev: start failed:", err)
+			proc = nil
+		}
+	}
+
+	last := goSourceStamp(".")
+	start()
+
+	tick := time.NewTicker(devPoll)
+	defer tick.Stop()
+	for {
+		select {
+		case <-interrupt:
+			return nil
+		case <-tick.C:
+			stamp := goSourceStamp(".")
+			if stamp == last {
+				continue
+			}
+			last = stamp
+			fmt.Fprintln(out, "dev: change detected — restarting")
+			stop()
+			start()
+		}
+	}
+}
+
+// goSourceStamp summarizes every .go file under root — count and modification times — so
+// that any edit, addition or removal changes it.
+func goSourceStamp(root string) string {
+	var n int
+	var sum int64
+	filepath.WalkDir(root, func(path string, d os.DirEntry, err error) error {
+		if err != nil {
+			return nil
+		}
+		if d.IsDir() {
+			if path != root && devSkip[d.Name()] {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		if filepath.Ext(path) != ".go" {
+			return nil
+		}
+		if info, err := d.Info(); err == nil {
+			n++
+			sum += info.ModTime().UnixNano() ^ int64(len(path))
+		}
+		return nil
+	})
+	return fmt.Sprintf("%d:%d", n, sum)
+}
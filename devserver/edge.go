@@ -0,0 +1,232 @@
+//go:build !wasm
+
+package devserver
+
+import (
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+
+	"github.com/tinywasm/goflare/cloudflare"
+	"github.com/tinywasm/goflare/edge"
+	"github.com/tinywasm/goflare/log"
+	"github.com/tinywasm/json"
+	"github.com/tinywasm/model"
+	"github.com/tinywasm/router"
+)
+
+// EdgeConfig configures the native edge runtime. Every field has a working default, so the
+// zero value serves web/public and the edge routes on :8080.
+type EdgeConfig struct {
+	Port      string      // default "8080"
+	PublicDir string      // default "web/public" — served first, like Pages assets
+	StateDir  string      // default cloudflare.DefaultStateDir — where local D1/R2 data lives
+	DevVars   string      // default cloudflare.DefaultDevVarsPath — local secrets, under the OS env
+	D1Binding string      // default D1_DATABASE_NAME, else "DB" — the one name d1.NewEdge opens
+	Edge      edge.Config // the SAME Authn/Authorize the app deploys with
+}
+
+// Edge runs an app's edge routes natively, behind the same pipeline the Worker runs — Authn,
+// access gate, validation — with the static site on the same port.
+//
+// It exists for the edit-run loop: the registration in routes.Register is the one the
+// deploy serves, and the D1, R2 and env bindings resolve to local stand-ins under StateDir,
+// so a backend change is one restart away instead of one deploy away.
+type Edge struct {
+	cfg    EdgeConfig
+	router router.Router
+}
+
+// NewEdge builds the native edge runtime. Register routes on Router(), then ListenAndServe.
+func NewEdge(cfg EdgeConfig) *Edge {
+	if cfg.Port == "" {
+		cfg.Port = "8080"
+	}
+	if cfg.PublicDir == "" {
+		cfg.PublicDir = filepath.Join("web", "public")
+	}
+	cloudflare.SetStateDir(cfg.StateDir)
+	cloudflare.SetDevVarsPath(cfg.DevVars)
+	cloudflare.SetD1Binding(cfg.D1Binding)
+	return &Edge{cfg: cfg, router: edge.NewRouter(cfg.Edge)}
+}
+
+// Router is where the app registers its routes — the same call it makes at the edge.
+func (e *Edge) Router() router.Router { return e.router }
+
+// Addr is the address ListenAndServe binds.
+func (e *Edge) Addr() string { return ":" + e.cfg.Port }
+
+// ListenAndServe refuses to start on the contradictions edge.Serve refuses, then serves.
+func (e *Edge) ListenAndServe() error {
+	edge.Validate(e.router)
+	return http.ListenAndServe(e.Addr(), e)
+}
+
+// ServeHTTP answers from PublicDir when the request names an existing file, and hands
+// everything else to the edge pipeline — the order Pages uses: assets first, then Functions.
+func (e *Edge) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if file, ok := e.static(r); ok {
+		w.Header().Set("Cache-Control", "no-cache")
+		http.ServeFile(w, r, file)
+		return
+	}
+
+	ctx := &httpContext{req: r, headers: map[string]string{}}
+	defer func() {
+		// The Worker recovers at its request boundary and answers 500 with the cause logged;
+		// so does this, or a panic would surface as a dropped connection instead.
+		if v := recover(); v != nil {
+			log.Panic(r.Method, r.URL.Path, v)
+			http.Error(w, "internal error", http.StatusInternalServerError)
+		}
+	}()
+	edge.Dispatch(e.router, ctx)
+	ctx.flush(w)
+}
+
+// static resolves a GET/HEAD to a file under PublicDir. A directory resolves to its
+// index.html. Nothing outside PublicDir is reachable: the path is cleaned first.
+func (e *Edge) static(r *http.Request) (string, bool) {
+	if r.Method != http.MethodGet && r.Method != http.MethodHead {
+		return "", false
+	}
+	name := filepath.Join(e.cfg.PublicDir, filepath.FromSlash(filepath.Clean("/"+r.URL.Path)))
+	info, err := os.Stat(name)
+	if err == nil && info.IsDir() {
+		name = filepath.Join(name, "index.html")
+		info, err = os.Stat(name)
+	}
+	if err != nil || info.IsDir() {
+		return "", false
+	}
+	return name, true
+}
+
+// httpContext adapts one net/http request to router.Context. The response is buffered, as
+// the Worker's is, so a handler may set a header after writing and still have it sent.
+type httpContext struct {
+	req      *http.Request
+	body     []byte
+	bodyRead bool
+	vals     map[string]any
+	uid      string
+
+	status  int
+	headers map[string]string
+	cookies []*http.Cookie
+	out     []byte
+	stream  io.ReadCloser // set by WriteStream; replaces out
+}
+
+func (c *httpContext) Method() string { return c.req.Method }
+func (c *httpContext) Path() string   { return c.req.URL.Path }
+func (c *httpContext) Query() string  { return c.req.URL.RawQuery }
+
+func (c *httpContext) Body() []byte {
+	if !c.bodyRead {
+		c.body, _ = io.ReadAll(c.req.Body)
+		c.bodyRead = true
+	}
+	return c.body
+}
+
+// BodyStream and WriteStream are the native side of r2.Stream: an upload piped to the bucket
+// and an object piped to the client, as the edge does, instead of buffered.
+func (c *httpContext) BodyStream() io.ReadCloser { return c.req.Body }
+
+func (c *httpContext) WriteStream(body io.ReadCloser) {
+	c.stream = body
+	c.out = nil
+}
+
+func (c *httpContext) GetHeader(key string) string { return c.req.Header.Get(key) }
+func (c *httpContext) SetHeader(key, value string) { c.headers[key] = value }
+func (c *httpContext) WriteStatus(code int)        { c.status = code }
+
+func (c *httpContext) Write(b []byte) (int, error) {
+	c.out = append(c.out, b...)
+	return len(b), nil
+}
+
+func (c *httpContext) SetValue(key string, v any) {
+	if c.vals == nil {
+		c.vals = map[string]any{}
+	}
+	c.vals[key] = v
+}
+
+func (c *httpContext) Value(key string) any { return c.vals[key] }
+func (c *httpContext) SetUserID(id string)  { c.uid = id }
+func (c *httpContext) UserID() string       { return c.uid }
+
+func (c *httpContext) Decode(into model.Decodable) error {
+	return json.Decode(c.Body(), into)
+}
+
+func (c *httpContext) Encode(v model.Encodable) error {
+	var buf []byte
+	if err := json.Encode(v, &buf); err != nil {
+		return err
+	}
+	_, err := c.Write(buf)
+	return err
+}
+
+func (c *httpContext) SetCookie(cookie router.Cookie) {
+	hc := &http.Cookie{
+		Name:     cookie.Name,
+		Value:    cookie.Value,
+		Path:     cookie.Path,
+		Domain:   cookie.Domain,
+		MaxAge:   cookie.MaxAge,
+		Secure:   cookie.Secure,
+		HttpOnly: cookie.HttpOnly,
+	}
+	switch cookie.SameSite {
+	case router.SameSiteLax:
+		hc.SameSite = http.SameSiteLaxMode
+	case router.SameSiteStrict:
+		hc.SameSite = http.SameSiteStrictMode
+	case router.SameSiteNone:
+		hc.SameSite = http.SameSiteNoneMode
+	}
+	c.cookies = append(c.cookies, hc)
+}
+
+func (c *httpContext) Cookie(name string) (router.Cookie, bool) {
+	hc, err := c.req.Cookie(name)
+	if err != nil {
+		return router.Cookie{}, false
+	}
+	return router.Cookie{Name: name, Value: hc.Value}, true
+}
+
+// flush sends the buffered response. The status defaults to 200, as a Worker's does.
+func (c *httpContext) flush(w http.ResponseWriter) {
+	for k, v := range c.headers {
+		w.Header().Set(k, v)
+	}
+	for _, hc := range c.cookies {
+		http.SetCookie(w, hc)
+	}
+	status := c.status
+	if status == 0 {
+		status = http.StatusOK
+	}
+	w.WriteHeader(status)
+	if c.stream != nil {
+		defer c.stream.Close()
+		if c.req.Method != http.MethodHead {
+			io.Copy(w, c.stream)
+		}
+		return
+	}
+	if c.req.Method != http.MethodHead {
+		w.Write(c.out)
+	}
+}
+
+var _ router.Context = (*httpContext)(nil)
+var _ http.Handler = (*Edge)(nil)
@@ -0,0 +1,94 @@
+//go:build !wasm
+
+package goflare
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"path/filepath"
+
+	"github.com/tinywasm/goflare/d1"
+	"github.com/tinywasm/model"
+)
+
+// DriftEntry is the project's drift checker: cmd/drift/main.go, a few lines that pass the
+// app's models to RunDrift. goflare cannot see the models itself — they are the app's Go
+// code — so `goflare d1 drift` runs this program.
+const DriftEntry = "cmd/drift"
+
+// RunDrift compares a database with the schema models compile to and prints the
+// differences. args are the command's flags: -env, -remote (the deployed database instead
+// of the local one) and -emit NAME (write a migration closing the drift). It returns an
+// error when drift is found, so CI can fail on it.
+//
+//	func main() {
+//	    if err := goflare.RunDrift(os.Args[1:], os.Stdout, &user.User{}, &order.Order{}); err != nil {
+//	        fmt.Fprintln(os.Stderr, "Error:", err)
+//	        os.Exit(1)
+//	    }
+//	}
+func RunDrift(args []string, out io.Writer, models ...model.Model) error {
+	fs := flag.NewFlagSet("d1 drift", flag.ContinueOnError)
+	env := fs.String("env", ".env", "path to .env file")
+	remote := fs.Bool("remote", false, "compare the deployed database")
+	emit := fs.String("emit", "", "write a migration with this name that closes the drift")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+
+	cfg, err := LoadConfigFromEnv(*env)
+	if err != nil {
+		return err
+	}
+	g := New(cfg)
+	db, err := g.OpenD1(*remote)
+	if err != nil {
+		return err
+	}
+	defer db.Close()
+
+	drift, err := d1.Diff(db, models...)
+	if err != nil {
+		return err
+	}
+	if len(drift) == 0 {
+		fmt.Fprintln(out, "No drift: the database matches the models.")
+		return nil
+	}
+	for _, d := range drift {
+		fmt.Fprintln(out, " ", d)
+	}
+
+	if *emit != "" {
+		path, err := d1.CreateMigration(cfg.MigrationsDir, *emit)
+		if err != nil {
+			return err
+		}
+		f, err := os.OpenFile(path, os.O_APPEND|os.O_WRONLY, 0)
+		if err != nil {
+			return err
+		}
+		_, err = f.WriteString(d1.DriftSQL(drift))
+		if cerr := f.Close(); err == nil {
+			err = cerr
+		}
+		if err != nil {
+			return err
+		}
+		fmt.Fprintln(out, "Wrote", path, "— review it, then `goflare d1 migrate up`")
+	}
+	return fmt.Errorf("%d schema difference(s) between the models and the database", len(drift))
+}
+
+// RunDriftEntry runs the `d1 drift` command: DriftEntry, with args passed through.
+func RunDriftEntry(args []string, out io.Writer) error {
+	if _, err := os.Stat(filepath.Join(DriftEntry, "main.go")); err != nil {
+		return fmt.Errorf("no drift checker: %s/main.go is missing (see docs/D1.md → Schema drift)", DriftEntry)
+	}
+	cmd := exec.Command("go", append([]string{"run", "./" + DriftEntry}, args...)...)
+	cmd.Stdout, cmd.Stderr = out, out
+	return cmd.Run()
+}
@@ -0,0 +1,218 @@
+// Package edgetest drives an edge router natively, from `go test`, with no browser and no
+// Cloudflare runtime.
+//
+// A Context is one request: set its method, path, headers, cookies and user, hand it to
+// Serve, then read back what the pipeline answered. It records for real — the identity the
+// Authn middleware establishes, every header and cookie the handler sets — because a fake
+// that cannot hold what the pipeline did is how the upload API once shipped as a permanent
+// 403 with a green suite.
+//
+//	r := edge.NewRouter(cfg)
+//	routes.Register(r)
+//
+//	c := edgetest.Serve(r, edgetest.NewRequest("GET", "/api/me").WithUser("u1"))
+//	c.ExpectStatus(t, 200)
+package edgetest
+
+import (
+	"testing"
+
+	"github.com/tinywasm/fmt"
+	"github.com/tinywasm/goflare/edge"
+	"github.com/tinywasm/json"
+	"github.com/tinywasm/model"
+	"github.com/tinywasm/router"
+)
+
+// Context is a router.Context that records what the pipeline did to it. The request side
+// is set with the With* methods; the response side is read with Status, Header, ResponseBody
+// and SetCookies once Serve has returned.
+type Context struct {
+	method  string
+	path    string
+	query   string
+	body    []byte
+	headers map[string]string
+	cookies map[string]string
+	vals    map[string]any
+	uid     string
+	piped   bool
+
+	status     int
+	resHeaders map[string]string
+	resCookies []router.Cookie
+	out        []byte
+	streamed   bool
+}
+
+// NewRequest starts a request for method and path. path is the pathname only; a query goes
+// through WithQuery.
+func NewRequest(method, path string) *Context {
+	return &Context{
+		method:     method,
+		path:       path,
+		headers:    map[string]string{},
+		cookies:    map[string]string{},
+		resHeaders: map[string]string{},
+	}
+}
+
+// WithBody sets the raw request body.
+func (c *Context) WithBody(b []byte) *Context {
+	c.body = b
+	return c
+}
+
+// WithQuery sets the raw query string, without the "?".
+func (c *Context) WithQuery(q string) *Context {
+	c.query = q
+	return c
+}
+
+// WithHeader sets a request header. The name is kept lowercased, as the Workers runtime hands
+// it over, and GetHeader matches it in any case — so a handler that only works with one
+// spelling fails here, not in production.
+func (c *Context) WithHeader(key, value string) *Context {
+	c.headers[fmt.ToLower(key)] = value
+	return c
+}
+
+// WithCookie sends a cookie with the request.
+func (c *Context) WithCookie(name, value string) *Context {
+	c.cookies[name] = value
+	return c
+}
+
+// WithUser presets the caller's identity, as if Authn had established it. It tests access
+// rules without minting a real credential; to test the Authn middleware itself, send the
+// cookie or header it reads instead.
+func (c *Context) WithUser(id string) *Context {
+	c.uid = id
+	return c
+}
+
+// Serve drives c through the full edge pipeline of r — Authn, access gate, middleware,
+// validation, handler — and returns c for reading.
+func Serve(r router.Router, c *Context) *Context {
+	edge.Dispatch(r, c)
+	return c
+}
+
+// Status is the answered status. A handler that wrote without calling WriteStatus answered
+// 200, exactly as the Worker would.
+func (c *Context) Status() int {
+	if c.status == 0 {
+		return 200
+	}
+	return c.status
+}
+
+// Header is a response header the pipeline set.
+func (c *Context) Header(key string) string { return c.resHeaders[key] }
+
+// ResponseBody is the response body.
+func (c *Context) ResponseBody() []byte { return c.out }
+
+// SetCookies are the cookies the response set, in order.
+func (c *Context) SetCookies() []router.Cookie { return c.resCookies }
+
+// ExpectStatus fails t unless the answered status is want.
+func (c *Context) ExpectStatus(t testing.TB, want int) {
+	t.Helper()
+	if got := c.Status(); got != want {
+		t.Errorf("%s %s: status = %d, want %d (body: %s)", c.method, c.path, got, want, c.out)
+	}
+}
+
+// ExpectHeader fails t unless the response header key is want.
+func (c *Context) ExpectHeader(t testing.TB, key, want string) {
+	t.Helper()
+	if got := c.resHeaders[key]; got != want {
+		t.Errorf("%s %s: header %s = %q, want %q", c.method, c.path, key, got, want)
+	}
+}
+
+// ExpectBody fails t unless the response body is exactly want.
+func (c *Context) ExpectBody(t testing.TB, want string) {
+	t.Helper()
+	if got := string(c.out); got != want {
+		t.Errorf("%s %s: body = %q, want %q", c.method, c.path, got, want)
+	}
+}
+
+// ── router.Context ───────────────────────────────────────────────────────────
+
+// Method implements router.Context.
+func (c *Context) Method() string { return c.method }
+
+// Path implements router.Context.
+func (c *Context) Path() string { return c.path }
+
+// Body is the REQUEST body. What the handler wrote is read with ResponseBody.
+func (c *Context) Body() []byte { return c.body }
+
+// Query is the raw query string; the pipeline validates it against a declared model.
+func (c *Context) Query() string { return c.query }
+
+// GetHeader reads a REQUEST header. What the handler set is read with Header.
+func (c *Context) GetHeader(key string) string { return c.headers[fmt.ToLower(key)] }
+
+// SetHeader implements router.Context.
+func (c *Context) SetHeader(key, value string) { c.resHeaders[key] = value }
+
+// WriteStatus implements router.Context.
+func (c *Context) WriteStatus(code int) { c.status = code }
+
+// Write implements router.Context.
+func (c *Context) Write(b []byte) (int, error) {
+	c.out = append(c.out, b...)
+	return len(b), nil
+}
+
+// SetValue implements router.Context.
+func (c *Context) SetValue(key string, v any) {
+	if c.vals == nil {
+		c.vals = map[string]any{}
+	}
+	c.vals[key] = v
+}
+
+// Value implements router.Context.
+func (c *Context) Value(key string) any { return c.vals[key] }
+
+// SetUserID implements router.Context. It records the identity for real: the gate reads it.
+func (c *Context) SetUserID(id string) { c.uid = id }
+
+// UserID implements router.Context.
+func (c *Context) UserID() string { return c.uid }
+
+// Decode implements router.Context.
+func (c *Context) Decode(into model.Decodable) error {
+	return json.Decode(c.body, into)
+}
+
+// Encode implements router.Context.
+func (c *Context) Encode(v model.Encodable) error {
+	var buf []byte
+	if err := json.Encode(v, &buf); err != nil {
+		return err
+	}
+	_, err := c.Write(buf)
+	return err
+}
+
+// SetCookie implements router.Context.
+func (c *Context) SetCookie(cookie router.Cookie) {
+	c.resCookies = append(c.resCookies, cookie)
+}
+
+// Cookie reads a REQUEST cookie. What the handler set is read with SetCookies.
+func (c *Context) Cookie(name string) (router.Cookie, bool) {
+	v, ok := c.cookies[name]
+	if !ok {
+		return router.Cookie{}, false
+	}
+	return router.Cookie{Name: name, Value: v}, true
+}
+
+var _ router.Context = (*Context)(nil)
@@ -0,0 +1,30 @@
+//go:build !wasm
+
+package edgetest
+
+import (
+	"bytes"
+	"io"
+)
+
+// BodyStream is the request body as a stream — the native r2.Stream — so a handler that
+// pipes instead of buffering (files.Store over r2) runs down the same path it takes at the
+// edge.
+func (c *Context) BodyStream() io.ReadCloser {
+	c.piped = true
+	return io.NopCloser(bytes.NewReader(c.body))
+}
+
+// Piped reports whether the handler read the request body with BodyStream rather than Body:
+// whether an upload was streamed or held in memory.
+func (c *Context) Piped() bool { return c.piped }
+
+// WriteStream reads body to its end into ResponseBody and closes it.
+func (c *Context) WriteStream(body io.ReadCloser) {
+	defer body.Close()
+	c.out, _ = io.ReadAll(body)
+	c.streamed = true
+}
+
+// Streamed reports whether the handler answered with WriteStream rather than Write.
+func (c *Context) Streamed() bool { return c.streamed }
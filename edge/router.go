@@ -1,166 +1,48 @@
-//go:build wasm
-
+// Package edge is the router a goflare app runs at the edge.
+//
+// The pipeline — matching, the access gate, input validation, Dispatch — is platform
+// independent and compiles natively, so `go test` drives it without a browser. Only Serve and
+// the request adapter behind it (serve_wasm.go) need the Workers runtime.
 package edge
 
 import (
-	"syscall/js"
-
 	"github.com/tinywasm/fmt"
 	"github.com/tinywasm/goflare/log"
-	"github.com/tinywasm/goflare/workers"
-	"github.com/tinywasm/json"
 	"github.com/tinywasm/model"
 	"github.com/tinywasm/router"
 )
 
-type wasmContext struct {
-	req  *workers.Request
-	res  *workers.Response
-	path string
-	vals map[string]any
-	uid  string
-}
-
-func (c *wasmContext) Method() string { return c.req.Method }
-func (c *wasmContext) Path() string   { return c.path }
-func (c *wasmContext) Body() []byte   { return c.req.Body() }
-func (c *wasmContext) GetHeader(key string) string {
-	return c.req.Headers[key]
-}
-func (c *wasmContext) SetHeader(key, value string) {
-	c.res.Header()[key] = value
-}
-func (c *wasmContext) WriteStatus(code int) {
-	c.res.WriteHeader(code)
-}
-func (c *wasmContext) Write(b []byte) (int, error) {
-	return c.res.Write(b)
-}
-
-func (c *wasmContext) SetValue(key string, v any) {
-	if c.vals == nil {
-		c.vals = make(map[string]any)
-	}
-	c.vals[key] = v
-}
-
-func (c *wasmContext) Value(key string) any {
-	if c.vals == nil {
-		return nil
-	}
-	return c.vals[key]
-}
-
-func (c *wasmContext) SetUserID(id string) {
-	c.uid = id
-}
-
-func (c *wasmContext) UserID() string {
-	return c.uid
-}
-
-func (c *wasmContext) Decode(into model.Decodable) error {
-	return json.Decode(c.Body(), into)
-}
-
-func (c *wasmContext) Encode(v model.Encodable) error {
-	var buf []byte
-	if err := json.Encode(v, &buf); err != nil {
-		return err
-	}
-	_, err := c.Write(buf)
-	return err
-}
-
-func (c *wasmContext) SetCookie(cookie router.Cookie) {
-	var s string
-	s = fmt.Sprintf("%s=%s; Path=%s", cookie.Name, cookie.Value, cookie.Path)
-	if cookie.Domain != "" {
-		s += "; Domain=" + cookie.Domain
-	}
-	if cookie.MaxAge > 0 {
-		s += fmt.Sprintf("; Max-Age=%d", cookie.MaxAge)
-	}
-	if cookie.Secure {
-		s += "; Secure"
-	}
-	if cookie.HttpOnly {
-		s += "; HttpOnly"
-	}
-	switch cookie.SameSite {
-	case router.SameSiteLax:
-		s += "; SameSite=Lax"
-	case router.SameSiteStrict:
-		s += "; SameSite=Strict"
-	case router.SameSiteNone:
-		s += "; SameSite=None"
-	}
-
-	existing := c.res.Header()["Set-Cookie"]
-	if existing == "" {
-		c.res.Header()["Set-Cookie"] = s
-	} else {
-		c.res.Header()["Set-Cookie"] = existing + ", " + s
-	}
-}
-
-func (c *wasmContext) Cookie(name string) (router.Cookie, bool) {
-	h := c.req.Headers["Cookie"]
-	if h == "" {
-		return router.Cookie{}, false
-	}
-
-	// Manual parsing of "Cookie" header: name1=val1; name2=val2
-	// Minimal implementation: search for "name="
-	// Note: we can't use strings.Split (stdlib prohibited)
-	// We'll do a simple scan.
-	b := []byte(h)
-	nb := []byte(name + "=")
-	for i := 0; i <= len(b)-len(nb); i++ {
-		match := true
-		for j := 0; j < len(nb); j++ {
-			if b[i+j] != nb[j] {
-				match = false
-				break
-			}
-		}
-		if match && (i == 0 || b[i-1] == ' ' || b[i-1] == ';') {
-			start := i + len(nb)
-			end := start
-			for end < len(b) && b[end] != ';' {
-				end++
-			}
-			val := string(b[start:end])
-			return router.Cookie{Name: name, Value: val}, true
-		}
-	}
-
-	return router.Cookie{}, false
-}
-
-type wasmRoute struct {
+type edgeRoute struct {
 	info router.RouteInfo
 	h    router.HandlerFunc
 }
 
-func (r *wasmRoute) Requires(resource model.Resource, action model.Action) router.Route {
+func (r *edgeRoute) Requires(resource model.Resource, action model.Action) router.Route {
 	r.info.Access = model.AccessGuarded
 	r.info.Resource = resource
 	r.info.Action = action
 	return r
 }
 
-func (r *wasmRoute) Authenticated() router.Route {
+func (r *edgeRoute) Authenticated() router.Route {
 	r.info.Access = model.AccessAuthenticated
 	return r
 }
 
-func (r *wasmRoute) Public() router.Route {
+func (r *edgeRoute) Public() router.Route {
 	r.info.Access = model.AccessPublic
 	return r
 }
 
-func (r *wasmRoute) Accepts(args model.Fielder) router.Route {
+// Accepts declares the model the request decodes into. Dispatch enforces its schema: a
+// request that does not decode, or whose fields break the rules their Field declares — kind,
+// NotNull, Permitted — is answered 400 before the handler. args is only read for its schema;
+// each request decodes into a value of its own.
+//
+// The model's own Validate method is not run: edge cannot make a new value of an app's type
+// without reflection, which the wasm build leaves out. Rules written by hand in Validate —
+// one field against another, a lookup — belong in the handler, after it decodes its input.
+func (r *edgeRoute) Accepts(args model.Fielder) router.Route {
 	r.info.Args = args
 	return r
 }
@@ -186,9 +68,9 @@ type Config struct {
 	Authorize model.Authorizer
 }
 
-type wasmRouter struct {
+type edgeRouter struct {
 	cfg         Config
-	routes      []*wasmRoute
+	routes      []*edgeRoute
 	middlewares []router.Middleware
 }
 
@@ -196,26 +78,26 @@ type wasmRouter struct {
 // could not authenticate anybody, which made every guarded route unreachable. An app with
 // no auth passes edge.Config{} — explicitly.
 func NewRouter(cfg Config) router.Router {
-	return &wasmRouter{cfg: cfg}
+	return &edgeRouter{cfg: cfg}
 }
 
-func (r *wasmRouter) Get(path string, h router.HandlerFunc) router.Route {
+func (r *edgeRouter) Get(path string, h router.HandlerFunc) router.Route {
 	return r.Handle("GET", path, h)
 }
-func (r *wasmRouter) Post(path string, h router.HandlerFunc) router.Route {
+func (r *edgeRouter) Post(path string, h router.HandlerFunc) router.Route {
 	return r.Handle("POST", path, h)
 }
-func (r *wasmRouter) Put(path string, h router.HandlerFunc) router.Route {
+func (r *edgeRouter) Put(path string, h router.HandlerFunc) router.Route {
 	return r.Handle("PUT", path, h)
 }
-func (r *wasmRouter) Delete(path string, h router.HandlerFunc) router.Route {
+func (r *edgeRouter) Delete(path string, h router.HandlerFunc) router.Route {
 	return r.Handle("DELETE", path, h)
 }
-func (r *wasmRouter) Options(path string, h router.HandlerFunc) router.Route {
+func (r *edgeRouter) Options(path string, h router.HandlerFunc) router.Route {
 	return r.Handle("OPTIONS", path, h)
 }
-func (r *wasmRouter) Handle(method, path string, h router.HandlerFunc) router.Route {
-	rt := &wasmRoute{
+func (r *edgeRouter) Handle(method, path string, h router.HandlerFunc) router.Route {
+	rt := &edgeRoute{
 		info: router.RouteInfo{Method: method, Path: path},
 		h:    h,
 	}
@@ -224,8 +106,8 @@ func (r *wasmRouter) Handle(method, path string, h router.HandlerFunc) router.Ro
 }
 
 // PublicAsset registra UNA ruta que sirve UN archivo al navegador.
-func (r *wasmRouter) PublicAsset(path string, h router.HandlerFunc) {
-	route := &wasmRoute{
+func (r *edgeRouter) PublicAsset(path string, h router.HandlerFunc) {
+	route := &edgeRoute{
 		info: router.RouteInfo{Method: "GET", Path: path, Access: model.AccessPublic},
 		h:    h,
 	}
@@ -233,18 +115,18 @@ func (r *wasmRouter) PublicAsset(path string, h router.HandlerFunc) {
 }
 
 // PublicDir sirve un directorio bajo un prefijo. Mismo contrato.
-func (r *wasmRouter) PublicDir(prefix string, dir string) {
-	route := &wasmRoute{
+func (r *edgeRouter) PublicDir(prefix string, dir string) {
+	route := &edgeRoute{
 		info: router.RouteInfo{Method: "GET", Path: prefix, Access: model.AccessPublic, Dir: dir},
 	}
 	r.routes = append(r.routes, route)
 }
 
-func (r *wasmRouter) Use(m ...router.Middleware) {
+func (r *edgeRouter) Use(m ...router.Middleware) {
 	r.middlewares = append(r.middlewares, m...)
 }
 
-func (r *wasmRouter) Routes() []router.RouteInfo {
+func (r *edgeRouter) Routes() []router.RouteInfo {
 	infos := make([]router.RouteInfo, len(r.routes))
 	for i, rt := range r.routes {
 		infos[i] = rt.info
@@ -252,11 +134,11 @@ func (r *wasmRouter) Routes() []router.RouteInfo {
 	return infos
 }
 
-func (r *wasmRouter) Stream(path string, h router.StreamFunc) router.Route {
+func (r *edgeRouter) Stream(path string, h router.StreamFunc) router.Route {
 	panic("Stream not supported in this runtime")
 }
 
-func (r *wasmRouter) Socket(path string, h router.SocketFunc) router.Route {
+func (r *edgeRouter) Socket(path string, h router.SocketFunc) router.Route {
 	panic("Socket not supported in this runtime")
 }
 
@@ -286,8 +168,8 @@ func pathMatches(pattern, pathname string) bool {
 //
 // The second result is the status to answer when nothing matched: 405 when the path exists
 // but not for this method, 404 when it does not exist at all.
-func (r *wasmRouter) match(method, pathname string) (*wasmRoute, int) {
-	var best *wasmRoute
+func (r *edgeRouter) match(method, pathname string) (*edgeRoute, int) {
+	var best *edgeRoute
 	pathExists := false
 
 	for _, rt := range r.routes {
@@ -314,7 +196,7 @@ func (r *wasmRouter) match(method, pathname string) (*wasmRoute, int) {
 
 // allows is the access gate. The zero value of Access is AccessGuarded, so a route that
 // declares nothing is unreachable — and validateRoutes already refused to start on it.
-func (r *wasmRouter) allows(info router.RouteInfo, userID string) (bool, string) {
+func (r *edgeRouter) allows(info router.RouteInfo, userID string) (bool, string) {
 	switch info.Access {
 	case model.AccessPublic:
 		return true, ""
@@ -343,8 +225,14 @@ func (r *wasmRouter) allows(info router.RouteInfo, userID string) (bool, string)
 // It panics rather than returning an error: there is nobody to hand an error to at the top of
 // a Worker, and goflare recovers and logs panics. Loud beats silent.
 func Validate(r router.Router) {
-	wr := r.(*wasmRouter)
+	wr := r.(*edgeRouter)
 	for _, rt := range wr.routes {
+		if rt.info.Args != nil {
+			if _, bad := newArgs(rt.info.Args); bad != "" {
+				panic("edge: route " + rt.info.Method + " " + rt.info.Path +
+					" accepts a model whose field \"" + bad + "\" cannot be checked: every request would be refused")
+			}
+		}
 		if rt.info.Access != model.AccessGuarded {
 			continue
 		}
@@ -364,27 +252,16 @@ func Validate(r router.Router) {
 	}
 }
 
-func Serve(r router.Router) {
-	wr := r.(*wasmRouter)
-
-	// Loudly, at startup — never a silent 403 in production.
-	Validate(wr)
-
-	workers.Handle(func(res *workers.Response, req *workers.Request) {
-		pathname := js.Global().Get("URL").New(req.URL).Get("pathname").String()
-		Dispatch(wr, &wasmContext{req: req, res: res, path: pathname})
-	})
-}
-
 // Dispatch drives ONE request through the full pipeline: identity, access gate, middleware,
-// handler. It speaks only router.Context, so the pipeline that runs in production is the
-// same one a test can drive — with no Cloudflare runtime and no js.Global() in sight.
+// input validation, handler. It speaks only router.Context, so the pipeline that runs in
+// production is the same one a test can drive — natively, with no Cloudflare runtime and no
+// js.Global() in sight. edgetest.Context is the recording Context built for exactly that.
 //
 // That is not a convenience: the previous tests called the matched handler DIRECTLY, past
 // the gate, which is why they stayed green while every guarded route answered 403 in
 // production. A pipeline you cannot drive is a pipeline nobody tests.
 func Dispatch(r router.Router, ctx router.Context) {
-	wr := r.(*wasmRouter)
+	wr := r.(*edgeRouter)
 
 	// The gate decides with the identity Authn established. Run these the other way round —
 	// as this router used to — and no caller can EVER be authorized, on any route: the gate
@@ -396,7 +273,7 @@ func Dispatch(r router.Router, ctx router.Context) {
 	gate(ctx)
 }
 
-func (r *wasmRouter) gateAndServe(ctx router.Context) {
+func (r *edgeRouter) gateAndServe(ctx router.Context) {
 	method, pathname := ctx.Method(), ctx.Path()
 
 	route, status := r.match(method, pathname)
@@ -422,12 +299,21 @@ func (r *wasmRouter) gateAndServe(ctx router.Context) {
 	// logic — decoding a body or hitting a database for a caller about to get a 403 is work
 	// (and attack surface) handed to somebody already denied.
 	h := route.h
+	if args := route.info.Args; args != nil {
+		// What the route declared with Accepts is enforced, not decorative: the handler only
+		// ever sees input that decoded into the model's schema and met its field rules.
+		next := h
+		h = func(c router.Context) {
+			if validateArgs(c, args) {
+				next(c)
+			}
+		}
+	}
 	for i := len(r.middlewares) - 1; i >= 0; i-- {
 		h = r.middlewares[i](h)
 	}
 	h(ctx)
 }
 
-var _ router.Router = (*wasmRouter)(nil)
-var _ router.Context = (*wasmContext)(nil)
-var _ router.Route = (*wasmRoute)(nil)
+var _ router.Router = (*edgeRouter)(nil)
+var _ router.Route = (*edgeRoute)(nil)
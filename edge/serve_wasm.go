@@ -0,0 +1,173 @@
+//go:build wasm
+
+package edge
+
+import (
+	"syscall/js"
+
+	"github.com/tinywasm/fmt"
+	"github.com/tinywasm/goflare/workers"
+	"github.com/tinywasm/json"
+	"github.com/tinywasm/model"
+	"github.com/tinywasm/router"
+)
+
+type wasmContext struct {
+	req   *workers.Request
+	res   *workers.Response
+	path  string
+	query string
+	vals  map[string]any
+	uid   string
+}
+
+func (c *wasmContext) Method() string { return c.req.Method }
+func (c *wasmContext) Path() string   { return c.path }
+
+// Query is the raw query string, without the "?". It is how a route that Accepts a model
+// gets its input validated on a GET, where there is no body.
+func (c *wasmContext) Query() string { return c.query }
+func (c *wasmContext) Body() []byte  { return c.req.Body() }
+
+// BodyStream and WriteStream let a handler pipe a body instead of buffering it: an upload
+// straight into R2, an R2 object straight to the client. They are the edge side of r2.Stream.
+func (c *wasmContext) BodyStream() js.Value      { return c.req.BodyStream() }
+func (c *wasmContext) WriteStream(body js.Value) { c.res.WriteStream(body) }
+
+// GetHeader matches key in any case: the runtime holds header names lowercased, so an exact
+// lookup of "Content-Type" would never find one.
+func (c *wasmContext) GetHeader(key string) string {
+	return c.req.Header(key)
+}
+func (c *wasmContext) SetHeader(key, value string) {
+	c.res.Header()[key] = value
+}
+func (c *wasmContext) WriteStatus(code int) {
+	c.res.WriteHeader(code)
+}
+func (c *wasmContext) Write(b []byte) (int, error) {
+	return c.res.Write(b)
+}
+
+func (c *wasmContext) SetValue(key string, v any) {
+	if c.vals == nil {
+		c.vals = make(map[string]any)
+	}
+	c.vals[key] = v
+}
+
+func (c *wasmContext) Value(key string) any {
+	if c.vals == nil {
+		return nil
+	}
+	return c.vals[key]
+}
+
+func (c *wasmContext) SetUserID(id string) {
+	c.uid = id
+}
+
+func (c *wasmContext) UserID() string {
+	return c.uid
+}
+
+func (c *wasmContext) Decode(into model.Decodable) error {
+	return json.Decode(c.Body(), into)
+}
+
+func (c *wasmContext) Encode(v model.Encodable) error {
+	var buf []byte
+	if err := json.Encode(v, &buf); err != nil {
+		return err
+	}
+	_, err := c.Write(buf)
+	return err
+}
+
+func (c *wasmContext) SetCookie(cookie router.Cookie) {
+	var s string
+	s = fmt.Sprintf("%s=%s; Path=%s", cookie.Name, cookie.Value, cookie.Path)
+	if cookie.Domain != "" {
+		s += "; Domain=" + cookie.Domain
+	}
+	if cookie.MaxAge > 0 {
+		s += fmt.Sprintf("; Max-Age=%d", cookie.MaxAge)
+	} else if cookie.MaxAge < 0 {
+		s += "; Max-Age=0" // delete it now, as net/http does for the devserver
+	}
+	if cookie.Secure {
+		s += "; Secure"
+	}
+	if cookie.HttpOnly {
+		s += "; HttpOnly"
+	}
+	switch cookie.SameSite {
+	case router.SameSiteLax:
+		s += "; SameSite=Lax"
+	case router.SameSiteStrict:
+		s += "; SameSite=Strict"
+	case router.SameSiteNone:
+		s += "; SameSite=None"
+	}
+
+	existing := c.res.Header()["Set-Cookie"]
+	if existing == "" {
+		c.res.Header()["Set-Cookie"] = s
+	} else {
+		c.res.Header()["Set-Cookie"] = existing + ", " + s
+	}
+}
+
+func (c *wasmContext) Cookie(name string) (router.Cookie, bool) {
+	h := c.req.Header("Cookie")
+	if h == "" {
+		return router.Cookie{}, false
+	}
+
+	// Manual parsing of "Cookie" header: name1=val1; name2=val2
+	// Minimal implementation: search for "name="
+	// Note: we can't use strings.Split (stdlib prohibited)
+	// We'll do a simple scan.
+	b := []byte(h)
+	nb := []byte(name + "=")
+	for i := 0; i <= len(b)-len(nb); i++ {
+		match := true
+		for j := 0; j < len(nb); j++ {
+			if b[i+j] != nb[j] {
+				match = false
+				break
+			}
+		}
+		if match && (i == 0 || b[i-1] == ' ' || b[i-1] == ';') {
+			start := i + len(nb)
+			end := start
+			for end < len(b) && b[end] != ';' {
+				end++
+			}
+			val := string(b[start:end])
+			return router.Cookie{Name: name, Value: val}, true
+		}
+	}
+
+	return router.Cookie{}, false
+}
+
+// Serve validates the routes and hands every request the Worker receives to Dispatch. It
+// never returns.
+func Serve(r router.Router) {
+	wr := r.(*edgeRouter)
+
+	// Loudly, at startup — never a silent 403 in production.
+	Validate(wr)
+
+	workers.Handle(func(res *workers.Response, req *workers.Request) {
+		u := js.Global().Get("URL").New(req.URL)
+		query := u.Get("search").String()
+		if query != "" {
+			query = query[1:] // drop the "?"
+		}
+		Dispatch(wr, &wasmContext{req: req, res: res, path: u.Get("pathname").String(), query: query})
+	})
+}
+
+var _ router.Context = (*wasmContext)(nil)
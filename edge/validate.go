@@ -0,0 +1,339 @@
+package edge
+
+import (
+	"github.com/tinywasm/fmt"
+	"github.com/tinywasm/goflare/internal/query"
+	"github.com/tinywasm/goflare/log"
+	"github.com/tinywasm/json"
+	"github.com/tinywasm/model"
+	"github.com/tinywasm/router"
+)
+
+// FieldError is one rejected field, exactly as the client receives it. Field is empty when
+// the failure belongs to the request as a whole (a body that is not JSON at all).
+type FieldError struct {
+	Field   string
+	Message string
+}
+
+// validateArgs enforces what the route declared with Accepts: the request must decode into
+// the model's schema and every field must pass the schema's rules — model.ValidateFields,
+// which is what an ormc-generated Validate runs first. Anything a hand-written Validate adds
+// is not checked here; see Accepts. It answers 400 itself and reports false when the request
+// is refused, so the handler never runs on input nobody checked.
+//
+// The body is the input when there is one; otherwise the query string is. The handler still
+// decodes its own copy — the declared model is a schema, not a per-request value.
+func validateArgs(ctx router.Context, declared model.Fielder) bool {
+	a, bad := newArgs(declared)
+	if bad != "" {
+		// Validate refuses to start on this; only a router that skipped it gets here.
+		log.Fail(500, ctx.Method(), ctx.Path(), fmt.Err("edge: Accepts model field", bad, "cannot be checked"))
+		ctx.WriteStatus(500)
+		return false
+	}
+
+	data := ctx.Body()
+	if len(data) == 0 {
+		if q, ok := ctx.(query.Querier); ok {
+			data = queryToJSON(q.Query(), a.schema)
+		}
+	}
+
+	var errs []FieldError
+	if len(data) != 0 {
+		if err := json.Decode(data, a); err != nil {
+			errs = append(errs, FieldError{Message: "malformed input: " + err.Error()})
+		}
+	}
+	if errs == nil {
+		errs = a.validate(actionOf(ctx.Method()))
+	}
+	if len(errs) == 0 {
+		return true
+	}
+
+	log.Reject(400, ctx.Method(), ctx.Path(), describe(errs))
+	ctx.SetHeader("Content-Type", "application/json")
+	ctx.WriteStatus(400)
+	ctx.Write(encodeFieldErrors(errs))
+	return false
+}
+
+// args is one request's copy of a declared model: the same schema over storage of its own.
+// The declared value is the app's and every request shares it, so it is never written: two
+// requests decoding into it would validate each other's fields, and a field the previous
+// request sent would satisfy a required check on this one.
+type args struct {
+	schema []model.Field
+	ptrs   []any
+}
+
+// newArgs allocates fresh, zero storage shaped like m's fields. bad names the first field
+// whose Go type it cannot copy — a nested model — and then a is nil.
+func newArgs(m model.Fielder) (a *args, bad string) {
+	schema, src := m.Schema(), m.Pointers()
+	a = &args{schema: schema, ptrs: make([]any, len(src))}
+	for i, p := range src {
+		switch p.(type) {
+		case *string:
+			a.ptrs[i] = new(string)
+		case *bool:
+			a.ptrs[i] = new(bool)
+		case *int:
+			a.ptrs[i] = new(int)
+		case *int8:
+			a.ptrs[i] = new(int8)
+		case *int16:
+			a.ptrs[i] = new(int16)
+		case *int32:
+			a.ptrs[i] = new(int32)
+		case *int64:
+			a.ptrs[i] = new(int64)
+		case *uint:
+			a.ptrs[i] = new(uint)
+		case *uint8:
+			a.ptrs[i] = new(uint8)
+		case *uint16:
+			a.ptrs[i] = new(uint16)
+		case *uint32:
+			a.ptrs[i] = new(uint32)
+		case *uint64:
+			a.ptrs[i] = new(uint64)
+		case *float32:
+			a.ptrs[i] = new(float32)
+		case *float64:
+			a.ptrs[i] = new(float64)
+		case *[]byte:
+			a.ptrs[i] = new([]byte)
+		case *[]int:
+			a.ptrs[i] = new([]int)
+		default:
+			if i < len(schema) {
+				return nil, schema[i].Name
+			}
+			return nil, "#" + fmt.Convert(i).String()
+		}
+	}
+	return a, ""
+}
+
+func (a *args) Schema() []model.Field { return a.schema }
+func (a *args) Pointers() []any       { return a.ptrs }
+func (a *args) IsNil() bool           { return a == nil }
+
+// DecodeFields implements model.Decodable: each field present in the input is read by its
+// schema name into its own storage.
+func (a *args) DecodeFields(r model.FieldReader) {
+	for i, f := range a.schema {
+		switch p := a.ptrs[i].(type) {
+		case *string:
+			if v, ok := r.String(f.Name); ok {
+				*p = v
+			}
+		case *bool:
+			if v, ok := r.Bool(f.Name); ok {
+				*p = v
+			}
+		case *float32:
+			if v, ok := r.Float(f.Name); ok {
+				*p = float32(v)
+			}
+		case *float64:
+			if v, ok := r.Float(f.Name); ok {
+				*p = v
+			}
+		case *[]byte:
+			if v, ok := r.Bytes(f.Name); ok {
+				*p = v
+			}
+		case *[]int:
+			if arr, ok := r.Array(f.Name); ok {
+				*p = make([]int, arr.Len())
+				for j := range *p {
+					(*p)[j] = int(arr.Int(j))
+				}
+			}
+		default:
+			if v, ok := r.Int(f.Name); ok {
+				setInt(p, v)
+			}
+		}
+	}
+}
+
+// setInt stores v through an integer pointer of any width.
+func setInt(p any, v int64) {
+	switch p := p.(type) {
+	case *int:
+		*p = int(v)
+	case *int8:
+		*p = int8(v)
+	case *int16:
+		*p = int16(v)
+	case *int32:
+		*p = int32(v)
+	case *int64:
+		*p = v
+	case *uint:
+		*p = uint(v)
+	case *uint8:
+		*p = uint8(v)
+	case *uint16:
+		*p = uint16(v)
+	case *uint32:
+		*p = uint32(v)
+	case *uint64:
+		*p = uint64(v)
+	}
+}
+
+// validate checks each field on its own, so the client learns about every bad field at once
+// and each error names its field.
+func (a *args) validate(action byte) []FieldError {
+	var errs []FieldError
+	for i, f := range a.schema {
+		if err := model.ValidateFields(action, field{f, a.ptrs[i]}); err != nil {
+			errs = append(errs, FieldError{Field: f.Name, Message: err.Error()})
+		}
+	}
+	return errs
+}
+
+// field is one field of args as a Fielder of its own, for model.ValidateFields.
+type field struct {
+	f model.Field
+	p any
+}
+
+func (f field) Schema() []model.Field { return []model.Field{f.f} }
+func (f field) Pointers() []any       { return []any{f.p} }
+
+// actionOf is the CRUD action a request method performs: what ValidateFields needs to know
+// which fields a request may leave out (an auto-increment key on create, all but the key on
+// delete).
+func actionOf(method string) byte {
+	switch method {
+	case "GET", "HEAD":
+		return model.ActionRead
+	case "PUT", "PATCH":
+		return model.ActionUpdate
+	case "DELETE":
+		return model.ActionDelete
+	}
+	return model.ActionCreate
+}
+
+// describe is the log line: the field names and what was wrong with them, never the values —
+// a rejected body can still carry a password.
+func describe(errs []FieldError) string {
+	s := "invalid input:"
+	for i, e := range errs {
+		if i > 0 {
+			s += ";"
+		}
+		if e.Field != "" {
+			s += " " + e.Field + ":"
+		}
+		s += " " + e.Message
+	}
+	return s
+}
+
+// encodeFieldErrors renders {"errors":[{"field":"…","message":"…"}]}. It is written by hand
+// because FieldError is a response shape, not a model, and tinywasm/json encodes models.
+func encodeFieldErrors(errs []FieldError) []byte {
+	b := fmt.Convert()
+	b.WriteString(`{"errors":[`)
+	for i, e := range errs {
+		if i > 0 {
+			b.WriteByte(',')
+		}
+		b.WriteString(`{"field":"`)
+		fmt.JSONEscape(e.Field, b)
+		b.WriteString(`","message":"`)
+		fmt.JSONEscape(e.Message, b)
+		b.WriteString(`"}`)
+	}
+	b.WriteString(`]}`)
+	return []byte(b.String())
+}
+
+// queryToJSON turns "a=1&b=x" into {"a":1,"b":"x"} so a query decodes through the same path
+// as a body. A value is emitted bare only when the schema declares its field an int, a float
+// or a bool and the text is one; everything else is a string, so ?zip=02134 stays "02134" in
+// a text field, and a number in an int field that is not one fails to decode.
+func queryToJSON(q string, schema []model.Field) []byte {
+	if q == "" {
+		return nil
+	}
+	b := fmt.Convert()
+	b.WriteByte('{')
+	n := 0
+	for _, pair := range fmt.Split(q, "&") {
+		if pair == "" {
+			continue
+		}
+		key, val := pair, ""
+		if i := fmt.Index(pair, "="); i >= 0 {
+			key, val = pair[:i], pair[i+1:]
+		}
+		key, val = query.Unescape(key), query.Unescape(val)
+		if n > 0 {
+			b.WriteByte(',')
+		}
+		b.WriteByte('"')
+		fmt.JSONEscape(key, b)
+		b.WriteString(`":`)
+		if bare(val, storageOf(schema, key)) {
+			b.WriteString(val)
+		} else {
+			b.WriteByte('"')
+			fmt.JSONEscape(val, b)
+			b.WriteByte('"')
+		}
+		n++
+	}
+	b.WriteByte('}')
+	return []byte(b.String())
+}
+
+// storageOf is the storage type the schema declares for the field name; text when the field
+// is unknown or declares no kind.
+func storageOf(schema []model.Field, name string) model.FieldType {
+	for _, f := range schema {
+		if f.Name == name && f.Type != nil {
+			return f.Type.Storage()
+		}
+	}
+	return model.FieldText
+}
+
+// bare reports whether a query value may be written as a JSON literal for a field of type
+// t: a number for a number field, true or false for a bool one. Nothing else is ever bare —
+// the check is also what keeps a value like 1,"admin":true out of the JSON.
+func bare(v string, t model.FieldType) bool {
+	switch t {
+	case model.FieldBool:
+		return v == "true" || v == "false"
+	case model.FieldInt:
+		_, err := fmt.Convert(v).Int64()
+		return v != "" && err == nil
+	case model.FieldFloat:
+		_, err := fmt.Convert(v).Float64()
+		return v != "" && err == nil && isNumeral(v)
+	}
+	return false
+}
+
+// isNumeral reports whether v is written the way JSON writes a number: digits, a sign, a
+// point, an exponent. A parser may also accept "NaN" or "Inf", which JSON does not.
+func isNumeral(v string) bool {
+	for i := 0; i < len(v); i++ {
+		c := v[i]
+		if !('0' <= c && c <= '9') && c != '-' && c != '+' && c != '.' && c != 'e' && c != 'E' {
+			return false
+		}
+	}
+	return true
+}
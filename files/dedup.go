@@ -0,0 +1,129 @@
+package files
+
+import (
+	"github.com/tinywasm/filetype"
+	"github.com/tinywasm/goflare/internal/errs"
+	"github.com/tinywasm/goflare/log"
+	"github.com/tinywasm/goflare/r2"
+	"github.com/tinywasm/goflare/webcrypto"
+	"github.com/tinywasm/router"
+)
+
+// Refs keeps who holds each file of a content-addressed Store: the same bytes uploaded by
+// two callers are one object, and deleting it for one must not break it for the other.
+// D1Refs keeps them in D1; an app may keep them its own way.
+type Refs interface {
+	// Ref records one more reference of owner's to key.
+	Ref(key, owner string) error
+	// Holds reports whether owner holds at least one reference to key.
+	Holds(key, owner string) (bool, error)
+	// Unref drops one reference of owner's to key, if it has any, and returns how many
+	// references anybody still holds: at zero the object may go.
+	Unref(key, owner string) (remaining int64, err error)
+}
+
+// ContentAddressed keys every upload by the SHA-256 of its bytes (crypto.subtle at the edge),
+// hex, plus the extension: the same logo uploaded 500 times is one object under one key, and
+// that key names those bytes forever, so it is cached as immutable like a minted one.
+// Uploading bytes that are already stored writes nothing and answers 200 with the key, where
+// a first upload answers 201.
+//
+// With refs, each upload records a reference of its sender's, and the delete route drops the
+// caller's reference, deleting the object only with the last one; listing still shows a file
+// to the caller who stored it first. Without refs nobody can tell whether a file is still
+// used, so no delete route is mounted.
+//
+// The hash needs the whole file, so uploads are buffered (up to MaxSize) and no multipart
+// routes are mounted. It and PerOwner exclude each other: the one called last wins.
+func (s *Store) ContentAddressed(refs Refs) *Store {
+	s.addressed, s.refs = true, refs
+	s.perOwner = false
+	return s
+}
+
+// uploadAddressed stores an upload that was validated and transformed under the hash of its
+// bytes, unless they are stored already. Only a first upload keeps its form fields.
+func (s *Store) uploadAddressed(ctx router.Context, t filetype.Type, data []byte, fields map[string]string) {
+	sum, err := webcrypto.SHA256(data)
+	if err != nil {
+		log.Fail(500, ctx.Method(), ctx.Path(), err)
+		ctx.WriteStatus(500)
+		return
+	}
+	key := webcrypto.Hex(sum) + t.Ext
+	owner := ctx.UserID()
+
+	// Every caller is charged for what it references, stored or found: deleting gives it back.
+	size := int64(len(data))
+	if !s.reserve(ctx, size, 1) {
+		return
+	}
+	// The reference goes first, so a delete that counts references after it keeps the
+	// object. One that counted just before can still remove the object this upload finds; the
+	// next upload of the same bytes stores it again.
+	if s.refs != nil {
+		if err := s.refs.Ref(key, owner); err != nil {
+			s.unreserve(ctx, size, 1)
+			fail(ctx, err)
+			return
+		}
+	}
+	created, err := s.putOnce(key, data, s.putOptions(ctx, t.MIME, fields))
+	if err != nil {
+		if s.refs != nil {
+			if _, uerr := s.refs.Unref(key, owner); uerr != nil {
+				log.Fail(502, ctx.Method(), ctx.Path(), uerr)
+			}
+		}
+		s.unreserve(ctx, size, 1)
+		fail(ctx, err)
+		return
+	}
+
+	if created {
+		ctx.WriteStatus(201)
+	} else {
+		ctx.WriteStatus(200)
+	}
+	ctx.Write([]byte(key))
+}
+
+// putOnce stores data under key unless an object is there already, reporting whether it
+// wrote. A bucket that takes conditions decides it in the write itself.
+func (s *Store) putOnce(key string, data []byte, opts r2.PutOptions) (bool, error) {
+	if mb, ok := s.bucket.(ManagedBucket); ok {
+		opts.OnlyIf = r2.Conditions{EtagDoesNotMatch: "*"}
+		_, err := mb.PutWith(key, data, opts)
+		if errs.Is(err, r2.ErrPreconditionFailed) {
+			return false, nil
+		}
+		return err == nil, err
+	}
+	if h, ok := s.bucket.(headBucket); ok {
+		if _, err := h.Head(key); err == nil {
+			return false, nil
+		}
+	}
+	return true, s.bucket.Put(key, data, opts.ContentType)
+}
+
+// removeAddressed is the delete route of a content-addressed Store: the caller's reference
+// goes, and the object with the last one. owns has already checked the caller holds one.
+func (s *Store) removeAddressed(ctx router.Context, b ManagedBucket, info r2.ObjectInfo) {
+	remaining, err := s.refs.Unref(info.Key, ctx.UserID())
+	if err != nil {
+		fail(ctx, err)
+		return
+	}
+	if remaining == 0 {
+		if err := b.Delete(info.Key); err != nil {
+			fail(ctx, err)
+			return
+		}
+	}
+	if err := s.release(ctx, info.Size, 1); err != nil {
+		fail(ctx, err)
+		return
+	}
+	ctx.WriteStatus(204)
+}
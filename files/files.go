@@ -1,5 +1,3 @@
-//go:build wasm
-
 // Package files serves an object bucket over a route prefix.
 //
 // It exists so that every module that accepts user uploads gets the same three
@@ -12,6 +10,7 @@ import (
 	"github.com/tinywasm/filetype"
 	"github.com/tinywasm/fmt"
 	"github.com/tinywasm/goflare/log"
+	"github.com/tinywasm/goflare/r2"
 	"github.com/tinywasm/model"
 	"github.com/tinywasm/router"
 	"github.com/tinywasm/unixid"
@@ -25,6 +24,10 @@ const (
 	headerContentType   = "Content-Type"
 	headerNoSniff       = "X-Content-Type-Options"
 	valueNoSniff        = "nosniff"
+
+	// sniffLen is how much of a streamed upload is read to judge its type: the magic bytes
+	// of every allowed format sit well inside it.
+	sniffLen = 512
 )
 
 // Bucket is the storage this package needs. r2.Bucket satisfies it.
@@ -33,6 +36,32 @@ type Bucket interface {
 	Get(key string) (data []byte, contentType string, err error)
 }
 
+// StreamBucket is a Bucket that can pipe a body instead of holding it. r2.Bucket is one.
+// With it, and a Context that can stream (the edge's, devserver's, edgetest's), neither an
+// upload nor a download passes through memory whole, so MaxSize can go past the 128 MB a
+// Worker could ever buffer.
+type StreamBucket interface {
+	Bucket
+	PutStream(key string, body r2.Stream, size int64, opts r2.PutOptions) (r2.ObjectInfo, error)
+	GetStream(key string, cond r2.Conditions) (r2.Stream, r2.ObjectInfo, error)
+}
+
+// RangeBucket is a StreamBucket that can read part of an object and describe one without
+// reading it. r2.Bucket is one. With it, a conditional request is answered 304 and a Range
+// request 206 without reading a byte more than the response carries.
+type RangeBucket interface {
+	StreamBucket
+	Head(key string) (r2.ObjectInfo, error)
+	GetRange(key string, offset, length int64, cond r2.Conditions) (r2.Stream, r2.ObjectInfo, error)
+}
+
+// streamer is a router.Context that hands over its request body unread and answers with a
+// stream.
+type streamer interface {
+	BodyStream() r2.Stream
+	WriteStream(body r2.Stream)
+}
+
 // Store registers the upload and serve handlers for one bucket under one prefix.
 type Store struct {
 	bucket   Bucket
@@ -41,6 +70,17 @@ type Store struct {
 	prefix   string
 	maxSize  int
 	perOwner bool
+
+	// secretVar names the secret signed URLs are keyed with; empty means serving is public.
+	secretVar  string
+	cache      string
+	transforms []Transform
+	quota      Quota
+	counter    Counter
+	fileField  string
+	keepFields []string
+	addressed  bool
+	refs       Refs
 }
 
 // New builds a Store on the safe defaults: raster images only, 10 MiB.
@@ -57,11 +97,12 @@ func New(b Bucket, prefix string) (*Store, error) {
 		return nil, fmt.Err("files: id generator:", err.Error())
 	}
 	return &Store{
-		bucket:  b,
-		ids:     ids,
-		allow:   filetype.Images,
-		prefix:  prefix,
-		maxSize: DefaultMaxSize,
+		bucket:    b,
+		ids:       ids,
+		allow:     filetype.Images,
+		prefix:    prefix,
+		maxSize:   DefaultMaxSize,
+		fileField: DefaultFileField,
 	}, nil
 }
 
@@ -90,6 +131,17 @@ func (s *Store) MaxSize(n int) *Store {
 // identity is never empty by construction.
 func (s *Store) PerOwner() *Store {
 	s.perOwner = true
+	s.addressed = false
+	return s
+}
+
+// CacheControl sets the Cache-Control every served file is answered with. Without it the
+// policy follows the keys: a minted key never changes content, so it is cached for a year
+// ("public, max-age=31536000, immutable"); a PerOwner key is replaced in place, so it is
+// cached but revalidated every time ("no-cache") — which the ETag makes a cheap 304; a
+// Private file stays out of shared caches ("private, no-cache").
+func (s *Store) CacheControl(policy string) *Store {
+	s.cache = policy
 	return s
 }
 
@@ -103,50 +155,98 @@ const Resource model.Resource = "files"
 // permission nothing enforces.
 const Action = model.Create
 
-// Mount registers both routes: uploading requires the files/Create permission, serving is
-// public because an <img src> cannot send headers.
+// Mount registers both routes: uploading — a PUT of the raw file, or a POST of an HTML form
+// (FileField) — requires the files/Create permission; serving is public because an <img src>
+// cannot send headers — unless the Store is Private, in which
+// case serving takes a signed URL and the sign route is mounted too. A bucket that can list
+// and delete (r2.Bucket can) also gets the caller's listing, behind files/Read, and deletion,
+// behind files/Delete. One that takes multipart uploads (r2.Bucket does) also gets the
+// multipart routes under <prefix>uploads/, behind the same permission as a single upload —
+// unless the Store has a Transform or is ContentAddressed, which need each file whole.
 //
 // The upload is guarded on purpose. Do NOT make it public to "fix" a 403: a write-open
 // bucket is a spam form. If uploads are rejected, the app has not told the router who the
 // caller is (edge.Config.Authn) or who may write (edge.Config.Authorize).
 func (s *Store) Mount(r router.Router) {
 	r.Put(s.prefix, s.upload).Requires(Resource, Action)
+	r.Post(s.prefix, s.upload).Requires(Resource, Action) // what an HTML form sends
 	r.Get(s.prefix, s.serve).Public()
+	if s.secretVar != "" {
+		s.mountSign(r)
+	}
+	if mb, ok := s.bucket.(ManagedBucket); ok {
+		s.mountManage(r, mb)
+	}
+	if mb, ok := s.bucket.(MultipartBucket); ok && len(s.transforms) == 0 && !s.addressed {
+		s.mountMultipart(r, mb)
+	}
 }
 
 func (s *Store) upload(ctx router.Context) {
+	// A form carries its file among boundaries and fields, so its body may be that much
+	// bigger than the file; the file itself is held to MaxSize once parsed out.
+	form := isForm(ctx.GetHeader(headerContentType))
+	limit := s.maxSize
+	if form {
+		limit += formOverhead
+	}
+
 	// Size first: Body() is lazy, so nothing has been buffered yet.
-	if n, ok := contentLength(ctx); ok && n > s.maxSize {
+	n, declared := contentLength(ctx)
+	if declared && n > limit {
 		log.Reject(413, ctx.Method(), ctx.Path(), "declared size exceeds the limit")
 		ctx.WriteStatus(413)
 		return
 	}
 
+	// A declared length is what lets the body be piped: R2 must know a stream's size up
+	// front, and it is the size the limit was just checked against. A transform needs the
+	// whole file, a form has to be parsed, and a content address hashes every byte, so with
+	// any of them the body is buffered.
+	if sb, ok := s.bucket.(StreamBucket); ok && declared && !form && len(s.transforms) == 0 && !s.addressed {
+		if sc, ok := ctx.(streamer); ok {
+			s.uploadStream(ctx, sb, sc, n)
+			return
+		}
+	}
+
 	data := ctx.Body()
-	if len(data) > s.maxSize {
+	if len(data) > limit {
 		log.Reject(413, ctx.Method(), ctx.Path(), "body exceeds the limit")
 		ctx.WriteStatus(413)
 		return
 	}
+	var fields map[string]string
+	if form {
+		var ok bool
+		if data, fields, ok = s.formFile(ctx, data); !ok {
+			return
+		}
+		if len(data) > s.maxSize {
+			log.Reject(413, ctx.Method(), ctx.Path(), "file exceeds the limit")
+			ctx.WriteStatus(413)
+			return
+		}
+	}
 
-	// The type comes from the bytes. The client's Content-Type is text it chose.
-	t, err := s.allow.Validate(data)
-	if err != nil {
-		// The reason names what the bytes actually were ("SVG is not allowed"), which is
-		// the difference between diagnosing an attack and staring at a 415.
-		log.Reject(415, ctx.Method(), ctx.Path(), err.Error())
-		ctx.WriteStatus(415)
-		ctx.Write([]byte(err.Error()))
+	t, ok := s.validate(ctx, data)
+	if !ok {
 		return
 	}
-
-	// The key comes from the server. The client's filename is text it chose.
-	key := s.ids.NewID() + t.Ext
-	if s.perOwner {
-		key = ctx.UserID()
+	if t, data, ok = s.transform(ctx, t, data); !ok {
+		return
 	}
-
-	if err := s.bucket.Put(key, data, t.MIME); err != nil {
+	if s.addressed {
+		s.uploadAddressed(ctx, t, data, fields)
+		return
+	}
+	key := s.key(ctx, t)
+	bytes, objects := s.footprint(key, int64(len(data)))
+	if !s.reserve(ctx, bytes, objects) {
+		return
+	}
+	if err := s.put(ctx, key, data, s.putOptions(ctx, t.MIME, fields)); err != nil {
+		s.unreserve(ctx, bytes, objects)
 		log.Fail(502, ctx.Method(), ctx.Path(), err)
 		ctx.WriteStatus(502)
 		return
@@ -156,26 +256,59 @@ func (s *Store) upload(ctx router.Context) {
 	ctx.Write([]byte(key)) // the client learns here where its file landed
 }
 
-func (s *Store) serve(ctx router.Context) {
-	key := ctx.Path()[len(s.prefix):]
-	if key == "" {
-		log.Reject(400, ctx.Method(), ctx.Path(), "no key in path")
+// uploadStream is upload for a body piped into the bucket: the type is judged on the first
+// bytes, and the rest flows through without being held. size is the declared length; a body
+// that turns out longer or shorter fails the write.
+func (s *Store) uploadStream(ctx router.Context, b StreamBucket, sc streamer, size int) {
+	head, body, err := r2.Peek(sc.BodyStream(), sniffLen)
+	if err != nil {
+		log.Reject(400, ctx.Method(), ctx.Path(), err.Error())
 		ctx.WriteStatus(400)
 		return
 	}
 
-	data, ct, err := s.bucket.Get(key)
-	if err != nil {
-		// A missing key and a broken bucket both land here, so the cause is the only way
-		// to tell "that file was never uploaded" from "R2 is down".
-		log.Reject(404, ctx.Method(), ctx.Path(), err.Error())
-		ctx.WriteStatus(404)
+	t, ok := s.validate(ctx, head)
+	if !ok {
 		return
 	}
+	key := s.key(ctx, t)
+	bytes, objects := s.footprint(key, int64(size))
+	if !s.reserve(ctx, bytes, objects) {
+		return
+	}
+	if _, err := b.PutStream(key, body, int64(size), s.putOptions(ctx, t.MIME, nil)); err != nil {
+		s.unreserve(ctx, bytes, objects)
+		log.Fail(502, ctx.Method(), ctx.Path(), err)
+		ctx.WriteStatus(502)
+		return
+	}
+
+	ctx.WriteStatus(201)
+	ctx.Write([]byte(key))
+}
+
+// validate deduces the type from the bytes and answers 415 when it is not allowed.
+func (s *Store) validate(ctx router.Context, data []byte) (filetype.Type, bool) {
+	// The type comes from the bytes. The client's Content-Type is text it chose.
+	t, err := s.allow.Validate(data)
+	if err != nil {
+		// The reason names what the bytes actually were ("SVG is not allowed"), which is
+		// the difference between diagnosing an attack and staring at a 415.
+		log.Reject(415, ctx.Method(), ctx.Path(), err.Error())
+		ctx.WriteStatus(415)
+		ctx.Write([]byte(err.Error()))
+		return t, false
+	}
+	return t, true
+}
 
-	ctx.SetHeader(headerContentType, ct)       // the type we deduced on upload
-	ctx.SetHeader(headerNoSniff, valueNoSniff) // the browser does not get to guess
-	ctx.Write(data)
+// key is where the upload lands. It comes from the server: the client's filename is text it
+// chose.
+func (s *Store) key(ctx router.Context, t filetype.Type) string {
+	if s.perOwner {
+		return ctx.UserID()
+	}
+	return s.ids.NewID() + t.Ext
 }
 
 // contentLength reports the declared body size. ok is false when the header is
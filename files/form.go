@@ -0,0 +1,218 @@
+package files
+
+import "github.com/tinywasm/fmt"
+
+// Form is a parsed multipart/form-data body: what a plain HTML form with
+// enctype="multipart/form-data" sends, no JavaScript involved.
+type Form struct {
+	Fields map[string]string // the text fields, by name; a repeated name keeps the first
+	Files  []FormFile
+}
+
+// FormFile is one file part. Filename and ContentType are what the browser sent — text the
+// client chose, like the raw upload's Content-Type: the Store judges the type from Data and
+// mints the key itself.
+type FormFile struct {
+	Field       string
+	Filename    string
+	ContentType string
+	Data        []byte
+}
+
+// File returns the first file sent under field.
+func (f *Form) File(field string) (FormFile, bool) {
+	for _, file := range f.Files {
+		if file.Field == field {
+			return file, true
+		}
+	}
+	return FormFile{}, false
+}
+
+// maxFormParts bounds the parts one body may have: a form with a file and a few fields needs
+// a handful, and a body of empty parts is only work for the parser.
+const maxFormParts = 64
+
+var errForm = fmt.Err("files: malformed multipart/form-data")
+
+// ParseForm parses a multipart/form-data body, given the request's Content-Type, which
+// carries the boundary. It holds no more than the body it is handed: the parts are slices of
+// it.
+func ParseForm(body []byte, contentType string) (*Form, error) {
+	boundary, ok := formBoundary(contentType)
+	if !ok {
+		return nil, fmt.Err("files: not multipart/form-data with a boundary:", contentType)
+	}
+	delim := "--" + boundary
+
+	// Anything before the first delimiter is preamble, and ignored.
+	i := indexOf(body, delim)
+	if i < 0 {
+		return nil, errForm
+	}
+	pos := i + len(delim)
+	f := &Form{Fields: map[string]string{}}
+	for n := 0; ; n++ {
+		if hasPrefix(body[pos:], "--") {
+			return f, nil // the closing delimiter; anything after it is epilogue
+		}
+		if n == maxFormParts || !hasPrefix(body[pos:], "\r\n") {
+			return nil, errForm
+		}
+		pos += 2
+		end := indexOf(body[pos:], "\r\n"+delim)
+		if end < 0 {
+			return nil, errForm // cut off before its closing delimiter
+		}
+		if err := f.addPart(body[pos : pos+end]); err != nil {
+			return nil, err
+		}
+		pos += end + 2 + len(delim)
+	}
+}
+
+// indexOf is the offset of the first sep in b, or -1. It reads b where it lies: a body of
+// megabytes is never copied into a string to be searched.
+func indexOf(b []byte, sep string) int {
+	if sep == "" {
+		return 0
+	}
+	for i := 0; i+len(sep) <= len(b); i++ {
+		if b[i] == sep[0] && hasPrefix(b[i:], sep) {
+			return i
+		}
+	}
+	return -1
+}
+
+// hasPrefix reports whether b begins with p.
+func hasPrefix(b []byte, p string) bool {
+	if len(b) < len(p) {
+		return false
+	}
+	for i := 0; i < len(p); i++ {
+		if b[i] != p[i] {
+			return false
+		}
+	}
+	return true
+}
+
+// addPart reads one part's headers and files its body as a field or a file.
+func (f *Form) addPart(part []byte) error {
+	split := indexOf(part, "\r\n\r\n")
+	if split < 0 {
+		return errForm
+	}
+	var name, filename, ctype string
+	var isFile bool
+	for _, line := range fmt.Split(string(part[:split]), "\r\n") {
+		colon := fmt.Index(line, ":")
+		if colon < 0 {
+			continue
+		}
+		value := fmt.Convert(line[colon+1:]).TrimSpace().String()
+		switch fmt.ToLower(fmt.Convert(line[:colon]).TrimSpace().String()) {
+		case "content-disposition":
+			name, _ = dispositionParam(value, "name")
+			filename, isFile = dispositionParam(value, "filename")
+		case "content-type":
+			ctype = value
+		}
+	}
+	if name == "" {
+		return errForm
+	}
+
+	data := part[split+4:]
+	if isFile {
+		f.Files = append(f.Files, FormFile{Field: name, Filename: filename, ContentType: ctype, Data: data})
+		return nil
+	}
+	if _, seen := f.Fields[name]; !seen {
+		f.Fields[name] = string(data)
+	}
+	return nil
+}
+
+// formBoundary returns the boundary parameter of a multipart/form-data Content-Type.
+func formBoundary(contentType string) (string, bool) {
+	parts := fmt.Split(contentType, ";")
+	if len(parts) < 2 || fmt.ToLower(fmt.Convert(parts[0]).TrimSpace().String()) != "multipart/form-data" {
+		return "", false
+	}
+	for _, p := range parts[1:] {
+		p = fmt.Convert(p).TrimSpace().String()
+		if len(p) > 9 && fmt.ToLower(p[:9]) == "boundary=" {
+			b := unquote(p[9:])
+			// RFC 2046 caps a boundary at 70 characters.
+			return b, b != "" && len(b) <= 70
+		}
+	}
+	return "", false
+}
+
+// isForm reports whether the request body is multipart/form-data.
+func isForm(contentType string) bool {
+	_, ok := formBoundary(contentType)
+	return ok
+}
+
+// dispositionParam returns a parameter of a Content-Disposition value — name="file" — and
+// whether it was present at all: a file part is told from a field by having a filename,
+// even an empty one.
+func dispositionParam(value, param string) (string, bool) {
+	for i := 0; i < len(value); {
+		// Each parameter follows a ";". A quoted value may itself contain ";", so the scan
+		// skips quoted strings instead of splitting on every ";".
+		semi := nextSemicolon(value, i)
+		if semi < 0 {
+			return "", false
+		}
+		rest := fmt.Convert(value[semi+1:]).TrimSpace().String()
+		eq := fmt.Index(rest, "=")
+		if eq > 0 && fmt.ToLower(fmt.Convert(rest[:eq]).TrimSpace().String()) == param {
+			v := rest[eq+1:]
+			if end := nextSemicolon(v, 0); end >= 0 {
+				v = v[:end]
+			}
+			return unquote(fmt.Convert(v).TrimSpace().String()), true
+		}
+		i = semi + 1
+	}
+	return "", false
+}
+
+// nextSemicolon is the index of the first ";" at or after from that is not inside a quoted
+// string, or -1.
+func nextSemicolon(s string, from int) int {
+	quoted := false
+	for i := from; i < len(s); i++ {
+		switch {
+		case s[i] == '\\' && quoted:
+			i++
+		case s[i] == '"':
+			quoted = !quoted
+		case s[i] == ';' && !quoted:
+			return i
+		}
+	}
+	return -1
+}
+
+// unquote strips the quotes of a quoted string and its backslash escapes; a token is returned
+// as it is.
+func unquote(s string) string {
+	if len(s) < 2 || s[0] != '"' || s[len(s)-1] != '"' {
+		return s
+	}
+	s = s[1 : len(s)-1]
+	out := make([]byte, 0, len(s))
+	for i := 0; i < len(s); i++ {
+		if s[i] == '\\' && i+1 < len(s) {
+			i++
+		}
+		out = append(out, s[i])
+	}
+	return string(out)
+}
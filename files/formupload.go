@@ -0,0 +1,78 @@
+package files
+
+import (
+	"github.com/tinywasm/router"
+)
+
+const (
+	// DefaultFileField is the form field a POSTed form carries its file in unless FileField
+	// says otherwise: <input type="file" name="file">.
+	DefaultFileField = "file"
+
+	// formOverhead is how much bigger than MaxSize a form body may be: boundaries, part
+	// headers and the text fields beside the file.
+	formOverhead = 64 << 10
+
+	// maxFieldLen caps a kept text field. R2 holds at most 2 KiB of custom metadata per
+	// object, shared by every key and value.
+	maxFieldLen = 256
+
+	// metaFieldPrefix namespaces the kept fields in the object's custom metadata, so no form
+	// can overwrite the owner.
+	metaFieldPrefix = "form-"
+)
+
+// FileField names the form field a POSTed multipart/form-data upload carries its file in.
+// Everything else about it is the upload it replaces: MaxSize is checked on the declared
+// length before a byte is read, the type is judged from the file's bytes — never from the
+// filename or the part's Content-Type — and the key is minted by the server.
+//
+//	<form method="post" action="/api/files/" enctype="multipart/form-data">
+//	  <input type="file" name="file"> <input name="caption"> <button>Upload</button>
+//	</form>
+//
+// A form is parsed whole in memory, so it is the way for what a Worker can hold; a bigger
+// file goes up as a raw PUT, which is piped, or in parts.
+func (s *Store) FileField(name string) *Store {
+	s.fileField = name
+	return s
+}
+
+// KeepFields stores the named text fields of a form upload beside the file, in its custom
+// metadata as "form-<name>" — a caption, an alt text. Fields not named are dropped: what is
+// stored is what the app asked for. A kept value longer than 256 bytes refuses the upload.
+func (s *Store) KeepFields(names ...string) *Store {
+	s.keepFields = append(s.keepFields, names...)
+	return s
+}
+
+// formFile parses a form body into its file and the fields to keep, answering 400 when
+// either is not what the Store expects.
+func (s *Store) formFile(ctx router.Context, body []byte) ([]byte, map[string]string, bool) {
+	f, err := ParseForm(body, ctx.GetHeader(headerContentType))
+	if err != nil {
+		reject(ctx, 400, err.Error())
+		return nil, nil, false
+	}
+	file, ok := f.File(s.fileField)
+	if !ok {
+		reject(ctx, 400, "no file in form field "+s.fileField)
+		return nil, nil, false
+	}
+	var kept map[string]string
+	for _, name := range s.keepFields {
+		v, ok := f.Fields[name]
+		if !ok {
+			continue
+		}
+		if len(v) > maxFieldLen {
+			reject(ctx, 400, "form field "+name+" is longer than 256 bytes")
+			return nil, nil, false
+		}
+		if kept == nil {
+			kept = map[string]string{}
+		}
+		kept[name] = v
+	}
+	return file.Data, kept, true
+}
@@ -0,0 +1,101 @@
+package files
+
+import "github.com/tinywasm/fmt"
+
+// HTTP dates — Last-Modified, If-Modified-Since, If-Range — are IMF-fixdate, always GMT:
+// "Sun, 06 Nov 1994 08:49:37 GMT". They are formatted and parsed by hand, on civil-calendar
+// arithmetic, so the wasm build carries no time package for one header.
+
+const (
+	weekdays = "SunMonTueWedThuFriSat"
+	months   = "JanFebMarAprMayJunJulAugSepOctNovDec"
+)
+
+// httpDate formats a Unix time in seconds as an IMF-fixdate.
+func httpDate(unix int64) string {
+	days, secs := unix/86400, unix%86400
+	if secs < 0 {
+		days, secs = days-1, secs+86400
+	}
+	y, m, d := civil(days)
+	wd := (days + 4) % 7 // 1970-01-01 was a Thursday
+	if wd < 0 {
+		wd += 7
+	}
+	return weekdays[wd*3:wd*3+3] + ", " + pad(d) + " " + months[(m-1)*3:m*3] + " " +
+		fmt.Convert(y).String() + " " + pad(secs/3600) + ":" + pad(secs/60%60) + ":" + pad(secs%60) + " GMT"
+}
+
+// parseHTTPDate reads an IMF-fixdate back into Unix seconds. The two obsolete formats HTTP
+// still allows a client to send are not read: ok is false, and the request is served as if it
+// carried no date — a full response instead of a 304, never a wrong 304.
+func parseHTTPDate(s string) (unix int64, ok bool) {
+	// "Sun, 06 Nov 1994 08:49:37 GMT"
+	//  0    5  8   12   17 20 23 26
+	if len(s) != 29 || s[3] != ',' || s[25:] != " GMT" || s[19] != ':' || s[22] != ':' {
+		return 0, false
+	}
+	m := int64(fmt.Index(months, s[8:11]))
+	if m < 0 || m%3 != 0 {
+		return 0, false
+	}
+	var f [5]int64
+	for i, span := range [5][2]int{{5, 7}, {12, 16}, {17, 19}, {20, 22}, {23, 25}} {
+		n, err := fmt.Convert(s[span[0]:span[1]]).Int64()
+		if err != nil || n < 0 {
+			return 0, false
+		}
+		f[i] = n
+	}
+	d, y, hh, mm, ss := f[0], f[1], f[2], f[3], f[4]
+	if d < 1 || d > 31 || hh > 23 || mm > 59 || ss > 60 {
+		return 0, false
+	}
+	return days(y, m/3+1, d)*86400 + hh*3600 + mm*60 + ss, true
+}
+
+// civil converts days since 1970-01-01 to a proleptic Gregorian year, month and day.
+func civil(z int64) (y, m, d int64) {
+	z += 719468
+	era := z / 146097
+	if z < 0 && z%146097 != 0 {
+		era--
+	}
+	doe := z - era*146097
+	yoe := (doe - doe/1460 + doe/36524 - doe/146096) / 365
+	doy := doe - (365*yoe + yoe/4 - yoe/100)
+	mp := (5*doy + 2) / 153
+	d = doy - (153*mp+2)/5 + 1
+	m = mp + 3
+	if m > 12 {
+		m -= 12
+	}
+	y = yoe + era*400
+	if m <= 2 {
+		y++
+	}
+	return y, m, d
+}
+
+// days is civil's inverse.
+func days(y, m, d int64) int64 {
+	if m <= 2 {
+		y--
+	}
+	era := y / 400
+	if y < 0 && y%400 != 0 {
+		era--
+	}
+	yoe := y - era*400
+	mp := (m + 9) % 12
+	doy := (153*mp+2)/5 + d - 1
+	doe := yoe*365 + yoe/4 - yoe/100 + doy
+	return era*146097 + doe - 719468
+}
+
+func pad(n int64) string {
+	if n < 10 {
+		return "0" + fmt.Convert(n).String()
+	}
+	return fmt.Convert(n).String()
+}
@@ -0,0 +1,155 @@
+package files
+
+import (
+	"github.com/tinywasm/fmt"
+	"github.com/tinywasm/goflare/internal/errs"
+	"github.com/tinywasm/goflare/r2"
+	"github.com/tinywasm/model"
+	"github.com/tinywasm/router"
+)
+
+// ManagedBucket is a Bucket whose objects carry metadata and can be listed and deleted.
+// r2.Bucket is one. With it, every upload records who sent it, and Mount adds the routes that
+// let that caller — and only that caller — list and delete what they uploaded.
+type ManagedBucket interface {
+	Bucket
+	PutWith(key string, data []byte, opts r2.PutOptions) (r2.ObjectInfo, error)
+	Head(key string) (r2.ObjectInfo, error)
+	Delete(key string) error
+	Each(opts r2.ListOptions, fn func(r2.ObjectInfo) error) error
+}
+
+// metaOwner is the custom metadata an upload records its sender's UserID under.
+const metaOwner = "owner"
+
+// mountManage registers listing and deletion, each behind its own action on the same
+// Resource: being allowed to upload is not being allowed to delete.
+//
+//	GET    <prefix without the slash>  the caller's files, one per line: key, size, type → 200
+//	DELETE <prefix><key>               delete one of the caller's files                 → 204
+//
+// Both see the caller's own files only — by the owner recorded at upload, or with PerOwner
+// by the key, which is the owner, or in a ContentAddressed Store by its Refs. Someone else's
+// file answers 404, exactly like a missing one. Files stored before owners were recorded
+// belong to nobody and are never listed.
+func (s *Store) mountManage(r router.Router, b ManagedBucket) {
+	r.Get(s.prefix[:len(s.prefix)-1], func(ctx router.Context) { s.list(ctx, b) }).Requires(Resource, model.Read)
+	if !s.addressed || s.refs != nil {
+		r.Delete(s.prefix, func(ctx router.Context) { s.remove(ctx, b) }).Requires(Resource, model.Delete)
+	}
+}
+
+func (s *Store) list(ctx router.Context, b ManagedBucket) {
+	uid := ctx.UserID()
+	var out []byte
+	line := func(o r2.ObjectInfo) {
+		out = append(out, o.Key+"\t"+fmt.Convert(o.Size).String()+"\t"+o.ContentType+"\n"...)
+	}
+
+	if s.perOwner {
+		// One object per identity, under the identity: nothing to scan.
+		o, err := b.Head(uid)
+		if err == nil {
+			line(o)
+		} else if !errs.Is(err, r2.ErrNotFound) {
+			fail(ctx, err)
+			return
+		}
+	} else {
+		// The owner is metadata, so this reads the whole listing. It pages through it —
+		// never more than one page in memory — but a bucket of millions is a job for D1.
+		err := b.Each(r2.ListOptions{}, func(o r2.ObjectInfo) error {
+			if o.Key[0] != '.' && o.CustomMetadata[metaOwner] == uid {
+				line(o)
+			}
+			return nil
+		})
+		if err != nil {
+			fail(ctx, err)
+			return
+		}
+	}
+
+	ctx.WriteStatus(200)
+	ctx.Write(out)
+}
+
+func (s *Store) remove(ctx router.Context, b ManagedBucket) {
+	key := ctx.Path()[len(s.prefix):]
+	if key == "" && s.perOwner {
+		key = ctx.UserID() // the caller's one object needs no name
+	}
+	if key == "" {
+		reject(ctx, 400, "no key in path")
+		return
+	}
+	info, ok := s.owns(ctx, b, key)
+	if !ok {
+		return
+	}
+	if s.addressed {
+		s.removeAddressed(ctx, b, info)
+		return
+	}
+	if err := b.Delete(key); err != nil {
+		fail(ctx, err)
+		return
+	}
+	if err := s.release(ctx, info.Size, 1); err != nil {
+		fail(ctx, err) // the file is gone; its bytes still count until an operator fixes the row
+		return
+	}
+	ctx.WriteStatus(204)
+}
+
+// owns reports whether key is an existing file of the caller's, and describes it; it answers
+// 404 when it is not. With no bucket to ask (nil b), only a PerOwner key can be judged: by
+// its name.
+func (s *Store) owns(ctx router.Context, b ManagedBucket, key string) (r2.ObjectInfo, bool) {
+	uid := ctx.UserID()
+	if key[0] == '.' || (s.perOwner && key != uid) {
+		reject(ctx, 404, "not a file of this caller")
+		return r2.ObjectInfo{}, false
+	}
+	if b == nil {
+		return r2.ObjectInfo{Key: key}, true
+	}
+	o, err := b.Head(key)
+	if err != nil {
+		reject(ctx, 404, err.Error())
+		return r2.ObjectInfo{}, false
+	}
+	mine := s.perOwner || o.CustomMetadata[metaOwner] == uid
+	if s.addressed && s.refs != nil {
+		// Shared bytes: whoever uploaded them holds a reference, not only the first sender.
+		if mine, err = s.refs.Holds(key, uid); err != nil {
+			fail(ctx, err)
+			return r2.ObjectInfo{}, false
+		}
+	}
+	if !mine {
+		reject(ctx, 404, "not a file of this caller")
+		return r2.ObjectInfo{}, false
+	}
+	return o, true
+}
+
+// put stores an upload with opts when the bucket can carry metadata, and with its type alone
+// when it cannot.
+func (s *Store) put(ctx router.Context, key string, data []byte, opts r2.PutOptions) error {
+	if mb, ok := s.bucket.(ManagedBucket); ok {
+		_, err := mb.PutWith(key, data, opts)
+		return err
+	}
+	return s.bucket.Put(key, data, opts.ContentType)
+}
+
+// putOptions is what every upload is stored with: the type deduced from the bytes, the
+// caller that sent them, and the form fields kept beside it (KeepFields).
+func (s *Store) putOptions(ctx router.Context, mime string, fields map[string]string) r2.PutOptions {
+	meta := map[string]string{metaOwner: ctx.UserID()}
+	for name, v := range fields {
+		meta[metaFieldPrefix+name] = v
+	}
+	return r2.PutOptions{ContentType: mime, CustomMetadata: meta}
+}
@@ -0,0 +1,390 @@
+package files
+
+import (
+	"github.com/tinywasm/filetype"
+	"github.com/tinywasm/fmt"
+	"github.com/tinywasm/goflare/log"
+	"github.com/tinywasm/goflare/r2"
+	"github.com/tinywasm/router"
+)
+
+// MultipartBucket is a bucket that also takes an object in parts, and can keep the small
+// records that tie those parts together across requests. r2.Bucket is one.
+type MultipartBucket interface {
+	StreamBucket
+	ManagedBucket
+	CreateMultipartUpload(key string, opts r2.PutOptions) (*r2.MultipartUpload, error)
+	ResumeMultipartUpload(key, uploadID string) *r2.MultipartUpload
+}
+
+const (
+	// uploadsPath hangs off the Store prefix: the multipart routes live under it.
+	uploadsPath = "uploads/"
+
+	// uploadsKey is where an upload in progress is recorded in the bucket itself — a Worker
+	// keeps nothing between requests. A stored key never starts with "." (the server mints
+	// them), so the records cannot collide with a file.
+	uploadsKey = ".uploads/"
+
+	// The initiating request announces what is coming — the names Google's resumable upload
+	// protocol uses. Both are checked then, and again against the bytes as they arrive.
+	headerUploadLength = "X-Upload-Content-Length"
+	headerUploadType   = "X-Upload-Content-Type"
+	headerETag         = "ETag"
+)
+
+// upload is one multipart upload in progress, as recorded under uploadsKey+token.
+type upload struct {
+	token string
+	key   string // where the object lands
+	id    string // R2's upload ID; never leaves the server
+	mime  string // the type announced, and checked against the first part
+	size  int    // the total announced, and checked against the parts
+	owner string // only the caller that started it may continue it
+
+	// countedBytes and countedObjects are what the upload was counted against its owner's
+	// Quota for when it started: what an abort gives back.
+	countedBytes, countedObjects int64
+}
+
+// part is one received part, as recorded under uploadsKey+token+"/"+n.
+type part struct {
+	n    int
+	etag string
+	size int
+}
+
+// mountMultipart registers the multipart routes, all behind the upload's own permission:
+//
+//	POST   <prefix>uploads/          start: X-Upload-Content-Length and -Type → 201, token
+//	PUT    <prefix>uploads/<token>/<n> send part n (from 1)                  → 200, ETag
+//	GET    <prefix>uploads/<token>   the part numbers received, one per line → 200
+//	POST   <prefix>uploads/<token>   complete                                → 201, key
+//	DELETE <prefix>uploads/<token>   abort                                   → 204
+//
+// A client that loses its connection asks which parts arrived and sends the rest: nothing
+// about the upload lives in the Worker, so any request may land on any instance.
+func (s *Store) mountMultipart(r router.Router, b MultipartBucket) {
+	p := s.prefix + uploadsPath
+	r.Post(p, func(ctx router.Context) { s.startOrComplete(ctx, b) }).Requires(Resource, Action)
+	r.Put(p, func(ctx router.Context) { s.uploadPart(ctx, b) }).Requires(Resource, Action)
+	r.Get(p, func(ctx router.Context) { s.uploadStatus(ctx, b) }).Requires(Resource, Action)
+	r.Delete(p, func(ctx router.Context) { s.abortUpload(ctx, b) }).Requires(Resource, Action)
+}
+
+func (s *Store) startOrComplete(ctx router.Context, b MultipartBucket) {
+	if s.uploadPathRest(ctx) == "" {
+		s.startUpload(ctx, b)
+		return
+	}
+	s.completeUpload(ctx, b)
+}
+
+func (s *Store) startUpload(ctx router.Context, b MultipartBucket) {
+	size, err := fmt.Convert(ctx.GetHeader(headerUploadLength)).Int()
+	if err != nil || size <= 0 {
+		reject(ctx, 400, headerUploadLength+" must be the total size in bytes")
+		return
+	}
+	if size > s.maxSize {
+		reject(ctx, 413, "announced size exceeds the limit")
+		return
+	}
+	t, ok := s.allowed(ctx.GetHeader(headerUploadType))
+	if !ok {
+		reject(ctx, 415, "announced type is not allowed: "+ctx.GetHeader(headerUploadType))
+		return
+	}
+
+	key := s.key(ctx, t)
+	bytes, objects := s.footprint(key, int64(size))
+	if !s.reserve(ctx, bytes, objects) {
+		return
+	}
+	mu, err := b.CreateMultipartUpload(key, s.putOptions(ctx, t.MIME, nil))
+	if err != nil {
+		s.unreserve(ctx, bytes, objects)
+		fail(ctx, err)
+		return
+	}
+	u := upload{token: s.ids.NewID(), key: key, id: mu.UploadID, mime: t.MIME, size: size, owner: ctx.UserID(),
+		countedBytes: bytes, countedObjects: objects}
+	if _, err := b.PutWith(uploadsKey+u.token, nil, r2.PutOptions{CustomMetadata: map[string]string{
+		"key": u.key, "upload": u.id, "type": u.mime, "size": fmt.Convert(u.size).String(), metaOwner: u.owner,
+		"counted": fmt.Convert(bytes).String() + "/" + fmt.Convert(objects).String(),
+	}}); err != nil {
+		mu.Abort()
+		s.unreserve(ctx, bytes, objects)
+		fail(ctx, err)
+		return
+	}
+
+	ctx.WriteStatus(201)
+	ctx.Write([]byte(u.token))
+}
+
+func (s *Store) uploadPart(ctx router.Context, b MultipartBucket) {
+	token, num := splitPart(s.uploadPathRest(ctx))
+	n, err := fmt.Convert(num).Int()
+	if token == "" || err != nil || n < 1 || n > r2.MaxParts {
+		reject(ctx, 400, "want uploads/<token>/<part number>")
+		return
+	}
+	u, ok := s.findUpload(ctx, b, token)
+	if !ok {
+		return
+	}
+	parts, err := listParts(b, token)
+	if err != nil {
+		fail(ctx, err)
+		return
+	}
+
+	// A declared length lets the part be piped, as a whole-file upload is.
+	size, declared := contentLength(ctx)
+	sc, canStream := ctx.(streamer)
+	buffered := !declared || !canStream
+	var data []byte
+	if buffered {
+		data = ctx.Body()
+		size = len(data)
+	}
+	// The total is checked across parts, not per part: what is already here counts.
+	received := size
+	for _, p := range parts {
+		if p.n != n {
+			received += p.size
+		}
+	}
+	if received > u.size {
+		reject(ctx, 413, "parts exceed the announced size")
+		return
+	}
+
+	mu := b.ResumeMultipartUpload(u.key, u.id)
+	var up r2.UploadedPart
+	if buffered {
+		if n == 1 && !s.firstPartMatches(ctx, u, data) {
+			return
+		}
+		up, err = mu.UploadPart(n, data)
+	} else {
+		head, body, perr := r2.Peek(sc.BodyStream(), sniffLen)
+		if perr != nil {
+			reject(ctx, 400, perr.Error())
+			return
+		}
+		if n == 1 && !s.firstPartMatches(ctx, u, head) {
+			return
+		}
+		up, err = mu.UploadPartStream(n, body, int64(size))
+	}
+	if err != nil {
+		fail(ctx, err)
+		return
+	}
+
+	if _, err := b.PutWith(partKey(token, n), nil, r2.PutOptions{CustomMetadata: map[string]string{
+		"etag": up.ETag, "size": fmt.Convert(size).String(),
+	}}); err != nil {
+		fail(ctx, err)
+		return
+	}
+	ctx.SetHeader(headerETag, up.ETag)
+	ctx.WriteStatus(200)
+}
+
+func (s *Store) uploadStatus(ctx router.Context, b MultipartBucket) {
+	token := s.uploadPathRest(ctx)
+	if _, ok := s.findUpload(ctx, b, token); !ok {
+		return
+	}
+	parts, err := listParts(b, token)
+	if err != nil {
+		fail(ctx, err)
+		return
+	}
+	var out []byte
+	for _, p := range parts {
+		out = append(out, fmt.Convert(p.n).String()+"\n"...)
+	}
+	ctx.WriteStatus(200)
+	ctx.Write(out)
+}
+
+func (s *Store) completeUpload(ctx router.Context, b MultipartBucket) {
+	token := s.uploadPathRest(ctx)
+	u, ok := s.findUpload(ctx, b, token)
+	if !ok {
+		return
+	}
+	parts, err := listParts(b, token)
+	if err != nil {
+		fail(ctx, err)
+		return
+	}
+
+	// Checked here, with a reason the client can act on, rather than left to R2's refusal.
+	total := 0
+	uploaded := make([]r2.UploadedPart, len(parts))
+	for i, p := range parts {
+		if p.n != i+1 {
+			reject(ctx, 400, "part "+fmt.Convert(i+1).String()+" is missing")
+			return
+		}
+		if i < len(parts)-1 && (p.size < r2.MinPartSize || p.size != parts[0].size) {
+			reject(ctx, 400, "every part but the last must be the same size, at least 5 MiB")
+			return
+		}
+		total += p.size
+		uploaded[i] = r2.UploadedPart{PartNumber: p.n, ETag: p.etag}
+	}
+	if total != u.size {
+		reject(ctx, 400, "received "+fmt.Convert(total).String()+" of "+fmt.Convert(u.size).String()+" bytes")
+		return
+	}
+
+	if _, err := b.ResumeMultipartUpload(u.key, u.id).Complete(uploaded); err != nil {
+		fail(ctx, err)
+		return
+	}
+	forgetUpload(b, token, parts)
+
+	ctx.WriteStatus(201)
+	ctx.Write([]byte(u.key))
+}
+
+func (s *Store) abortUpload(ctx router.Context, b MultipartBucket) {
+	token := s.uploadPathRest(ctx)
+	u, ok := s.findUpload(ctx, b, token)
+	if !ok {
+		return
+	}
+	parts, err := listParts(b, token)
+	if err != nil {
+		fail(ctx, err)
+		return
+	}
+	if err := b.ResumeMultipartUpload(u.key, u.id).Abort(); err != nil {
+		fail(ctx, err)
+		return
+	}
+	forgetUpload(b, token, parts)
+	if err := s.release(ctx, u.countedBytes, u.countedObjects); err != nil {
+		fail(ctx, err)
+		return
+	}
+	ctx.WriteStatus(204)
+}
+
+// findUpload loads the upload the token names. Someone else's upload is answered exactly
+// like a missing one: a token is not a secret worth confirming.
+func (s *Store) findUpload(ctx router.Context, b MultipartBucket, token string) (upload, bool) {
+	if token == "" || fmt.Contains(token, "/") {
+		reject(ctx, 400, "want uploads/<token>")
+		return upload{}, false
+	}
+	info, err := b.Head(uploadsKey + token)
+	m := info.CustomMetadata
+	if err != nil || m[metaOwner] != ctx.UserID() {
+		reason := "no upload " + token + " for this caller"
+		if err != nil {
+			reason = err.Error()
+		}
+		reject(ctx, 404, reason)
+		return upload{}, false
+	}
+	size, _ := fmt.Convert(m["size"]).Int()
+	u := upload{token: token, key: m["key"], id: m["upload"], mime: m["type"], size: size, owner: m[metaOwner]}
+	if c := m["counted"]; c != "" {
+		if i := fmt.Index(c, "/"); i > 0 {
+			u.countedBytes, _ = fmt.Convert(c[:i]).Int64()
+			u.countedObjects, _ = fmt.Convert(c[i+1:]).Int64()
+		}
+	}
+	return u, true
+}
+
+// firstPartMatches holds the first part to the rule every upload follows: the type comes
+// from the bytes, and here it must also be the type announced when the upload started.
+func (s *Store) firstPartMatches(ctx router.Context, u upload, head []byte) bool {
+	t, ok := s.validate(ctx, head)
+	if !ok {
+		return false
+	}
+	if t.MIME != u.mime {
+		reject(ctx, 415, "announced "+u.mime+" but the bytes are "+t.MIME)
+		return false
+	}
+	return true
+}
+
+// allowed finds the allowed type with this MIME.
+func (s *Store) allowed(mime string) (filetype.Type, bool) {
+	for _, t := range s.allow {
+		if mime != "" && t.MIME == mime {
+			return t, true
+		}
+	}
+	return filetype.Type{}, false
+}
+
+// uploadPathRest is what follows <prefix>uploads/ in the path.
+func (s *Store) uploadPathRest(ctx router.Context) string {
+	return ctx.Path()[len(s.prefix)+len(uploadsPath):]
+}
+
+// listParts returns the parts received so far, by part number.
+func listParts(b MultipartBucket, token string) ([]part, error) {
+	var parts []part
+	err := b.Each(r2.ListOptions{Prefix: uploadsKey + token + "/"}, func(o r2.ObjectInfo) error {
+		_, num := splitPart(o.Key[len(uploadsKey):])
+		n, err := fmt.Convert(num).Int()
+		if err != nil {
+			return nil // not a part record
+		}
+		size, _ := fmt.Convert(o.CustomMetadata["size"]).Int()
+		parts = append(parts, part{n: n, etag: o.CustomMetadata["etag"], size: size})
+		return nil
+	})
+	// Keys list in string order ("10" before "2"); parts are wanted by number.
+	for i := 1; i < len(parts); i++ {
+		for j := i; j > 0 && parts[j].n < parts[j-1].n; j-- {
+			parts[j], parts[j-1] = parts[j-1], parts[j]
+		}
+	}
+	return parts, err
+}
+
+// forgetUpload deletes the records of a finished upload. A record left behind by a failed
+// delete is harmless: its token can no longer complete anything.
+func forgetUpload(b MultipartBucket, token string, parts []part) {
+	for _, p := range parts {
+		b.Delete(partKey(token, p.n))
+	}
+	b.Delete(uploadsKey + token)
+}
+
+func partKey(token string, n int) string {
+	return uploadsKey + token + "/" + fmt.Convert(n).String()
+}
+
+// splitPart splits "<token>/<n>".
+func splitPart(rest string) (token, n string) {
+	for i := 0; i < len(rest); i++ {
+		if rest[i] == '/' {
+			return rest[:i], rest[i+1:]
+		}
+	}
+	return rest, ""
+}
+
+func reject(ctx router.Context, status int, reason string) {
+	log.Reject(status, ctx.Method(), ctx.Path(), reason)
+	ctx.WriteStatus(status)
+}
+
+func fail(ctx router.Context, err error) {
+	log.Fail(502, ctx.Method(), ctx.Path(), err)
+	ctx.WriteStatus(502)
+}
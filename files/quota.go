@@ -0,0 +1,99 @@
+package files
+
+import (
+	"github.com/tinywasm/fmt"
+	"github.com/tinywasm/goflare/log"
+	"github.com/tinywasm/router"
+)
+
+// Quota caps what one owner — one UserID — may keep in a Store. A zero field is no cap.
+type Quota struct {
+	Bytes   int64
+	Objects int64
+}
+
+// Counter keeps each owner's usage where every Worker instance sees it. D1Counter is the only
+// one goflare ships; KV is not supported.
+type Counter interface {
+	// Add changes owner's usage by bytes and objects — negative to release — unless the
+	// result would exceed q, in which case it changes nothing and reports false. Check and
+	// change must be one step: two uploads racing for the last free byte must not both fit.
+	// A release passes a zero Quota and always fits.
+	//
+	// KV cannot do that step — it has no compare-and-set — so a KV Counter an app writes
+	// itself lets a burst of concurrent uploads overshoot; it still bounds an account that
+	// uploads one at a time.
+	Add(owner string, bytes, objects int64, q Quota) (bool, error)
+}
+
+// Quota makes every upload count against its sender's q in c, and every delete give it back.
+// An upload that would exceed it is refused with 507 before a byte is stored; a multipart
+// upload is counted when it starts, for the size it announces, and given back if aborted.
+// With PerOwner a replacement counts only what it adds over the file it replaces.
+//
+// Files stored before the quota was set, or removed from the bucket other than through the
+// delete route, are not in the counter: set the quota before the first upload, and delete
+// through the Store.
+func (s *Store) Quota(q Quota, c Counter) *Store {
+	s.quota, s.counter = q, c
+	return s
+}
+
+// footprint is what storing size bytes under key adds to the caller's usage. Only a PerOwner
+// key can already hold a file of the same owner, which the new one replaces.
+func (s *Store) footprint(key string, size int64) (bytes, objects int64) {
+	if s.perOwner {
+		if h, ok := s.bucket.(headBucket); ok {
+			if old, err := h.Head(key); err == nil {
+				return size - old.Size, 0
+			}
+		}
+	}
+	return size, 1
+}
+
+// reserve counts bytes and objects against the caller's quota, answering 507 when they do not
+// fit and 502 when the counter cannot say.
+func (s *Store) reserve(ctx router.Context, bytes, objects int64) bool {
+	if s.counter == nil {
+		return true
+	}
+	owner := ctx.UserID()
+	ok, err := s.counter.Add(owner, bytes, objects, s.quota)
+	if err != nil {
+		fail(ctx, err)
+		return false
+	}
+	if !ok {
+		// The reason is the operator's evidence when a user reports "my upload fails", and the
+		// first line to read when one account is filling the bucket.
+		reject(ctx, 507, "quota of "+owner+" exceeded: "+fmt.Convert(bytes).String()+
+			" more bytes do not fit in "+limit(s.quota.Bytes)+" bytes, "+limit(s.quota.Objects)+" files")
+		return false
+	}
+	return true
+}
+
+// release gives bytes and objects back to the caller's quota.
+func (s *Store) release(ctx router.Context, bytes, objects int64) error {
+	if s.counter == nil {
+		return nil
+	}
+	_, err := s.counter.Add(ctx.UserID(), -bytes, -objects, Quota{})
+	return err
+}
+
+// unreserve is release on a path that already failed: the counter's error can only be logged,
+// and the usage stays over-counted — the owner gets less room, never more.
+func (s *Store) unreserve(ctx router.Context, bytes, objects int64) {
+	if err := s.release(ctx, bytes, objects); err != nil {
+		log.Fail(502, ctx.Method(), ctx.Path(), fmt.Errf("files: quota not released: %s", err.Error()))
+	}
+}
+
+func limit(n int64) string {
+	if n == 0 {
+		return "unlimited"
+	}
+	return fmt.Convert(n).String()
+}
@@ -0,0 +1,42 @@
+package files
+
+import (
+	"github.com/tinywasm/goflare/d1"
+	"github.com/tinywasm/orm"
+)
+
+// UsageSchema creates the table D1Counter keeps usage in. Put it in a migration:
+//
+//	goflare d1 migrate create files_usage   # then paste UsageSchema into the .sql file
+const UsageSchema = `CREATE TABLE IF NOT EXISTS files_usage (
+	owner   TEXT PRIMARY KEY,
+	bytes   INTEGER NOT NULL DEFAULT 0,
+	objects INTEGER NOT NULL DEFAULT 0
+);`
+
+// D1Counter is a Counter on a D1 table (UsageSchema). Each Add is one statement, so the
+// check and the change cannot be split by a concurrent upload on another instance.
+type D1Counter struct {
+	db *orm.DB
+}
+
+// NewD1Counter keeps usage in db, which must have the files_usage table.
+func NewD1Counter(db *orm.DB) *D1Counter {
+	return &D1Counter{db: db}
+}
+
+// Add implements Counter with one upsert: a first upload inserts the row, a later one updates
+// it only where the WHERE clause — the quota — still holds. Nothing changed means over quota.
+func (c *D1Counter) Add(owner string, bytes, objects int64, q Quota) (bool, error) {
+	if (q.Bytes > 0 && bytes > q.Bytes) || (q.Objects > 0 && objects > q.Objects) {
+		return false, nil // does not fit even an empty account; the insert would not check
+	}
+	res, err := d1.Exec(c.db, `INSERT INTO files_usage (owner, bytes, objects) VALUES (?1, MAX(?2, 0), MAX(?3, 0))
+		ON CONFLICT(owner) DO UPDATE SET bytes = MAX(bytes + ?2, 0), objects = MAX(objects + ?3, 0)
+		WHERE (?4 = 0 OR bytes + ?2 <= ?4) AND (?5 = 0 OR objects + ?3 <= ?5)`,
+		owner, bytes, objects, q.Bytes, q.Objects)
+	if err != nil {
+		return false, err
+	}
+	return res.Changes == 1, nil
+}
@@ -0,0 +1,60 @@
+package files
+
+import (
+	"github.com/tinywasm/goflare/d1"
+	"github.com/tinywasm/orm"
+)
+
+// RefsSchema creates the table D1Refs keeps references in. Put it in a migration, like
+// UsageSchema.
+const RefsSchema = `CREATE TABLE IF NOT EXISTS files_refs (
+	object TEXT NOT NULL,
+	owner  TEXT NOT NULL,
+	n      INTEGER NOT NULL,
+	PRIMARY KEY (object, owner)
+);`
+
+// D1Refs is a Refs on a D1 table (RefsSchema): one row per file and owner, counting how many
+// times that owner uploaded it.
+type D1Refs struct {
+	db *orm.DB
+}
+
+// NewD1Refs keeps references in db, which must have the files_refs table.
+func NewD1Refs(db *orm.DB) *D1Refs {
+	return &D1Refs{db: db}
+}
+
+// Ref implements Refs with one upsert.
+func (r *D1Refs) Ref(key, owner string) error {
+	_, err := d1.Exec(r.db, `INSERT INTO files_refs (object, owner, n) VALUES (?1, ?2, 1)
+		ON CONFLICT(object, owner) DO UPDATE SET n = n + 1`, key, owner)
+	return err
+}
+
+// Holds implements Refs.
+func (r *D1Refs) Holds(key, owner string) (bool, error) {
+	var n int64
+	if err := r.db.RawConn().QueryRow(`SELECT COUNT(*) FROM files_refs WHERE object = ?1 AND owner = ?2`,
+		key, owner).Scan(&n); err != nil {
+		return false, err
+	}
+	return n > 0, nil
+}
+
+// Unref implements Refs: the decrement and the removal of an emptied row are one batch, the
+// count that follows a separate read.
+func (r *D1Refs) Unref(key, owner string) (int64, error) {
+	if _, err := d1.Batch(r.db,
+		d1.Statement(`UPDATE files_refs SET n = n - 1 WHERE object = ?1 AND owner = ?2`, key, owner),
+		d1.Statement(`DELETE FROM files_refs WHERE object = ?1 AND n <= 0`, key),
+	); err != nil {
+		return 0, err
+	}
+	var n int64
+	if err := r.db.RawConn().QueryRow(`SELECT COALESCE(SUM(n), 0) FROM files_refs WHERE object = ?1`,
+		key).Scan(&n); err != nil {
+		return 0, err
+	}
+	return n, nil
+}
@@ -0,0 +1,305 @@
+package files
+
+import (
+	"github.com/tinywasm/fmt"
+	"github.com/tinywasm/goflare/log"
+	"github.com/tinywasm/goflare/r2"
+	"github.com/tinywasm/router"
+)
+
+const (
+	headerCacheControl    = "Cache-Control"
+	headerLastModified    = "Last-Modified"
+	headerAcceptRanges    = "Accept-Ranges"
+	headerContentRange    = "Content-Range"
+	headerRange           = "Range"
+	headerIfRange         = "If-Range"
+	headerIfNoneMatch     = "If-None-Match"
+	headerIfModifiedSince = "If-Modified-Since"
+
+	cacheImmutable  = "public, max-age=31536000, immutable"
+	cacheRevalidate = "no-cache"
+	cachePrivate    = "private, no-cache"
+)
+
+// headBucket is a Bucket that can describe an object without reading it. r2.Bucket is one.
+type headBucket interface {
+	Head(key string) (r2.ObjectInfo, error)
+}
+
+func (s *Store) serve(ctx router.Context) {
+	key := ctx.Path()[len(s.prefix):]
+	if key == "" {
+		log.Reject(400, ctx.Method(), ctx.Path(), "no key in path")
+		ctx.WriteStatus(400)
+		return
+	}
+	if key[0] == '.' {
+		// Never minted for a file: the bucket's own records (multipart uploads in progress).
+		log.Reject(404, ctx.Method(), ctx.Path(), "not a file key")
+		ctx.WriteStatus(404)
+		return
+	}
+	if s.secretVar != "" && !s.signed(ctx, key) {
+		return
+	}
+
+	if sb, ok := s.bucket.(StreamBucket); ok {
+		if sc, ok := ctx.(streamer); ok {
+			if rb, ok := sb.(RangeBucket); ok && checked(ctx) {
+				s.serveChecked(ctx, rb, sc, key)
+				return
+			}
+			s.serveStream(ctx, sb, sc, key)
+			return
+		}
+	}
+	s.serveBuffered(ctx, key)
+}
+
+// serveStream is serve for an object piped from the bucket to the client.
+func (s *Store) serveStream(ctx router.Context, b StreamBucket, sc streamer, key string) {
+	body, info, err := b.GetStream(key, r2.Conditions{})
+	if err != nil {
+		log.Reject(404, ctx.Method(), ctx.Path(), err.Error())
+		ctx.WriteStatus(404)
+		return
+	}
+
+	_, ranges := b.(RangeBucket)
+	s.headers(ctx, info, ranges)
+	ctx.SetHeader(headerContentLength, fmt.Convert(info.Size).String())
+	sc.WriteStream(body)
+}
+
+// serveChecked answers a request that carries validators or a Range. The object is described
+// first, so a 304 or a 416 costs no read at all, and then read — whole or the one range —
+// pinned to the ETag just judged: an object replaced in between fails instead of answering
+// bytes of one version under the validators of another.
+func (s *Store) serveChecked(ctx router.Context, b RangeBucket, sc streamer, key string) {
+	info, err := b.Head(key)
+	if err != nil {
+		log.Reject(404, ctx.Method(), ctx.Path(), err.Error())
+		ctx.WriteStatus(404)
+		return
+	}
+	s.headers(ctx, info, true)
+	if notModified(ctx, info) {
+		ctx.WriteStatus(304)
+		return
+	}
+
+	pin := r2.Conditions{EtagMatches: info.ETag}
+	start, n, status := byteRange(ctx, info)
+	var body r2.Stream
+	switch status {
+	case 416:
+		unsatisfiable(ctx, info.Size)
+		return
+	case 206:
+		body, _, err = b.GetRange(key, start, n, pin)
+	default:
+		body, _, err = b.GetStream(key, pin)
+		n = info.Size
+	}
+	if err != nil {
+		// Gone or replaced since the Head: the client retries and gets the new state.
+		log.Reject(503, ctx.Method(), ctx.Path(), err.Error())
+		ctx.WriteStatus(503)
+		return
+	}
+	if status == 206 {
+		ctx.SetHeader(headerContentRange, contentRange(start, n, info.Size))
+		ctx.WriteStatus(206)
+	}
+	ctx.SetHeader(headerContentLength, fmt.Convert(n).String())
+	sc.WriteStream(body)
+}
+
+// serveBuffered is serve for a bucket or a context that cannot stream: the object is read
+// whole, and a range is cut from it in memory.
+func (s *Store) serveBuffered(ctx router.Context, key string) {
+	var info r2.ObjectInfo
+	if h, ok := s.bucket.(headBucket); ok {
+		var err error
+		if info, err = h.Head(key); err != nil {
+			log.Reject(404, ctx.Method(), ctx.Path(), err.Error())
+			ctx.WriteStatus(404)
+			return
+		}
+		if notModified(ctx, info) {
+			s.headers(ctx, info, true)
+			ctx.WriteStatus(304)
+			return
+		}
+	}
+
+	data, ct, err := s.bucket.Get(key)
+	if err != nil {
+		// A missing key and a broken bucket both land here, so the cause is the only way
+		// to tell "that file was never uploaded" from "R2 is down".
+		log.Reject(404, ctx.Method(), ctx.Path(), err.Error())
+		ctx.WriteStatus(404)
+		return
+	}
+
+	info.ContentType = ct // the type we deduced on upload
+	info.Size = int64(len(data))
+	s.headers(ctx, info, true)
+	start, n, status := byteRange(ctx, info)
+	switch status {
+	case 416:
+		unsatisfiable(ctx, info.Size)
+	case 206:
+		ctx.SetHeader(headerContentRange, contentRange(start, n, info.Size))
+		ctx.WriteStatus(206)
+		ctx.Write(data[start : start+n])
+	default:
+		ctx.Write(data)
+	}
+}
+
+// headers sets what every answer about an object carries, a 304 included: its type, its
+// validators and the cache policy.
+func (s *Store) headers(ctx router.Context, info r2.ObjectInfo, ranges bool) {
+	ctx.SetHeader(headerContentType, info.ContentType) // the type we deduced on upload
+	ctx.SetHeader(headerNoSniff, valueNoSniff)         // the browser does not get to guess
+	ctx.SetHeader(headerCacheControl, s.cachePolicy())
+	if info.ETag != "" {
+		ctx.SetHeader(headerETag, `"`+info.ETag+`"`)
+	}
+	if info.Uploaded > 0 {
+		ctx.SetHeader(headerLastModified, httpDate(info.Uploaded/1000))
+	}
+	if ranges {
+		ctx.SetHeader(headerAcceptRanges, "bytes")
+	}
+}
+
+func (s *Store) cachePolicy() string {
+	switch {
+	case s.cache != "":
+		return s.cache
+	case s.secretVar != "":
+		return cachePrivate
+	case s.perOwner:
+		return cacheRevalidate
+	default:
+		return cacheImmutable
+	}
+}
+
+// checked reports whether the request carries anything that needs the object's metadata
+// before its body: validators to answer 304 to, or a Range.
+func checked(ctx router.Context) bool {
+	return ctx.GetHeader(headerRange) != "" || ctx.GetHeader(headerIfNoneMatch) != "" ||
+		ctx.GetHeader(headerIfModifiedSince) != ""
+}
+
+// notModified reports whether the client's copy is current. If-None-Match wins over
+// If-Modified-Since when both are sent, as HTTP requires: the ETag is exact, the date is to
+// the second.
+func notModified(ctx router.Context, info r2.ObjectInfo) bool {
+	if inm := ctx.GetHeader(headerIfNoneMatch); inm != "" {
+		return info.ETag != "" && etagListed(inm, info.ETag)
+	}
+	if ims, ok := parseHTTPDate(ctx.GetHeader(headerIfModifiedSince)); ok && info.Uploaded > 0 {
+		return info.Uploaded/1000 <= ims
+	}
+	return false
+}
+
+// etagListed reports whether an If-None-Match list names etag. The comparison is the weak one
+// HTTP prescribes for it: W/"x" names "x".
+func etagListed(list, etag string) bool {
+	for _, tag := range fmt.Split(list, ",") {
+		tag = fmt.Convert(tag).TrimSpace().String()
+		if tag == "*" {
+			return true
+		}
+		if len(tag) > 2 && tag[:2] == "W/" {
+			tag = tag[2:]
+		}
+		if tag == `"`+etag+`"` {
+			return true
+		}
+	}
+	return false
+}
+
+// byteRange resolves the request's Range against the object info describes. status is 206 with
+// the one range to send, 416 when it lies wholly past the end, or 200 to send everything: no
+// Range, an If-Range that no longer holds, several ranges, or one this does not parse — HTTP
+// allows answering any Range with the whole object, and that is never wrong.
+func byteRange(ctx router.Context, info r2.ObjectInfo) (start, n int64, status int) {
+	size := info.Size
+	spec := ctx.GetHeader(headerRange)
+	if spec == "" || len(spec) < 6 || spec[:6] != "bytes=" || fmt.Contains(spec, ",") {
+		return 0, 0, 200
+	}
+	if ir := ctx.GetHeader(headerIfRange); ir != "" && !rangeCurrent(ir, info) {
+		return 0, 0, 200
+	}
+	r := spec[6:]
+	dash := fmt.Index(r, "-")
+	if dash < 0 {
+		return 0, 0, 200
+	}
+	first, last := r[:dash], r[dash+1:]
+
+	if first == "" { // "-500": the last 500 bytes
+		suffix, err := fmt.Convert(last).Int64()
+		if err != nil || suffix < 0 {
+			return 0, 0, 200
+		}
+		if suffix == 0 || size == 0 {
+			return 0, 0, 416
+		}
+		if suffix > size {
+			suffix = size
+		}
+		return size - suffix, suffix, 206
+	}
+
+	start, err := fmt.Convert(first).Int64()
+	if err != nil || start < 0 {
+		return 0, 0, 200
+	}
+	if start >= size {
+		return 0, 0, 416
+	}
+	end := size - 1
+	if last != "" {
+		e, err := fmt.Convert(last).Int64()
+		if err != nil || e < start {
+			return 0, 0, 200
+		}
+		if e < end {
+			end = e
+		}
+	}
+	return start, end - start + 1, 206
+}
+
+// rangeCurrent reports whether If-Range still names the object the client holds part of: a
+// strong ETag equal to the current one, or the exact Last-Modified. Anything else means the
+// parts would not fit together, so the whole object is sent instead.
+func rangeCurrent(ir string, info r2.ObjectInfo) bool {
+	if ir[0] == '"' {
+		return info.ETag != "" && ir == `"`+info.ETag+`"`
+	}
+	t, ok := parseHTTPDate(ir)
+	return ok && info.Uploaded > 0 && t == info.Uploaded/1000
+}
+
+// unsatisfiable answers 416, telling the client how big the object really is.
+func unsatisfiable(ctx router.Context, size int64) {
+	ctx.SetHeader(headerContentRange, "bytes */"+fmt.Convert(size).String())
+	log.Reject(416, ctx.Method(), ctx.Path(), "range outside the object")
+	ctx.WriteStatus(416)
+}
+
+func contentRange(start, n, size int64) string {
+	return "bytes " + fmt.Convert(start).String() + "-" + fmt.Convert(start+n-1).String() + "/" +
+		fmt.Convert(size).String()
+}
@@ -0,0 +1,151 @@
+package files
+
+import (
+	"github.com/tinywasm/fmt"
+	"github.com/tinywasm/goflare/cloudflare"
+	"github.com/tinywasm/goflare/internal/clock"
+	"github.com/tinywasm/goflare/internal/query"
+	"github.com/tinywasm/goflare/log"
+	"github.com/tinywasm/goflare/webcrypto"
+	"github.com/tinywasm/model"
+	"github.com/tinywasm/router"
+)
+
+// DefaultURLTTL is how long, in seconds, a URL minted by the sign route stays valid.
+const DefaultURLTTL = 5 * 60
+
+const (
+	// signPath is where the sign route hangs off the prefix. It starts with ".", so it can
+	// never be the key of a file.
+	signPath = ".sign/"
+
+	paramExpires = "exp"
+	paramUser    = "uid"
+	paramSig     = "sig"
+	paramBind    = "bind"
+)
+
+// Private makes serving require a signed URL: one minted by SignURL or the sign route, not
+// expired, and — when it was bound to a caller — requested by that caller. Anything else is
+// a 403. An unguessable key is not access control: keys end up in logs, in Referer headers,
+// in a forwarded email. Invoices and ID documents belong behind Private.
+//
+// secretVar names the secret the signatures are keyed with, read through cloudflare.Env on
+// every request — set it with `wrangler secret put` at the edge and in .dev.vars locally.
+// If it is unset when a request arrives, serving answers 500: an unsigned fallback would make
+// every private file public the day a deploy forgets the secret.
+//
+// Private also mounts the sign route, GET <prefix>.sign/<key>, behind files/Read: it answers
+// a URL for one of the caller's own files, valid for DefaultURLTTL seconds. With ?bind=1 the
+// URL works only for that caller — which needs the identity to travel without headers, i.e.
+// a session cookie.
+func (s *Store) Private(secretVar string) *Store {
+	s.secretVar = secretVar
+	return s
+}
+
+// SignURL returns the path that serves key until ttl seconds from now: the prefix, the key
+// and the signature in the query. A non-empty userID binds the URL to that caller.
+//
+// It checks nothing about who asks: call it from a handler that already decided the caller may
+// read key. The sign route is that handler for the caller's own files.
+func (s *Store) SignURL(key string, ttl int64, userID string) (string, error) {
+	if s.secretVar == "" {
+		return "", fmt.Err("files: SignURL on a Store that is not Private")
+	}
+	exp := fmt.Convert(clock.Now() + ttl).String()
+	sig, err := s.signature(key, exp, userID)
+	if err != nil {
+		return "", err
+	}
+	q := paramExpires + "=" + exp
+	if userID != "" {
+		q += "&" + paramUser + "=" + query.Escape(userID)
+	}
+	return s.prefix + key + "?" + q + "&" + paramSig + "=" + webcrypto.Hex(sig), nil
+}
+
+// signature is the HMAC over everything a URL grants: which object, until when, and to whom.
+// The fields are NUL-separated, so no key can be shifted into the expiry or the user.
+func (s *Store) signature(key, exp, userID string) ([]byte, error) {
+	secret := cloudflare.Env(s.secretVar)
+	if secret == "" {
+		return nil, fmt.Errf("files: secret %s is not set", s.secretVar)
+	}
+	return webcrypto.HMAC([]byte(secret), []byte(key+"\x00"+exp+"\x00"+userID))
+}
+
+// signed reports whether the request carries a valid signature for key, answering 403 (or
+// 500, for a missing secret) when it does not.
+func (s *Store) signed(ctx router.Context, key string) bool {
+	var q string
+	if qc, ok := ctx.(query.Querier); ok {
+		q = qc.Query()
+	}
+	exp, uid, sig := query.Param(q, paramExpires), query.Param(q, paramUser), query.Param(q, paramSig)
+
+	got, ok := webcrypto.Unhex(sig)
+	if !ok || exp == "" {
+		reject(ctx, 403, "private file requested without a signature")
+		return false
+	}
+	want, err := s.signature(key, exp, uid)
+	if err != nil {
+		log.Fail(500, ctx.Method(), ctx.Path(), err)
+		ctx.WriteStatus(500)
+		return false
+	}
+	if !webcrypto.Equal(got, want) {
+		reject(ctx, 403, "signature does not match")
+		return false
+	}
+	// Only now are exp and uid the server's own words.
+	if until, err := fmt.Convert(exp).Int64(); err != nil || until < clock.Now() {
+		reject(ctx, 403, "signed URL expired")
+		return false
+	}
+	if uid != "" && uid != ctx.UserID() {
+		reject(ctx, 403, "signed URL bound to another caller")
+		return false
+	}
+	return true
+}
+
+// mountSign registers the sign route. It hands out URLs for the caller's own files only: with
+// PerOwner the caller's key, otherwise — when the bucket records owners — a file they
+// uploaded. A bucket that records no owner leaves the decision to files/Read alone.
+func (s *Store) mountSign(r router.Router) {
+	r.Get(s.prefix+signPath, s.sign).Requires(Resource, model.Read)
+}
+
+func (s *Store) sign(ctx router.Context) {
+	key := ctx.Path()[len(s.prefix)+len(signPath):]
+	if key == "" && s.perOwner {
+		key = ctx.UserID()
+	}
+	if key == "" {
+		reject(ctx, 400, "no key in path")
+		return
+	}
+	if mb, _ := s.bucket.(ManagedBucket); mb != nil || s.perOwner {
+		if _, ok := s.owns(ctx, mb, key); !ok {
+			return
+		}
+	} else if key[0] == '.' {
+		reject(ctx, 404, "not a file key")
+		return
+	}
+
+	var bind string
+	if qc, ok := ctx.(query.Querier); ok && query.Param(qc.Query(), paramBind) == "1" {
+		bind = ctx.UserID()
+	}
+	url, err := s.SignURL(key, DefaultURLTTL, bind)
+	if err != nil {
+		log.Fail(500, ctx.Method(), ctx.Path(), err)
+		ctx.WriteStatus(500)
+		return
+	}
+	ctx.WriteStatus(200)
+	ctx.Write([]byte(url))
+}
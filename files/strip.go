@@ -0,0 +1,164 @@
+package files
+
+import (
+	"github.com/tinywasm/filetype"
+	"github.com/tinywasm/fmt"
+)
+
+// Strip removes the metadata a photo carries beside its pixels: EXIF (GPS coordinates, the
+// camera's serial number, the time it was taken), XMP, IPTC and comments from a JPEG; text,
+// EXIF and timestamp chunks from a PNG; EXIF and XMP from a WebP. The image data is copied
+// byte for byte — nothing is decoded or re-encoded, so quality is untouched and the cost is
+// one pass over the upload.
+//
+// What the image needs to be displayed right stays: JFIF, the ICC colour profile, Adobe's
+// colour transform. The EXIF orientation goes with the rest of EXIF, so a phone photo taken
+// sideways is stored sideways; a Transform that rotates it first is the fix.
+//
+// Any other type is returned unchanged. A file whose structure does not parse is an error:
+// storing it as uploaded would keep whatever it hides.
+func Strip(t filetype.Type, data []byte) ([]byte, error) {
+	switch t.MIME {
+	case "image/jpeg":
+		return stripJPEG(data)
+	case "image/png":
+		return stripPNG(data)
+	case "image/webp":
+		return stripWebP(data)
+	}
+	return data, nil
+}
+
+var errMalformed = fmt.Err("files: malformed image")
+
+// stripJPEG copies the segments before the image data except the metadata ones, then the
+// image data as is. A segment is FF, a marker, and — for all but the standalone markers — a
+// big-endian length that counts itself.
+func stripJPEG(data []byte) ([]byte, error) {
+	if len(data) < 4 || data[0] != 0xFF || data[1] != 0xD8 {
+		return nil, errMalformed
+	}
+	out := make([]byte, 0, len(data))
+	out = append(out, 0xFF, 0xD8)
+	i := 2
+	for {
+		if i+1 >= len(data) || data[i] != 0xFF {
+			return nil, errMalformed
+		}
+		marker := data[i+1]
+		if marker == 0xFF { // fill byte before a marker
+			i++
+			continue
+		}
+		if marker == 0x01 || (marker >= 0xD0 && marker <= 0xD7) {
+			out = append(out, data[i:i+2]...)
+			i += 2
+			continue
+		}
+		if marker == 0xD9 { // end of image with no scan: nothing left to copy
+			return append(out, 0xFF, 0xD9), nil
+		}
+		if i+4 > len(data) {
+			return nil, errMalformed
+		}
+		n := int(data[i+2])<<8 | int(data[i+3])
+		end := i + 2 + n
+		if n < 2 || end > len(data) {
+			return nil, errMalformed
+		}
+		if marker == 0xDA { // start of scan: the entropy-coded image follows, to the end
+			return append(out, data[i:]...), nil
+		}
+		if keepJPEG(marker, data[i+4:end]) {
+			out = append(out, data[i:end]...)
+		}
+		i = end
+	}
+}
+
+// keepJPEG reports whether a segment is needed to show the image. APP0 (JFIF) and APP14
+// (Adobe) say how to read the pixels; APP2 is kept only as the ICC profile, which says what
+// colours they are. Every other APPn — APP1 is EXIF and XMP, APP13 is IPTC — and the COM
+// comment are metadata.
+func keepJPEG(marker byte, payload []byte) bool {
+	switch {
+	case marker == 0xE0 || marker == 0xEE:
+		return true
+	case marker == 0xE2:
+		return len(payload) >= 12 && string(payload[:12]) == "ICC_PROFILE\x00"
+	case marker >= 0xE1 && marker <= 0xEF, marker == 0xFE:
+		return false
+	}
+	return true
+}
+
+// pngSignature opens every PNG.
+const pngSignature = "\x89PNG\r\n\x1a\n"
+
+// stripPNG copies every chunk up to IEND except the text ones (tEXt, zTXt, iTXt), eXIf and
+// tIME. A chunk is a big-endian length, a four-letter type, the data and a CRC; removing one
+// whole leaves every other CRC valid.
+func stripPNG(data []byte) ([]byte, error) {
+	if len(data) < 8 || string(data[:8]) != pngSignature {
+		return nil, errMalformed
+	}
+	out := make([]byte, 0, len(data))
+	out = append(out, data[:8]...)
+	i := 8
+	for {
+		if i+8 > len(data) {
+			return nil, errMalformed
+		}
+		n := int(data[i])<<24 | int(data[i+1])<<16 | int(data[i+2])<<8 | int(data[i+3])
+		end := i + 12 + n
+		if n < 0 || end > len(data) || end < i {
+			return nil, errMalformed
+		}
+		switch string(data[i+4 : i+8]) {
+		case "tEXt", "zTXt", "iTXt", "eXIf", "tIME":
+		default:
+			out = append(out, data[i:end]...)
+		}
+		if string(data[i+4:i+8]) == "IEND" {
+			return out, nil
+		}
+		i = end
+	}
+}
+
+// stripWebP copies every chunk of the RIFF container except EXIF and "XMP ", clears the
+// flags in VP8X that announced them, and rewrites the RIFF size. A chunk is a four-letter
+// type, a little-endian size and the data, padded to an even length.
+func stripWebP(data []byte) ([]byte, error) {
+	if len(data) < 12 || string(data[:4]) != "RIFF" || string(data[8:12]) != "WEBP" {
+		return nil, errMalformed
+	}
+	out := make([]byte, 0, len(data))
+	out = append(out, data[:12]...)
+	i := 12
+	for i < len(data) {
+		if i+8 > len(data) {
+			return nil, errMalformed
+		}
+		n := int(data[i+4]) | int(data[i+5])<<8 | int(data[i+6])<<16 | int(data[i+7])<<24
+		end := i + 8 + n + n&1
+		if n < 0 || end > len(data) || end < i {
+			return nil, errMalformed
+		}
+		switch string(data[i : i+4]) {
+		case "EXIF", "XMP ":
+		case "VP8X":
+			start := len(out)
+			out = append(out, data[i:end]...)
+			if n > 0 {
+				out[start+8] &^= 0x08 | 0x04 // the EXIF and XMP flags
+			}
+		default:
+			out = append(out, data[i:end]...)
+		}
+		i = end
+	}
+	size := len(out) - 8
+	out[4], out[5], out[6], out[7] = byte(size), byte(size>>8), byte(size>>16), byte(size>>24)
+	return out, nil
+}
@@ -0,0 +1,53 @@
+package files
+
+import (
+	"github.com/tinywasm/filetype"
+	"github.com/tinywasm/goflare/log"
+	"github.com/tinywasm/router"
+)
+
+// Transform rewrites an upload after its type is validated and before it is stored: strip
+// metadata, resize, re-encode, watermark. It gets the type judged from the bytes and returns
+// the bytes to store; an error refuses the upload (422) with its message.
+//
+// What it returns is judged again against Allow, so a transform may change the type — a PNG
+// re-encoded as JPEG is stored as JPEG, under a .jpg key — but never into one the Store
+// does not accept.
+type Transform func(t filetype.Type, data []byte) ([]byte, error)
+
+// StripMetadata makes every upload pass through Strip: EXIF, XMP, IPTC and the like never
+// reach the bucket. Opt in for anything a person photographs — avatars above all, where GPS
+// coordinates in a public file are a home address.
+func (s *Store) StripMetadata() *Store {
+	return s.Transform(Strip)
+}
+
+// Transform adds fn to the stages every upload goes through, in the order they were added.
+//
+// A transform needs the whole file, so a Store with any buffers each upload, up to MaxSize,
+// instead of piping it — keep MaxSize within what a Worker can hold — and mounts no multipart
+// routes: their parts are never one file in the Worker.
+func (s *Store) Transform(fn Transform) *Store {
+	s.transforms = append(s.transforms, fn)
+	return s
+}
+
+// transform runs the stages over data and judges the result, answering 422 or 415 when it
+// is refused.
+func (s *Store) transform(ctx router.Context, t filetype.Type, data []byte) (filetype.Type, []byte, bool) {
+	if len(s.transforms) == 0 {
+		return t, data, true
+	}
+	for _, fn := range s.transforms {
+		out, err := fn(t, data)
+		if err != nil {
+			log.Reject(422, ctx.Method(), ctx.Path(), err.Error())
+			ctx.WriteStatus(422)
+			ctx.Write([]byte(err.Error()))
+			return t, nil, false
+		}
+		data = out
+	}
+	t, ok := s.validate(ctx, data)
+	return t, data, ok
+}
@@ -16,11 +16,17 @@ require (
 	github.com/tinywasm/storage v0.0.2
 	github.com/tinywasm/tinygo v1.0.0
 	lukechampine.com/blake3 v1.4.1
+	modernc.org/sqlite v1.38.2
 )
 
 require (
 	github.com/HugoSmits86/nativewebp v1.2.1 // indirect
 	github.com/disintegration/imaging v1.6.2 // indirect
+	github.com/dustin/go-humanize v1.0.1 // indirect
+	github.com/google/uuid v1.6.0 // indirect
+	github.com/mattn/go-isatty v0.0.20 // indirect
+	github.com/ncruces/go-strftime v0.1.9 // indirect
+	github.com/remyoudompheng/bigfft v0.0.0-20230129092748-24d4a6f8daec // indirect
 	github.com/smallstep/truststore v0.13.0 // indirect
 	github.com/tinywasm/color v0.1.1 // indirect
 	github.com/tinywasm/font v0.0.4 // indirect
@@ -28,10 +34,15 @@ require (
 	github.com/tinywasm/js v0.0.4 // indirect
 	github.com/tinywasm/modfind v0.0.4 // indirect
 	golang.org/x/crypto v0.53.0 // indirect
+	golang.org/x/exp v0.0.0-20250620022241-b7579e27df2b // indirect
 	golang.org/x/image v0.45.0 // indirect
 	golang.org/x/net v0.55.0 // indirect
+	golang.org/x/sys v0.47.0 // indirect
 	golang.org/x/text v0.41.0 // indirect
 	howett.net/plist v1.0.0 // indirect
+	modernc.org/libc v1.66.3 // indirect
+	modernc.org/mathutil v1.7.1 // indirect
+	modernc.org/memory v1.11.0 // indirect
 )
 
 require (
@@ -54,10 +54,23 @@ type Config struct {
 	// Compiler
 	CompilerMode string // "S" | "M" | "L"  default: "S"
 
-	D1DatabaseID   string // D1_DATABASE_ID
-	D1DatabaseName string // D1_DATABASE_NAME — optional, default: ProjectName
-	R2BucketID     string // R2_BUCKET_ID
-	R2BucketName   string // R2_BUCKET_NAME
+	D1DatabaseID    string // D1_DATABASE_ID
+	D1DatabaseName  string // D1_DATABASE_NAME — optional, default: ProjectName
+	R2BucketID      string // R2_BUCKET_ID
+	R2BucketName    string // R2_BUCKET_NAME
+	KVNamespaceID   string // KV_NAMESPACE_ID
+	KVNamespaceName string // KV_NAMESPACE_NAME — binding name, default: "KV"
+
+	// Provision: deploy creates the configured D1, R2 and KV resources that do not exist.
+	Provision bool // PROVISION=true
+
+	// Migrations
+	MigrationsDir   string // D1_MIGRATIONS_DIR — default: "migrations"
+	RequireMigrated bool   // D1_REQUIRE_MIGRATED=true — deploy refuses while migrations are pending
+
+	// Backups
+	BackupDir           string // D1_BACKUP_DIR — where d1 export and pre-migrate backups go; default: ".goflare/backups"
+	BackupBeforeMigrate bool   // D1_BACKUP_BEFORE_MIGRATE=true — d1 migrate up exports the database first
 }
 
 type Goflare struct {
@@ -0,0 +1,22 @@
+//go:build !wasm
+
+// Package clock is the current time for code that runs at the edge, where package time
+// stays out of the binary.
+package clock
+
+import (
+	"sync/atomic"
+	"time"
+)
+
+// skew is how far Advance has moved the clock, in seconds.
+var skew atomic.Int64
+
+// Now is the current Unix time in seconds.
+func Now() int64 {
+	return time.Now().Unix() + skew.Load()
+}
+
+// Advance moves Now forward by seconds (back, when negative), so a test can expire a
+// session or a signed URL without sleeping. There is no edge counterpart.
+func Advance(seconds int64) { skew.Add(seconds) }
@@ -0,0 +1,27 @@
+// Package errs is the part of the errors package goflare's wasm code needs, without the
+// errors package: the wasm build keeps it out of its binary.
+package errs
+
+// Is is errors.Is: whether err, or any error it wraps, is target. It follows both
+// Unwrap() error and the Unwrap() []error of a joined error.
+func Is(err, target error) bool {
+	for err != nil {
+		if err == target {
+			return true
+		}
+		switch u := err.(type) {
+		case interface{ Unwrap() []error }:
+			for _, e := range u.Unwrap() {
+				if Is(e, target) {
+					return true
+				}
+			}
+			return false
+		case interface{ Unwrap() error }:
+			err = u.Unwrap()
+		default:
+			return false
+		}
+	}
+	return false
+}
@@ -0,0 +1,69 @@
+// Package query reads and writes URL query strings for the wasm build, which keeps net/url
+// out of its binary.
+package query
+
+import "github.com/tinywasm/fmt"
+
+// Querier is a router.Context that knows its raw query string ("a=1&b=2", no "?"). Every
+// goflare context does; router.Context does not promise it, so it is asked for.
+type Querier interface {
+	Query() string
+}
+
+// Param returns the unescaped value of name in the raw query q, or "" when it is absent.
+func Param(q, name string) string {
+	for _, pair := range fmt.Split(q, "&") {
+		if len(pair) > len(name) && pair[:len(name)] == name && pair[len(name)] == '=' {
+			return Unescape(pair[len(name)+1:])
+		}
+	}
+	return ""
+}
+
+// Escape percent-encodes everything but the characters a query value can carry as they are.
+func Escape(s string) string {
+	out := make([]byte, 0, len(s))
+	for i := 0; i < len(s); i++ {
+		c := s[i]
+		if ('a' <= c && c <= 'z') || ('A' <= c && c <= 'Z') || ('0' <= c && c <= '9') ||
+			c == '-' || c == '_' || c == '.' || c == '~' {
+			out = append(out, c)
+			continue
+		}
+		out = append(out, '%', "0123456789ABCDEF"[c>>4], "0123456789ABCDEF"[c&0x0f])
+	}
+	return string(out)
+}
+
+// Unescape decodes the percent-encoding of a query component, "+" included. A "%" not
+// followed by two hex digits is kept as it is.
+func Unescape(s string) string {
+	out := make([]byte, 0, len(s))
+	for i := 0; i < len(s); i++ {
+		switch c := s[i]; {
+		case c == '+':
+			out = append(out, ' ')
+		case c == '%' && i+2 < len(s) && isHex(s[i+1]) && isHex(s[i+2]):
+			out = append(out, unhex(s[i+1])<<4|unhex(s[i+2]))
+			i += 2
+		default:
+			out = append(out, c)
+		}
+	}
+	return string(out)
+}
+
+func isHex(c byte) bool {
+	return ('0' <= c && c <= '9') || ('a' <= c && c <= 'f') || ('A' <= c && c <= 'F')
+}
+
+func unhex(c byte) byte {
+	switch {
+	case '0' <= c && c <= '9':
+		return c - '0'
+	case 'a' <= c && c <= 'f':
+		return c - 'a' + 10
+	default:
+		return c - 'A' + 10
+	}
+}
@@ -0,0 +1,11 @@
+//go:build !wasm
+
+package log
+
+import "os"
+
+// write prints the line to stderr, tagged with the console level it would have had at the
+// edge.
+func write(level, line string) {
+	os.Stderr.WriteString(level + " " + line + "\n")
+}
@@ -0,0 +1,11 @@
+//go:build wasm
+
+package log
+
+import "syscall/js"
+
+// write hands the line to the Workers console, which is what `wrangler tail` and the
+// dashboard's real-time logs read.
+func write(level, line string) {
+	js.Global().Get("console").Call(level, line)
+}
@@ -1,5 +1,3 @@
-//go:build wasm
-
 // Package log is the minimum an edge app must report to be operable.
 //
 // The rule it enforces: no response of 400 or worse leaves the Worker without a line
@@ -10,13 +8,14 @@
 // every request with its method, path and status, so an access log here would only
 // duplicate it, cost CPU on the hot path and bury the lines that matter.
 //
+// The same lines are written natively — to stderr instead of the Workers console — so an edge
+// pipeline driven by a test or the dev server reports exactly what it would in production.
+//
 // Never pass a request body, a header or a cookie to these functions. Logs are read by
 // people and shipped to third parties; a body can carry credentials or personal data.
 package log
 
 import (
-	"syscall/js"
-
 	"github.com/tinywasm/fmt"
 )
 
@@ -49,7 +48,15 @@ func Panic(method, path string, v any) {
 	console("error", "500", method, path, "panic: "+fmt.Convert(v).String())
 }
 
+// Slow records an operation that ran past its budget — a D1 query, say. It is the one line
+// here not tied to a failure: a request can succeed and still be slow enough to matter, and
+// the request log never says which query made it so. detail names the operation (the SQL,
+// never its arguments).
+func Slow(what string, ms, budget int64, detail string) {
+	write("warn", prefix+" slow "+what+" "+fmt.Convert(ms).String()+"ms (budget "+
+		fmt.Convert(budget).String()+"ms) — "+detail)
+}
+
 func console(level string, status, method, path, detail string) {
-	js.Global().Get("console").Call(level,
-		prefix+" "+status+" "+method+" "+path+" — "+detail)
+	write(level, prefix+" "+status+" "+method+" "+path+" — "+detail)
 }
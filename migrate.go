@@ -0,0 +1,141 @@
+//go:build !wasm
+
+package goflare
+
+import (
+	"fmt"
+	"io"
+
+	"github.com/tinywasm/goflare/cloudflare"
+	"github.com/tinywasm/goflare/d1"
+	"github.com/tinywasm/orm"
+)
+
+// d1Binding is the D1 binding name the deploy declares, and so the name the local database
+// is stored under.
+func (c *Config) d1Binding() string {
+	if c.D1DatabaseName != "" {
+		return c.D1DatabaseName
+	}
+	return cloudflare.DefaultD1Binding
+}
+
+// OpenD1 opens the project's database: the deployed one over the API when remote is set,
+// otherwise the local stand-in `goflare dev` uses (.goflare/state/d1/<binding>.sqlite).
+func (g *Goflare) OpenD1(remote bool) (*orm.DB, error) {
+	if remote {
+		return g.RemoteD1()
+	}
+	binding := g.Config.d1Binding()
+	cloudflare.SetD1Binding(binding)
+	return d1.NewEdge(binding)
+}
+
+// RunMigrate runs `goflare d1 migrate <action>`: up applies the pending migrations, status
+// lists them, create writes the next empty one (name is its name). With backup (or
+// D1_BACKUP_BEFORE_MIGRATE=true) up exports the database to BackupDir first, and does not
+// migrate if the export fails.
+func RunMigrate(envPath string, out io.Writer, action string, remote, backup bool, name string) error {
+	cfg, err := LoadConfigFromEnv(envPath)
+	if err != nil {
+		return err
+	}
+	g := New(cfg)
+
+	if action == "create" {
+		path, err := d1.CreateMigration(cfg.MigrationsDir, name)
+		if err != nil {
+			return err
+		}
+		fmt.Fprintln(out, "Created", path)
+		return nil
+	}
+
+	migrations, err := d1.LoadMigrations(cfg.MigrationsDir)
+	if err != nil {
+		return err
+	}
+	db, err := g.OpenD1(remote)
+	if err != nil {
+		return err
+	}
+	defer db.Close()
+
+	where := "local"
+	if remote {
+		where = "remote"
+	}
+
+	switch action {
+	case "up":
+		if backup || cfg.BackupBeforeMigrate {
+			pending, err := d1.Pending(db, migrations)
+			if err != nil {
+				return err
+			}
+			if len(pending) > 0 {
+				path, err := g.BackupD1(remote)
+				if err != nil {
+					return fmt.Errorf("backup before migrate: %w", err)
+				}
+				fmt.Fprintln(out, "Backed up to", path)
+			}
+		}
+		applied, err := d1.Migrate(db, migrations)
+		for _, m := range applied {
+			fmt.Fprintln(out, "Applied", m.ID())
+		}
+		if err != nil {
+			return err
+		}
+		if len(applied) == 0 {
+			fmt.Fprintf(out, "Up to date (%s).\n", where)
+		}
+		return nil
+
+	case "status":
+		states, err := d1.MigrationStatus(db, migrations)
+		if err != nil {
+			return err
+		}
+		fmt.Fprintf(out, "Migrations in %s (%s):\n", cfg.MigrationsDir, where)
+		for _, s := range states {
+			state := "pending"
+			switch {
+			case s.Changed:
+				state = "CHANGED"
+			case s.Applied:
+				state = "applied"
+			}
+			fmt.Fprintf(out, "  %-8s %s\n", state, s.ID())
+		}
+		if len(states) == 0 {
+			fmt.Fprintln(out, "  (none)")
+		}
+		return nil
+	}
+	return fmt.Errorf("unknown migrate action %q (want up, status or create)", action)
+}
+
+// checkMigrated refuses a deploy while the deployed database has pending migrations: the new
+// code would meet the old schema.
+func (g *Goflare) checkMigrated() error {
+	migrations, err := d1.LoadMigrations(g.Config.MigrationsDir)
+	if err != nil || len(migrations) == 0 {
+		return err
+	}
+	db, err := g.RemoteD1()
+	if err != nil {
+		return err
+	}
+	defer db.Close()
+	pending, err := d1.Pending(db, migrations)
+	if err != nil {
+		return fmt.Errorf("check migrations: %w", err)
+	}
+	if len(pending) > 0 {
+		return fmt.Errorf("%d pending D1 migration(s), first %s — run `goflare d1 migrate up -remote` before deploying (%s=true)",
+			len(pending), pending[0].ID(), EnvKeyRequireMigrated)
+	}
+	return nil
+}
@@ -0,0 +1,270 @@
+//go:build !wasm
+
+package goflare
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+)
+
+// Provisioned is one resource the provisioning step checked.
+type Provisioned struct {
+	Kind    string // "D1", "R2", "KV"
+	Name    string // the resource's name in Cloudflare
+	EnvKey  string // where its ID is configured
+	ID      string
+	Created bool
+}
+
+func (c *Config) kvBinding() string {
+	if c.KVNamespaceName != "" {
+		return c.KVNamespaceName
+	}
+	return "KV"
+}
+
+// wantsD1, wantsR2 and wantsKV: a resource is configured when its binding or its ID is.
+func (c *Config) wantsD1() bool { return c.D1DatabaseID != "" || c.D1DatabaseName != "" }
+func (c *Config) wantsR2() bool { return c.R2BucketID != "" || c.R2BucketName != "" }
+func (c *Config) wantsKV() bool { return c.KVNamespaceID != "" || c.KVNamespaceName != "" }
+
+// Provision makes sure every configured D1 database, R2 bucket and KV namespace exists,
+// creating by name what does not, and fills the IDs into Config. Resources are named after
+// the project: the database is PROJECT_NAME, the bucket R2_BUCKET_ID (or PROJECT_NAME), the
+// namespace PROJECT_NAME-<binding>. An existing resource of that name is adopted, never
+// replaced, so running it twice is harmless.
+func (g *Goflare) Provision(client *CfClient) ([]Provisioned, error) {
+	var out []Provisioned
+	if g.Config.wantsD1() {
+		p, err := g.provisionD1(client)
+		if err != nil {
+			return out, fmt.Errorf("provision D1: %w", err)
+		}
+		g.Config.D1DatabaseID = p.ID
+		out = append(out, p)
+	}
+	if g.Config.wantsR2() {
+		p, err := g.provisionR2(client)
+		if err != nil {
+			return out, fmt.Errorf("provision R2: %w", err)
+		}
+		g.Config.R2BucketID = p.ID
+		out = append(out, p)
+	}
+	if g.Config.wantsKV() {
+		p, err := g.provisionKV(client)
+		if err != nil {
+			return out, fmt.Errorf("provision KV: %w", err)
+		}
+		g.Config.KVNamespaceID = p.ID
+		out = append(out, p)
+	}
+	return out, nil
+}
+
+func (g *Goflare) provisionD1(client *CfClient) (Provisioned, error) {
+	p := Provisioned{Kind: "D1", Name: g.Config.ProjectName, EnvKey: EnvKeyD1DatabaseID}
+	base := fmt.Sprintf("/accounts/%s/d1/database", g.Config.AccountID)
+
+	if id := g.Config.D1DatabaseID; id != "" {
+		p.ID = id
+		return p, configuredExists(client, base+"/"+url.PathEscape(id), EnvKeyD1DatabaseID, id)
+	}
+	var dbs []struct {
+		UUID string `json:"uuid"`
+		Name string `json:"name"`
+	}
+	if err := getJSON(client, base+"?name="+url.QueryEscape(p.Name), &dbs); err != nil {
+		return p, err
+	}
+	for _, db := range dbs {
+		if db.Name == p.Name {
+			p.ID = db.UUID
+			return p, nil
+		}
+	}
+	var created struct {
+		UUID string `json:"uuid"`
+	}
+	if err := postJSON(client, base, map[string]string{"name": p.Name}, &created); err != nil {
+		return p, err
+	}
+	p.ID, p.Created = created.UUID, true
+	return p, nil
+}
+
+func (g *Goflare) provisionR2(client *CfClient) (Provisioned, error) {
+	name := g.Config.R2BucketID
+	if name == "" {
+		name = g.Config.ProjectName
+	}
+	// An R2 bucket's ID is its name, so there is nothing to look up: it exists or it is created.
+	p := Provisioned{Kind: "R2", Name: name, EnvKey: EnvKeyR2BucketID, ID: name}
+	base := fmt.Sprintf("/accounts/%s/r2/buckets", g.Config.AccountID)
+	exists, err := resourceExists(client, base+"/"+url.PathEscape(name))
+	if err != nil || exists {
+		return p, err
+	}
+	if err := postJSON(client, base, map[string]string{"name": name}, &struct{}{}); err != nil {
+		return p, err
+	}
+	p.Created = true
+	return p, nil
+}
+
+func (g *Goflare) provisionKV(client *CfClient) (Provisioned, error) {
+	p := Provisioned{Kind: "KV", Name: g.Config.ProjectName + "-" + g.Config.kvBinding(), EnvKey: EnvKeyKVNamespaceID}
+	base := fmt.Sprintf("/accounts/%s/storage/kv/namespaces", g.Config.AccountID)
+
+	if id := g.Config.KVNamespaceID; id != "" {
+		p.ID = id
+		return p, configuredExists(client, base+"/"+url.PathEscape(id), EnvKeyKVNamespaceID, id)
+	}
+	// There is no lookup by title: read the list, page by page, until it runs out.
+	for page, seen := 1, 0; ; page++ {
+		raw, info, err := client.getPage(fmt.Sprintf("%s?per_page=%d&page=%d", base, kvPageSize, page))
+		if err != nil {
+			return p, err
+		}
+		var namespaces []struct {
+			ID    string `json:"id"`
+			Title string `json:"title"`
+		}
+		if err := json.Unmarshal(raw, &namespaces); err != nil {
+			return p, fmt.Errorf("unexpected response: %w", err)
+		}
+		for _, ns := range namespaces {
+			if ns.Title == p.Name {
+				p.ID = ns.ID
+				return p, nil
+			}
+		}
+		seen += len(namespaces)
+		if lastPage(info, page, len(namespaces), seen) {
+			break
+		}
+	}
+	var created struct {
+		ID string `json:"id"`
+	}
+	if err := postJSON(client, base, map[string]string{"title": p.Name}, &created); err != nil {
+		return p, err
+	}
+	p.ID, p.Created = created.ID, true
+	return p, nil
+}
+
+// kvPageSize is how many namespaces one list call asks for; 100 is the API's maximum.
+const kvPageSize = 100
+
+// lastPage reports whether page, which held n items, ends a list of which seen have been
+// read. It trusts result_info when the endpoint sends it, and otherwise stops at a short page.
+func lastPage(info cfResultInfo, page, n, seen int) bool {
+	switch {
+	case n == 0:
+		return true
+	case info.TotalPages > 0:
+		return page >= info.TotalPages
+	case info.TotalCount > 0:
+		return seen >= info.TotalCount
+	}
+	return n < kvPageSize
+}
+
+// configuredExists checks an ID the configuration names. A missing one is an error, not a
+// cue to create a replacement: the ID was written down on purpose, and a new, empty resource
+// under a new ID — rewritten into .env — would hide that the data it pointed to is gone.
+func configuredExists(client *CfClient, path, envKey, id string) error {
+	exists, err := resourceExists(client, path)
+	if err != nil {
+		return err
+	}
+	if !exists {
+		return fmt.Errorf("%s=%s does not exist on this account: fix the ID, or remove it to adopt or create the resource by name", envKey, id)
+	}
+	return nil
+}
+
+// resourceExists reports whether GET path succeeds; a 404 is "no", anything else an error.
+func resourceExists(client *CfClient, path string) (bool, error) {
+	_, err := client.get(path)
+	if err == nil {
+		return true, nil
+	}
+	var apiErr *cfError
+	if errors.As(err, &apiErr) && apiErr.Status == http.StatusNotFound {
+		return false, nil
+	}
+	return false, err
+}
+
+func getJSON(client *CfClient, path string, into any) error {
+	raw, err := client.get(path)
+	if err != nil {
+		return err
+	}
+	if err := json.Unmarshal(raw, into); err != nil {
+		return fmt.Errorf("unexpected response: %w", err)
+	}
+	return nil
+}
+
+// reportProvisioned writes the IDs back to the .env file when there is one, and prints them
+// either way — in CI there is no file to keep, and the IDs belong in repository variables.
+func reportProvisioned(envPath string, out io.Writer, res []Provisioned) error {
+	for _, p := range res {
+		state := "exists"
+		if p.Created {
+			state = "created"
+		}
+		fmt.Fprintf(out, "%s %s: %s — %s=%s\n", p.Kind, p.Name, state, p.EnvKey, p.ID)
+	}
+	if envPath == "" {
+		return nil
+	}
+	if _, err := os.Stat(envPath); err != nil {
+		return nil
+	}
+	for _, p := range res {
+		if err := setEnvValue(envPath, p.EnvKey, p.ID); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// setEnvValue sets key in a .env file: the existing line is replaced, keeping an `export `
+// prefix, or one is appended. Comments, order and other keys are left as they are.
+func setEnvValue(path, key, value string) error {
+	raw, err := os.ReadFile(path)
+	if err != nil {
+		return err
+	}
+	lines := strings.Split(string(raw), "\n")
+	found := false
+	for i, line := range lines {
+		k, _, ok := strings.Cut(strings.TrimSpace(line), "=")
+		export := ""
+		if rest, cut := strings.CutPrefix(k, "export "); cut {
+			k, export = rest, "export "
+		}
+		if ok && strings.TrimSpace(k) == key {
+			lines[i] = export + key + "=" + value
+			found = true
+		}
+	}
+	if !found {
+		if n := len(lines); n > 0 && lines[n-1] == "" {
+			lines = append(lines[:n-1], key+"="+value, "")
+		} else {
+			lines = append(lines, key+"="+value)
+		}
+	}
+	return os.WriteFile(path, []byte(strings.Join(lines, "\n")), 0o644)
+}
@@ -0,0 +1,37 @@
+package goflare
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestSetEnvValue_ReplacesOrAppendsKeepingTheRest(t *testing.T) {
+	path := filepath.Join(t.TempDir(), ".env")
+	os.WriteFile(path, []byte("# app\nPROJECT_NAME=shop\nD1_DATABASE_ID=old\n"), 0o644)
+
+	if err := setEnvValue(path, EnvKeyD1DatabaseID, "new"); err != nil {
+		t.Fatal(err)
+	}
+	if err := setEnvValue(path, EnvKeyKVNamespaceID, "kv"); err != nil {
+		t.Fatal(err)
+	}
+	got, _ := os.ReadFile(path)
+	want := "# app\nPROJECT_NAME=shop\nD1_DATABASE_ID=new\nKV_NAMESPACE_ID=kv\n"
+	if string(got) != want {
+		t.Errorf(".env =\n%s\nwant\n%s", got, want)
+	}
+}
+
+func TestSetEnvValue_KeepsExportPrefix(t *testing.T) {
+	path := filepath.Join(t.TempDir(), ".env")
+	os.WriteFile(path, []byte("export D1_DATABASE_ID=old\n"), 0o644)
+
+	if err := setEnvValue(path, EnvKeyD1DatabaseID, "new"); err != nil {
+		t.Fatal(err)
+	}
+	got, _ := os.ReadFile(path)
+	if want := "export D1_DATABASE_ID=new\n"; string(got) != want {
+		t.Errorf(".env = %q, want %q", got, want)
+	}
+}
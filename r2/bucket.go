@@ -9,6 +9,7 @@ import (
 	"github.com/tinywasm/fmt"
 )
 
+// Bucket is an R2 bucket binding. Natively the same type is a directory (bucket_native.go).
 type Bucket struct {
 	obj js.Value
 }
@@ -23,39 +24,66 @@ func NewEdge(binding string) (*Bucket, error) {
 }
 
 func (b *Bucket) Put(key string, data []byte, contentType string) error {
+	_, err := b.PutWith(key, data, PutOptions{ContentType: contentType})
+	return err
+}
+
+// PutWith stores data under key with the metadata, checksums and preconditions in opts, and
+// returns what R2 recorded — the ETag included.
+func (b *Bucket) PutWith(key string, data []byte, opts PutOptions) (ObjectInfo, error) {
 	ua := js.Global().Get("Uint8Array").New(len(data))
 	js.CopyBytesToJS(ua, data)
+	return b.put(key, ua, int64(len(data)), opts)
+}
 
-	opts := js.Global().Get("Object").New()
-	if contentType != "" {
-		httpMetadata := js.Global().Get("Object").New()
-		httpMetadata.Set("contentType", contentType)
-		opts.Set("httpMetadata", httpMetadata)
+// put hands value — bytes or a stream — to R2's put with opts.
+func (b *Bucket) put(key string, value js.Value, size int64, opts PutOptions) (ObjectInfo, error) {
+	o := newObject()
+	if http := httpMetadata(opts); !http.IsUndefined() {
+		o.Set("httpMetadata", http)
 	}
-
-	if _, err := await.Promise(b.obj.Call("put", key, ua, opts)); err != nil {
-		return fmt.Errf("r2: put %s: %s", key, err.Error())
+	if len(opts.CustomMetadata) > 0 {
+		o.Set("customMetadata", stringMap(opts.CustomMetadata))
+	}
+	if opts.MD5 != "" {
+		o.Set("md5", opts.MD5)
+	}
+	if opts.SHA256 != "" {
+		o.Set("sha256", opts.SHA256)
+	}
+	if !opts.OnlyIf.isZero() {
+		o.Set("onlyIf", conditions(opts.OnlyIf))
 	}
-	return nil
-}
 
-func (b *Bucket) Get(key string) ([]byte, string, error) {
-	obj, err := await.Promise(b.obj.Call("get", key))
+	res, err := await.Promise(b.obj.Call("put", key, value, o))
 	if err != nil {
-		return nil, "", fmt.Errf("r2: get %s: %s", key, err.Error())
+		if isChecksumError(err) {
+			return ObjectInfo{}, keyErr(key, ErrChecksumMismatch)
+		}
+		return ObjectInfo{}, fmt.Errf("r2: put %s: %s", key, err.Error())
 	}
-	if obj.IsNull() || obj.IsUndefined() {
-		return nil, "", fmt.Errf("r2: object not found: %s", key)
+	// put resolves to null — not an error — when onlyIf did not hold.
+	if res.IsNull() || res.IsUndefined() {
+		if opts.OnlyIf.isZero() {
+			return ObjectInfo{Key: key, Size: size, ContentType: opts.ContentType}, nil
+		}
+		return ObjectInfo{}, keyErr(key, ErrPreconditionFailed)
 	}
+	return objectInfo(res), nil
+}
 
-	// obj is an R2Object. To get the data, we need to read from the body stream.
-	// However, usually in Workers R2 get() returns an R2Object with a .body (ReadableStream).
-	// But sometimes it returns R2ObjectBody which HAS the body.
+func (b *Bucket) Get(key string) ([]byte, string, error) {
+	data, info, err := b.GetIf(key, Conditions{})
+	return data, info.ContentType, err
+}
 
-	jsBody := obj.Get("body")
-	if jsBody.IsNull() || jsBody.IsUndefined() {
-		// Maybe it was just a metadata head() call, but we called get()
-		return nil, "", fmt.Errf("r2: object has no body: %s", key)
+// GetIf reads the object when cond holds. When it does not, the error is
+// ErrPreconditionFailed and info still describes the stored object — its ETag is what a
+// 304 Not Modified answers with.
+func (b *Bucket) GetIf(key string, cond Conditions) ([]byte, ObjectInfo, error) {
+	jsBody, info, err := b.GetStream(key, cond)
+	if err != nil {
+		return nil, info, err
 	}
 
 	// We need to read the entire stream. Easiest way in this environment:
@@ -63,7 +91,7 @@ func (b *Bucket) Get(key string) ([]byte, string, error) {
 	resp := js.Global().Get("Response").New(jsBody)
 	arrayBuffer, err := await.Promise(resp.Call("arrayBuffer"))
 	if err != nil {
-		return nil, "", fmt.Errf("r2: read body of %s: %s", key, err.Error())
+		return nil, info, fmt.Errf("r2: read body of %s: %s", key, err.Error())
 	}
 
 	byteLength := arrayBuffer.Get("byteLength").Int()
@@ -71,16 +99,19 @@ func (b *Bucket) Get(key string) ([]byte, string, error) {
 	ua := js.Global().Get("Uint8Array").New(arrayBuffer)
 	js.CopyBytesToGo(buf, ua)
 
-	contentType := ""
-	httpMetadata := obj.Get("httpMetadata")
-	if !httpMetadata.IsUndefined() && !httpMetadata.IsNull() {
-		ct := httpMetadata.Get("contentType")
-		if !ct.IsUndefined() && !ct.IsNull() {
-			contentType = ct.String()
-		}
-	}
+	return buf, info, nil
+}
 
-	return buf, contentType, nil
+// Head returns the object's metadata without reading its bytes.
+func (b *Bucket) Head(key string) (ObjectInfo, error) {
+	obj, err := await.Promise(b.obj.Call("head", key))
+	if err != nil {
+		return ObjectInfo{}, fmt.Errf("r2: head %s: %s", key, err.Error())
+	}
+	if obj.IsNull() || obj.IsUndefined() {
+		return ObjectInfo{}, keyErr(key, ErrNotFound)
+	}
+	return objectInfo(obj), nil
 }
 
 func (b *Bucket) Delete(key string) error {
@@ -90,41 +121,134 @@ func (b *Bucket) Delete(key string) error {
 	return nil
 }
 
-type ObjectInfo struct {
-	Key         string
-	Size        int64
-	ContentType string
-}
-
-func (b *Bucket) List(prefix string) ([]ObjectInfo, error) {
-	opts := js.Global().Get("Object").New()
-	if prefix != "" {
-		opts.Set("prefix", prefix)
+// ListPage returns one page of the listing opts selects.
+func (b *Bucket) ListPage(opts ListOptions) (ListResult, error) {
+	o := newObject()
+	if opts.Prefix != "" {
+		o.Set("prefix", opts.Prefix)
+	}
+	if opts.Delimiter != "" {
+		o.Set("delimiter", opts.Delimiter)
 	}
+	if opts.Cursor != "" {
+		o.Set("cursor", opts.Cursor)
+	}
+	o.Set("limit", opts.listLimit())
+	// Without include, list leaves httpMetadata and customMetadata out of every object.
+	o.Set("include", js.ValueOf([]any{"httpMetadata", "customMetadata"}))
 
-	res, err := await.Promise(b.obj.Call("list", opts))
+	res, err := await.Promise(b.obj.Call("list", o))
 	if err != nil {
-		return nil, fmt.Errf("r2: list prefix %s: %s", prefix, err.Error())
+		return ListResult{}, fmt.Errf("r2: list prefix %s: %s", opts.Prefix, err.Error())
 	}
 
+	var out ListResult
 	jsObjects := res.Get("objects")
 	n := jsObjects.Length()
-	out := make([]ObjectInfo, n)
+	out.Objects = make([]ObjectInfo, n)
 	for i := 0; i < n; i++ {
-		o := jsObjects.Index(i)
-		info := ObjectInfo{
-			Key:  o.Get("key").String(),
-			Size: int64(o.Get("size").Float()),
+		out.Objects[i] = objectInfo(jsObjects.Index(i))
+	}
+	if prefixes := res.Get("delimitedPrefixes"); !prefixes.IsUndefined() && !prefixes.IsNull() {
+		for i := 0; i < prefixes.Length(); i++ {
+			out.DelimitedPrefixes = append(out.DelimitedPrefixes, prefixes.Index(i).String())
 		}
-		httpMetadata := o.Get("httpMetadata")
-		if !httpMetadata.IsUndefined() && !httpMetadata.IsNull() {
-			ct := httpMetadata.Get("contentType")
-			if !ct.IsUndefined() && !ct.IsNull() {
-				info.ContentType = ct.String()
+	}
+	if t := res.Get("truncated"); t.Type() == js.TypeBoolean {
+		out.Truncated = t.Bool()
+	}
+	out.Cursor = stringField(res, "cursor")
+	return out, nil
+}
+
+// objectInfo reads an R2Object — what head, put, get and list all describe objects with.
+func objectInfo(o js.Value) ObjectInfo {
+	info := ObjectInfo{
+		Key:  stringField(o, "key"),
+		ETag: stringField(o, "etag"),
+	}
+	if size := o.Get("size"); size.Type() == js.TypeNumber {
+		info.Size = int64(size.Float())
+	}
+	if up := o.Get("uploaded"); up.Type() == js.TypeObject {
+		info.Uploaded = int64(up.Call("getTime").Float())
+	}
+	if http := o.Get("httpMetadata"); http.Type() == js.TypeObject {
+		info.ContentType = stringField(http, "contentType")
+		info.CacheControl = stringField(http, "cacheControl")
+		info.ContentDisposition = stringField(http, "contentDisposition")
+	}
+	if custom := o.Get("customMetadata"); custom.Type() == js.TypeObject {
+		keys := js.Global().Get("Object").Call("keys", custom)
+		if n := keys.Length(); n > 0 {
+			info.CustomMetadata = make(map[string]string, n)
+			for i := 0; i < n; i++ {
+				k := keys.Index(i).String()
+				info.CustomMetadata[k] = custom.Get(k).String()
 			}
 		}
-		out[i] = info
 	}
+	return info
+}
 
-	return out, nil
+// httpMetadata is the httpMetadata of a put, or undefined when opts sets none of it.
+func httpMetadata(opts PutOptions) js.Value {
+	if opts.ContentType == "" && opts.CacheControl == "" && opts.ContentDisposition == "" {
+		return js.Undefined()
+	}
+	m := newObject()
+	if opts.ContentType != "" {
+		m.Set("contentType", opts.ContentType)
+	}
+	if opts.CacheControl != "" {
+		m.Set("cacheControl", opts.CacheControl)
+	}
+	if opts.ContentDisposition != "" {
+		m.Set("contentDisposition", opts.ContentDisposition)
+	}
+	return m
+}
+
+// conditions is c as an R2Conditional.
+func conditions(c Conditions) js.Value {
+	o := newObject()
+	if c.EtagMatches != "" {
+		o.Set("etagMatches", c.EtagMatches)
+	}
+	if c.EtagDoesNotMatch != "" {
+		o.Set("etagDoesNotMatch", c.EtagDoesNotMatch)
+	}
+	if c.UploadedBefore != 0 {
+		o.Set("uploadedBefore", js.Global().Get("Date").New(c.UploadedBefore))
+	}
+	if c.UploadedAfter != 0 {
+		o.Set("uploadedAfter", js.Global().Get("Date").New(c.UploadedAfter))
+	}
+	return o
+}
+
+func stringMap(m map[string]string) js.Value {
+	o := newObject()
+	for k, v := range m {
+		o.Set(k, v)
+	}
+	return o
+}
+
+func stringField(o js.Value, name string) string {
+	v := o.Get(name)
+	if v.Type() != js.TypeString {
+		return ""
+	}
+	return v.String()
+}
+
+func newObject() js.Value { return js.Global().Get("Object").New() }
+
+// isChecksumError reports whether R2 threw because the bytes did not match a digest: it
+// says so in text ("The Content-MD5 you specified did not match what we received"), with
+// BadDigest's code 10037.
+func isChecksumError(err error) bool {
+	msg := err.Error()
+	return fmt.Contains(msg, "did not match what we received") || fmt.Contains(msg, "10037")
 }
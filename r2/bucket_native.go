@@ -0,0 +1,335 @@
+//go:build !wasm
+
+package r2
+
+import (
+	"bytes"
+	"crypto/md5"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"io"
+	"net/url"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/tinywasm/fmt"
+	"github.com/tinywasm/goflare/cloudflare"
+)
+
+// Bucket is the local stand-in for an R2 bucket: the same methods, backed by a directory.
+//
+// Each object is two files — its bytes under blobs/ and its metadata (content type, ETag, …)
+// under meta/ — both named by the escaped key, so a key like "a/b" or ".." can never step
+// outside the bucket or collide with a directory.
+type Bucket struct {
+	dir string
+}
+
+// NewEdge opens the local bucket for a binding name: <cloudflare.StateDir()>/r2/<binding>.
+// It is the same call the edge code makes, which is what lets that code run unchanged under
+// devserver and in native tests.
+func NewEdge(binding string) (*Bucket, error) {
+	if binding == "" {
+		return nil, ErrBucketNotFound
+	}
+	return NewLocal(filepath.Join(cloudflare.StateDir(), "r2", binding))
+}
+
+// NewLocal opens (creating if needed) a bucket stored under dir.
+func NewLocal(dir string) (*Bucket, error) {
+	for _, sub := range []string{"blobs", "meta"} {
+		if err := os.MkdirAll(filepath.Join(dir, sub), 0o755); err != nil {
+			return nil, fmt.Errf("r2: open %s: %s", dir, err.Error())
+		}
+	}
+	return &Bucket{dir: dir}, nil
+}
+
+// localMeta is the sidecar stored next to every object: what R2 keeps beside the bytes.
+type localMeta struct {
+	ContentType        string            `json:"contentType,omitempty"`
+	Size               int64             `json:"size"`
+	ETag               string            `json:"etag,omitempty"`
+	Uploaded           int64             `json:"uploaded,omitempty"`
+	CacheControl       string            `json:"cacheControl,omitempty"`
+	ContentDisposition string            `json:"contentDisposition,omitempty"`
+	CustomMetadata     map[string]string `json:"customMetadata,omitempty"`
+}
+
+func (m localMeta) info(key string) ObjectInfo {
+	return ObjectInfo{
+		Key:                key,
+		Size:               m.Size,
+		ContentType:        m.ContentType,
+		ETag:               m.ETag,
+		Uploaded:           m.Uploaded,
+		CacheControl:       m.CacheControl,
+		ContentDisposition: m.ContentDisposition,
+		CustomMetadata:     m.CustomMetadata,
+	}
+}
+
+// writeMu makes a conditional Put's check and write one step, as R2 does: two writers
+// racing on the same ETag must not both win.
+var writeMu sync.Mutex
+
+func (b *Bucket) Put(key string, data []byte, contentType string) error {
+	_, err := b.PutWith(key, data, PutOptions{ContentType: contentType})
+	return err
+}
+
+// PutWith stores data under key with the metadata, checksums and preconditions in opts, and
+// returns what was recorded — the ETag included.
+func (b *Bucket) PutWith(key string, data []byte, opts PutOptions) (ObjectInfo, error) {
+	return b.put(key, bytes.NewReader(data), int64(len(data)), opts)
+}
+
+// put copies src into a temporary blob, hashing as it goes, and moves it into place only once
+// the size, the checksums and the preconditions all hold. size < 0 means unknown.
+func (b *Bucket) put(key string, src io.Reader, size int64, opts PutOptions) (ObjectInfo, error) {
+	if key == "" {
+		return ObjectInfo{}, fmt.Err(errPrefix + "put: empty key")
+	}
+	tmp, err := os.CreateTemp(filepath.Join(b.dir, "blobs"), ".tmp-*")
+	if err != nil {
+		return ObjectInfo{}, fmt.Errf("r2: put %s: %s", key, err.Error())
+	}
+	defer os.Remove(tmp.Name()) // a no-op once renamed
+
+	md5sum, shasum := md5.New(), sha256.New()
+	n, err := io.Copy(io.MultiWriter(tmp, md5sum, shasum), src)
+	if cerr := tmp.Close(); err == nil {
+		err = cerr
+	}
+	if err != nil {
+		return ObjectInfo{}, fmt.Errf("r2: put %s: %s", key, err.Error())
+	}
+	if size >= 0 && n != size {
+		return ObjectInfo{}, fmt.Errf("r2: put %s: body is %d bytes, declared %d", key, n, size)
+	}
+	etag := hex.EncodeToString(md5sum.Sum(nil))
+	if opts.MD5 != "" && !strings.EqualFold(opts.MD5, etag) {
+		return ObjectInfo{}, keyErr(key, ErrChecksumMismatch)
+	}
+	if opts.SHA256 != "" && !strings.EqualFold(opts.SHA256, hex.EncodeToString(shasum.Sum(nil))) {
+		return ObjectInfo{}, keyErr(key, ErrChecksumMismatch)
+	}
+
+	m := localMeta{
+		ContentType:        opts.ContentType,
+		Size:               n,
+		ETag:               etag,
+		Uploaded:           time.Now().UnixMilli(),
+		CacheControl:       opts.CacheControl,
+		ContentDisposition: opts.ContentDisposition,
+		CustomMetadata:     opts.CustomMetadata,
+	}
+	raw, _ := json.Marshal(m)
+
+	writeMu.Lock()
+	defer writeMu.Unlock()
+	if !opts.OnlyIf.isZero() {
+		stored, err := b.readMeta(key)
+		if err != nil && !os.IsNotExist(err) {
+			return ObjectInfo{}, fmt.Errf("r2: put %s: %s", key, err.Error())
+		}
+		if !holds(opts.OnlyIf, stored, err == nil) {
+			return ObjectInfo{}, keyErr(key, ErrPreconditionFailed)
+		}
+	}
+	if err := os.Rename(tmp.Name(), b.blob(key)); err != nil {
+		return ObjectInfo{}, fmt.Errf("r2: put %s: %s", key, err.Error())
+	}
+	if err := writeAtomic(b.meta(key), raw); err != nil {
+		return ObjectInfo{}, fmt.Errf("r2: put %s: %s", key, err.Error())
+	}
+	return m.info(key), nil
+}
+
+func (b *Bucket) Get(key string) ([]byte, string, error) {
+	data, info, err := b.GetIf(key, Conditions{})
+	return data, info.ContentType, err
+}
+
+// GetIf reads the object when cond holds. When it does not, the error is
+// ErrPreconditionFailed and info still describes the stored object — its ETag is what a
+// 304 Not Modified answers with.
+func (b *Bucket) GetIf(key string, cond Conditions) ([]byte, ObjectInfo, error) {
+	body, info, err := b.GetStream(key, cond)
+	if err != nil {
+		return nil, info, err
+	}
+	defer body.Close()
+	data, err := io.ReadAll(body)
+	if err != nil {
+		return nil, info, fmt.Errf("r2: get %s: %s", key, err.Error())
+	}
+	return data, info, nil
+}
+
+// Head returns the object's metadata without reading its bytes.
+func (b *Bucket) Head(key string) (ObjectInfo, error) {
+	m, err := b.readMeta(key)
+	if os.IsNotExist(err) {
+		return ObjectInfo{}, keyErr(key, ErrNotFound)
+	}
+	if err != nil {
+		return ObjectInfo{}, fmt.Errf("r2: head %s: %s", key, err.Error())
+	}
+	return m.info(key), nil
+}
+
+// Delete removes the object. Deleting a key that does not exist is not an error, as in R2.
+func (b *Bucket) Delete(key string) error {
+	for _, p := range []string{b.blob(key), b.meta(key)} {
+		if err := os.Remove(p); err != nil && !os.IsNotExist(err) {
+			return fmt.Errf("r2: delete %s: %s", key, err.Error())
+		}
+	}
+	return nil
+}
+
+// ListPage returns one page of the listing opts selects, in key order, as R2 does. The
+// cursor is the last key the page consumed; treat it as opaque all the same.
+func (b *Bucket) ListPage(opts ListOptions) (ListResult, error) {
+	keys, err := b.keys(opts.Prefix)
+	if err != nil {
+		return ListResult{}, fmt.Errf("r2: list prefix %s: %s", opts.Prefix, err.Error())
+	}
+
+	var out ListResult
+	limit, n, last := opts.listLimit(), 0, ""
+	for _, key := range keys {
+		if opts.Cursor != "" && key <= opts.Cursor {
+			continue
+		}
+		group := ""
+		if opts.Delimiter != "" {
+			if i := strings.Index(key[len(opts.Prefix):], opts.Delimiter); i >= 0 {
+				group = key[:len(opts.Prefix)+i+len(opts.Delimiter)]
+			}
+		}
+		// The rest of a group already listed is consumed without taking a slot.
+		if group != "" && len(out.DelimitedPrefixes) > 0 && out.DelimitedPrefixes[len(out.DelimitedPrefixes)-1] == group {
+			last = key
+			continue
+		}
+		if n == limit {
+			out.Truncated = true
+			out.Cursor = last
+			break
+		}
+		if group != "" {
+			out.DelimitedPrefixes = append(out.DelimitedPrefixes, group)
+		} else {
+			m, err := b.readMeta(key)
+			if err != nil {
+				return ListResult{}, fmt.Errf("r2: list prefix %s: %s", opts.Prefix, err.Error())
+			}
+			out.Objects = append(out.Objects, m.info(key))
+		}
+		n++
+		last = key
+	}
+	return out, nil
+}
+
+// keys is every stored key starting with prefix, sorted.
+func (b *Bucket) keys(prefix string) ([]string, error) {
+	entries, err := os.ReadDir(filepath.Join(b.dir, "meta"))
+	if err != nil {
+		return nil, err
+	}
+	var keys []string
+	for _, e := range entries {
+		if strings.HasPrefix(e.Name(), ".") {
+			continue // a write in flight: escapeKey never produces a leading "."
+		}
+		key, err := url.PathUnescape(e.Name())
+		if err != nil || !strings.HasPrefix(key, prefix) {
+			continue
+		}
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys, nil
+}
+
+// holds evaluates cond against the stored object (exists is false when there is none) the
+// way R2 does: against a missing key only EtagDoesNotMatch can hold.
+func holds(cond Conditions, stored localMeta, exists bool) bool {
+	if cond.isZero() {
+		return true
+	}
+	if !exists {
+		return cond.EtagMatches == "" && cond.UploadedBefore == 0 && cond.UploadedAfter == 0
+	}
+	if cond.EtagMatches != "" && !etagIn(cond.EtagMatches, stored.ETag) {
+		return false
+	}
+	if cond.EtagDoesNotMatch != "" && etagIn(cond.EtagDoesNotMatch, stored.ETag) {
+		return false
+	}
+	if cond.UploadedBefore != 0 && stored.Uploaded >= cond.UploadedBefore {
+		return false
+	}
+	if cond.UploadedAfter != 0 && stored.Uploaded <= cond.UploadedAfter {
+		return false
+	}
+	return true
+}
+
+// etagIn reports whether etag is named by want: "*", or the tag itself, quoted or not.
+func etagIn(want, etag string) bool {
+	if want == "*" {
+		return true
+	}
+	return strings.Trim(strings.TrimPrefix(want, "W/"), `"`) == etag
+}
+
+func (b *Bucket) readMeta(key string) (localMeta, error) {
+	var m localMeta
+	raw, err := os.ReadFile(b.meta(key))
+	if err != nil {
+		return m, err
+	}
+	err = json.Unmarshal(raw, &m)
+	return m, err
+}
+
+func (b *Bucket) blob(key string) string { return filepath.Join(b.dir, "blobs", escapeKey(key)) }
+func (b *Bucket) meta(key string) string { return filepath.Join(b.dir, "meta", escapeKey(key)) }
+
+// escapeKey maps a key to ONE path element: "/" is escaped, and so is a leading ".", so
+// neither "a/b" nor ".." can name anything but a file inside the bucket.
+func escapeKey(key string) string {
+	s := url.PathEscape(key)
+	if strings.HasPrefix(s, ".") {
+		s = "%2E" + s[1:]
+	}
+	return s
+}
+
+// writeAtomic writes through a temporary file and a rename, so a crash mid-write never
+// leaves a torn object behind.
+func writeAtomic(path string, data []byte) error {
+	tmp, err := os.CreateTemp(filepath.Dir(path), ".tmp-*")
+	if err != nil {
+		return err
+	}
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		os.Remove(tmp.Name())
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		os.Remove(tmp.Name())
+		return err
+	}
+	return os.Rename(tmp.Name(), path)
+}
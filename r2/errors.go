@@ -5,3 +5,19 @@ import . "github.com/tinywasm/fmt"
 const errPrefix = "r2: "
 
 var ErrBucketNotFound = Err(errPrefix + "bucket not found")
+
+// The outcomes a caller branches on. An error returned by this package matches one of these
+// with errors.Is and still names the key it was about.
+var (
+	// ErrNotFound: no object under the key. R2 answers null, not an error; this is that null.
+	ErrNotFound = Err(errPrefix + "object not found")
+	// ErrPreconditionFailed: the Conditions of a GetIf or a conditional Put did not hold.
+	ErrPreconditionFailed = Err(errPrefix + "precondition failed")
+	// ErrChecksumMismatch: the bytes did not match PutOptions.MD5 or SHA256; nothing was written.
+	ErrChecksumMismatch = Err(errPrefix + "checksum mismatch")
+)
+
+// keyErr tags the key with one of the sentinels above.
+func keyErr(key string, sentinel error) error {
+	return ErrType(Err(key), sentinel)
+}
@@ -0,0 +1,36 @@
+package r2
+
+// List returns every object whose key starts with prefix, in key order. It follows the
+// listing's cursor to the end, so a bucket past one page (MaxListLimit keys) is listed whole;
+// for a large bucket prefer Each, which does not hold the whole listing in memory.
+func (b *Bucket) List(prefix string) ([]ObjectInfo, error) {
+	var out []ObjectInfo
+	err := b.Each(ListOptions{Prefix: prefix}, func(o ObjectInfo) error {
+		out = append(out, o)
+		return nil
+	})
+	return out, err
+}
+
+// Each calls fn for every object the listing selects, page after page, until the listing is
+// exhausted or fn returns an error — which Each then returns unchanged. opts.Limit sets the
+// page size; opts.Cursor starts mid-listing. With a Delimiter, only the objects directly
+// under the prefix are visited: the grouped prefixes are what ListPage is for.
+func (b *Bucket) Each(opts ListOptions, fn func(ObjectInfo) error) error {
+	for {
+		page, err := b.ListPage(opts)
+		if err != nil {
+			return err
+		}
+		for _, o := range page.Objects {
+			if err := fn(o); err != nil {
+				return err
+			}
+		}
+		// A truncated page without a cursor would restart the listing forever.
+		if !page.Truncated || page.Cursor == "" {
+			return nil
+		}
+		opts.Cursor = page.Cursor
+	}
+}
@@ -0,0 +1,88 @@
+//go:build wasm
+
+package r2
+
+import (
+	"syscall/js"
+
+	"github.com/tinywasm/await"
+	"github.com/tinywasm/fmt"
+)
+
+// MultipartUpload is an object being written in parts: R2's R2MultipartUpload. Parts can be
+// sent in any order, in parallel and from different requests — ResumeMultipartUpload picks one
+// back up by its ID — and nothing is visible under Key until Complete.
+type MultipartUpload struct {
+	Key      string
+	UploadID string
+	obj      js.Value
+}
+
+// CreateMultipartUpload starts an upload to key. opts carries the metadata the object will
+// have; its checksums and OnlyIf do not apply to a multipart upload.
+func (b *Bucket) CreateMultipartUpload(key string, opts PutOptions) (*MultipartUpload, error) {
+	o := newObject()
+	if http := httpMetadata(opts); !http.IsUndefined() {
+		o.Set("httpMetadata", http)
+	}
+	if len(opts.CustomMetadata) > 0 {
+		o.Set("customMetadata", stringMap(opts.CustomMetadata))
+	}
+	u, err := await.Promise(b.obj.Call("createMultipartUpload", key, o))
+	if err != nil {
+		return nil, fmt.Errf("r2: create multipart upload %s: %s", key, err.Error())
+	}
+	return &MultipartUpload{Key: key, UploadID: stringField(u, "uploadId"), obj: u}, nil
+}
+
+// ResumeMultipartUpload returns the upload started earlier — typically by another request.
+// It does not check that the upload exists: the next call on it does.
+func (b *Bucket) ResumeMultipartUpload(key, uploadID string) *MultipartUpload {
+	u := b.obj.Call("resumeMultipartUpload", key, uploadID)
+	return &MultipartUpload{Key: key, UploadID: uploadID, obj: u}
+}
+
+// UploadPart stores part n (1 to MaxParts). Sending the same n again replaces it.
+func (u *MultipartUpload) UploadPart(n int, data []byte) (UploadedPart, error) {
+	ua := js.Global().Get("Uint8Array").New(len(data))
+	js.CopyBytesToJS(ua, data)
+	return u.uploadPart(n, ua)
+}
+
+// UploadPartStream pipes body into part n. size is its exact byte count, as for PutStream.
+func (u *MultipartUpload) UploadPartStream(n int, body Stream, size int64) (UploadedPart, error) {
+	fixed := js.Global().Get("FixedLengthStream").New(float64(size))
+	return u.uploadPart(n, body.Call("pipeThrough", fixed))
+}
+
+func (u *MultipartUpload) uploadPart(n int, value js.Value) (UploadedPart, error) {
+	p, err := await.Promise(u.obj.Call("uploadPart", n, value))
+	if err != nil {
+		return UploadedPart{}, fmt.Errf("r2: upload part %d of %s: %s", n, u.Key, err.Error())
+	}
+	return UploadedPart{PartNumber: n, ETag: stringField(p, "etag")}, nil
+}
+
+// Complete assembles parts, in PartNumber order, into the object under Key.
+func (u *MultipartUpload) Complete(parts []UploadedPart) (ObjectInfo, error) {
+	arr := js.Global().Get("Array").New()
+	for _, p := range parts {
+		o := newObject()
+		o.Set("partNumber", p.PartNumber)
+		o.Set("etag", p.ETag)
+		arr.Call("push", o)
+	}
+	obj, err := await.Promise(u.obj.Call("complete", arr))
+	if err != nil {
+		return ObjectInfo{}, fmt.Errf("r2: complete %s: %s", u.Key, err.Error())
+	}
+	return objectInfo(obj), nil
+}
+
+// Abort discards the upload and every part sent so far.
+func (u *MultipartUpload) Abort() error {
+	if _, err := await.Promise(u.obj.Call("abort")); err != nil {
+		return fmt.Errf("r2: abort %s: %s", u.Key, err.Error())
+	}
+	return nil
+}
@@ -0,0 +1,206 @@
+//go:build !wasm
+
+package r2
+
+import (
+	"bytes"
+	"crypto/md5"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+
+	"github.com/tinywasm/fmt"
+)
+
+// MultipartUpload is an object being written in parts, held under uploads/<UploadID>/ until
+// Complete assembles them. Parts can be sent in any order and from different requests —
+// ResumeMultipartUpload picks one back up by its ID — and nothing is visible under Key until
+// Complete.
+type MultipartUpload struct {
+	Key      string
+	UploadID string
+	b        *Bucket
+}
+
+// uploadMeta is what an upload remembers between requests: where it goes and what it carries.
+type uploadMeta struct {
+	Key  string    `json:"key"`
+	Meta localMeta `json:"meta"`
+}
+
+// CreateMultipartUpload starts an upload to key. opts carries the metadata the object will
+// have; its checksums and OnlyIf do not apply to a multipart upload.
+func (b *Bucket) CreateMultipartUpload(key string, opts PutOptions) (*MultipartUpload, error) {
+	if key == "" {
+		return nil, fmt.Err(errPrefix + "create multipart upload: empty key")
+	}
+	id := make([]byte, 16)
+	if _, err := rand.Read(id); err != nil {
+		return nil, fmt.Errf("r2: create multipart upload %s: %s", key, err.Error())
+	}
+	u := &MultipartUpload{Key: key, UploadID: hex.EncodeToString(id), b: b}
+
+	raw, _ := json.Marshal(uploadMeta{Key: key, Meta: localMeta{
+		ContentType:        opts.ContentType,
+		CacheControl:       opts.CacheControl,
+		ContentDisposition: opts.ContentDisposition,
+		CustomMetadata:     opts.CustomMetadata,
+	}})
+	dir := filepath.Join(b.dir, "uploads", u.UploadID)
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return nil, fmt.Errf("r2: create multipart upload %s: %s", key, err.Error())
+	}
+	if err := writeAtomic(filepath.Join(dir, "upload.json"), raw); err != nil {
+		return nil, fmt.Errf("r2: create multipart upload %s: %s", key, err.Error())
+	}
+	return u, nil
+}
+
+// ResumeMultipartUpload returns the upload started earlier — typically by another request.
+// It does not check that the upload exists: the next call on it does.
+func (b *Bucket) ResumeMultipartUpload(key, uploadID string) *MultipartUpload {
+	return &MultipartUpload{Key: key, UploadID: uploadID, b: b}
+}
+
+// UploadPart stores part n (1 to MaxParts). Sending the same n again replaces it.
+func (u *MultipartUpload) UploadPart(n int, data []byte) (UploadedPart, error) {
+	return u.UploadPartStream(n, io.NopCloser(bytes.NewReader(data)), int64(len(data)))
+}
+
+// UploadPartStream copies body into part n and closes it. size is its exact byte count, as
+// for PutStream.
+func (u *MultipartUpload) UploadPartStream(n int, body Stream, size int64) (UploadedPart, error) {
+	defer body.Close()
+	if n < 1 || n > MaxParts {
+		return UploadedPart{}, fmt.Errf("r2: upload part %d of %s: part numbers run from 1 to %d", n, u.Key, MaxParts)
+	}
+	dir, _, err := u.open()
+	if err != nil {
+		return UploadedPart{}, err
+	}
+
+	tmp, err := os.CreateTemp(dir, ".tmp-*")
+	if err != nil {
+		return UploadedPart{}, fmt.Errf("r2: upload part %d of %s: %s", n, u.Key, err.Error())
+	}
+	defer os.Remove(tmp.Name())
+	sum := md5.New()
+	written, err := io.Copy(io.MultiWriter(tmp, sum), body)
+	if cerr := tmp.Close(); err == nil {
+		err = cerr
+	}
+	if err == nil && written != size {
+		err = fmt.Errf("body is %d bytes, declared %d", written, size)
+	}
+	if err == nil {
+		err = os.Rename(tmp.Name(), partPath(dir, n))
+	}
+	if err != nil {
+		return UploadedPart{}, fmt.Errf("r2: upload part %d of %s: %s", n, u.Key, err.Error())
+	}
+	return UploadedPart{PartNumber: n, ETag: hex.EncodeToString(sum.Sum(nil))}, nil
+}
+
+// Complete assembles parts, in PartNumber order, into the object under Key. As in R2, every
+// part but the last must be at least MinPartSize and all of them the same size, and each
+// ETag must be the one its part was stored with.
+func (u *MultipartUpload) Complete(parts []UploadedPart) (ObjectInfo, error) {
+	dir, meta, err := u.open()
+	if err != nil {
+		return ObjectInfo{}, err
+	}
+	if len(parts) == 0 {
+		return ObjectInfo{}, fmt.Errf("r2: complete %s: no parts", u.Key)
+	}
+	parts = append([]UploadedPart(nil), parts...)
+	sort.Slice(parts, func(i, j int) bool { return parts[i].PartNumber < parts[j].PartNumber })
+
+	var readers []io.Reader
+	var files []*os.File
+	defer func() {
+		for _, f := range files {
+			f.Close()
+		}
+	}()
+	var first int64
+	for i, p := range parts {
+		f, err := os.Open(partPath(dir, p.PartNumber))
+		if err != nil {
+			return ObjectInfo{}, fmt.Errf("r2: complete %s: part %d was never uploaded", u.Key, p.PartNumber)
+		}
+		files = append(files, f)
+		sum := md5.New()
+		size, err := io.Copy(sum, f)
+		if err != nil {
+			return ObjectInfo{}, fmt.Errf("r2: complete %s: %s", u.Key, err.Error())
+		}
+		if hex.EncodeToString(sum.Sum(nil)) != p.ETag {
+			return ObjectInfo{}, fmt.Errf("r2: complete %s: part %d does not match its ETag", u.Key, p.PartNumber)
+		}
+		if i == 0 {
+			first = size
+		}
+		if i < len(parts)-1 && (size < MinPartSize || size != first) {
+			return ObjectInfo{}, fmt.Errf("r2: complete %s: part %d is %d bytes; all but the last must be the same size, at least %d",
+				u.Key, p.PartNumber, size, MinPartSize)
+		}
+		if _, err := f.Seek(0, io.SeekStart); err != nil {
+			return ObjectInfo{}, fmt.Errf("r2: complete %s: %s", u.Key, err.Error())
+		}
+		readers = append(readers, f)
+	}
+
+	info, err := u.b.put(u.Key, io.MultiReader(readers...), -1, PutOptions{
+		ContentType:        meta.Meta.ContentType,
+		CacheControl:       meta.Meta.CacheControl,
+		ContentDisposition: meta.Meta.ContentDisposition,
+		CustomMetadata:     meta.Meta.CustomMetadata,
+	})
+	if err != nil {
+		return ObjectInfo{}, err
+	}
+	os.RemoveAll(dir)
+	return info, nil
+}
+
+// Abort discards the upload and every part sent so far.
+func (u *MultipartUpload) Abort() error {
+	dir, _, err := u.open()
+	if err != nil {
+		return err
+	}
+	if err := os.RemoveAll(dir); err != nil {
+		return fmt.Errf("r2: abort %s: %s", u.Key, err.Error())
+	}
+	return nil
+}
+
+// open finds the upload's directory and what it was created with. The ID is checked to be
+// one CreateMultipartUpload could have minted, so it can never name a path outside uploads/.
+func (u *MultipartUpload) open() (string, uploadMeta, error) {
+	var m uploadMeta
+	if len(u.UploadID) != 32 {
+		return "", m, fmt.Errf("r2: multipart upload %s not found", u.UploadID)
+	}
+	if _, err := hex.DecodeString(u.UploadID); err != nil {
+		return "", m, fmt.Errf("r2: multipart upload %s not found", u.UploadID)
+	}
+	dir := filepath.Join(u.b.dir, "uploads", u.UploadID)
+	raw, err := os.ReadFile(filepath.Join(dir, "upload.json"))
+	if err == nil {
+		err = json.Unmarshal(raw, &m)
+	}
+	if err != nil || m.Key != u.Key {
+		return "", m, fmt.Errf("r2: multipart upload %s not found for %s", u.UploadID, u.Key)
+	}
+	return dir, m, nil
+}
+
+func partPath(dir string, n int) string {
+	return filepath.Join(dir, "part-"+strconv.Itoa(n))
+}
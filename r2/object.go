@@ -0,0 +1,108 @@
+package r2
+
+// ObjectInfo describes one object: what Head returns, what a listing yields, and what Get
+// reports alongside the bytes.
+type ObjectInfo struct {
+	Key         string
+	Size        int64
+	ContentType string
+
+	// ETag is the object's entity tag, unquoted. For a single-part upload it is the hex MD5
+	// of the bytes, so it changes exactly when the content does.
+	ETag string
+
+	// Uploaded is when the object was written, in Unix milliseconds.
+	Uploaded int64
+
+	CacheControl       string
+	ContentDisposition string
+
+	// CustomMetadata is the string map stored with the object by PutOptions.CustomMetadata.
+	CustomMetadata map[string]string
+}
+
+// PutOptions is everything Put can store beside the bytes.
+type PutOptions struct {
+	ContentType        string
+	CacheControl       string
+	ContentDisposition string
+	CustomMetadata     map[string]string
+
+	// MD5 and SHA256 are hex digests the bytes must match. R2 refuses the write when they
+	// do not (ErrChecksumMismatch): a body truncated or altered on the way never lands.
+	MD5    string
+	SHA256 string
+
+	// OnlyIf makes the write conditional on the object currently stored: when it does not
+	// hold, nothing is written and Put returns ErrPreconditionFailed.
+	OnlyIf Conditions
+}
+
+// Conditions are R2's onlyIf: the preconditions a conditional Get or Put checks against the
+// stored object. A zero field is not checked; a zero Conditions always holds.
+type Conditions struct {
+	// EtagMatches holds when the stored ETag equals it. "*" matches any existing object.
+	EtagMatches string
+	// EtagDoesNotMatch holds when the stored ETag differs. "*" holds only when the key is
+	// free, which makes it a create-if-absent.
+	EtagDoesNotMatch string
+	// UploadedBefore and UploadedAfter bound the stored object's upload time (Unix ms).
+	UploadedBefore int64
+	UploadedAfter  int64
+}
+
+func (c Conditions) isZero() bool {
+	return c == Conditions{}
+}
+
+// MaxListLimit is the most objects one List call returns — R2's own ceiling, and the page
+// size used when ListOptions.Limit is zero.
+const MaxListLimit = 1000
+
+// ListOptions selects one page of a listing.
+type ListOptions struct {
+	Prefix string
+
+	// Delimiter groups every key that has it after Prefix under one entry in
+	// ListResult.DelimitedPrefixes ("photos/2024/") instead of listing each object: a
+	// directory view of a flat keyspace. Usually "/".
+	Delimiter string
+
+	// Cursor continues a listing where the previous page stopped (ListResult.Cursor).
+	Cursor string
+
+	// Limit caps the entries in the page; zero or anything above MaxListLimit means
+	// MaxListLimit.
+	Limit int
+}
+
+// ListResult is one page of a listing. When Truncated is true there is more: pass Cursor
+// back in ListOptions.Cursor — or let Each do it.
+type ListResult struct {
+	Objects           []ObjectInfo
+	DelimitedPrefixes []string
+	Truncated         bool
+	Cursor            string
+}
+
+// listLimit is the page size R2 is asked for.
+func (o ListOptions) listLimit() int {
+	if o.Limit <= 0 || o.Limit > MaxListLimit {
+		return MaxListLimit
+	}
+	return o.Limit
+}
+
+// MinPartSize is the smallest part R2 accepts in a multipart upload, except for the last one.
+// Every other part must also be the same size.
+const MinPartSize = 5 << 20 // 5 MiB
+
+// MaxParts is the most parts one multipart upload can have.
+const MaxParts = 10000
+
+// UploadedPart is what UploadPart returns and Complete takes back: the part's number and
+// the ETag R2 gave it.
+type UploadedPart struct {
+	PartNumber int
+	ETag       string
+}
@@ -0,0 +1,115 @@
+//go:build wasm
+
+package r2
+
+import (
+	"syscall/js"
+
+	"github.com/tinywasm/await"
+	"github.com/tinywasm/fmt"
+)
+
+// Stream is a body that is piped, never held whole in wasm memory: a ReadableStream at the
+// edge — the request's body, an R2 object's body — and an io.ReadCloser natively
+// (stream_native.go).
+type Stream = js.Value
+
+// PutStream pipes body into the object under key. size is the exact byte count: R2 must know
+// a stream's length before it accepts it, and a body that turns out longer or shorter fails
+// the write instead of storing a torn object.
+func (b *Bucket) PutStream(key string, body Stream, size int64, opts PutOptions) (ObjectInfo, error) {
+	if body.IsNull() || body.IsUndefined() {
+		return b.put(key, js.Null(), 0, opts)
+	}
+	// A request body declares its length; one that has been through Peek (a tee) has lost
+	// it, so it is restated.
+	fixed := js.Global().Get("FixedLengthStream").New(float64(size))
+	return b.put(key, body.Call("pipeThrough", fixed), size, opts)
+}
+
+// GetStream returns the object's body unread, for the response to pipe to the client. The
+// errors and the info on a failed precondition are GetIf's.
+func (b *Bucket) GetStream(key string, cond Conditions) (Stream, ObjectInfo, error) {
+	if cond.isZero() {
+		return b.getBody(key, js.Undefined())
+	}
+	o := newObject()
+	o.Set("onlyIf", conditions(cond))
+	return b.getBody(key, o)
+}
+
+// GetRange is GetStream for length bytes from offset: what an HTTP Range request is served
+// from. info still describes the whole object — Size is its full size. A range past the end
+// is an error: resolve it against Head's Size first.
+func (b *Bucket) GetRange(key string, offset, length int64, cond Conditions) (Stream, ObjectInfo, error) {
+	rng := newObject()
+	rng.Set("offset", float64(offset))
+	rng.Set("length", float64(length))
+	o := newObject()
+	o.Set("range", rng)
+	if !cond.isZero() {
+		o.Set("onlyIf", conditions(cond))
+	}
+	return b.getBody(key, o)
+}
+
+// getBody calls R2's get with opts (undefined for none) and returns the body it answers.
+func (b *Bucket) getBody(key string, opts js.Value) (Stream, ObjectInfo, error) {
+	var obj js.Value
+	var err error
+	if opts.IsUndefined() {
+		obj, err = await.Promise(b.obj.Call("get", key))
+	} else {
+		obj, err = await.Promise(b.obj.Call("get", key, opts))
+	}
+	if err != nil {
+		return js.Null(), ObjectInfo{}, fmt.Errf("r2: get %s: %s", key, err.Error())
+	}
+	if obj.IsNull() || obj.IsUndefined() {
+		return js.Null(), ObjectInfo{}, keyErr(key, ErrNotFound)
+	}
+	info := objectInfo(obj)
+
+	// get returns an R2ObjectBody, whose .body is a ReadableStream. When onlyIf fails it
+	// returns a plain R2Object instead: the metadata, with no body.
+	body := obj.Get("body")
+	if body.IsNull() || body.IsUndefined() {
+		return js.Null(), info, keyErr(key, ErrPreconditionFailed)
+	}
+	return body, info, nil
+}
+
+// Peek reads the first n bytes of body — enough to judge its type by the magic bytes — and
+// returns them with a stream that still yields the whole body, those bytes included. Only
+// what was peeked is held in memory.
+func Peek(body Stream, n int) ([]byte, Stream, error) {
+	if body.IsNull() || body.IsUndefined() {
+		return nil, body, nil
+	}
+	branches := body.Call("tee")
+	probe, rest := branches.Index(0), branches.Index(1)
+
+	reader := probe.Call("getReader")
+	var head []byte
+	for len(head) < n {
+		chunk, err := await.Promise(reader.Call("read"))
+		if err != nil {
+			rest.Call("cancel")
+			return nil, js.Null(), fmt.Errf("r2: peek: %s", err.Error())
+		}
+		if chunk.Get("done").Bool() {
+			break
+		}
+		v := chunk.Get("value")
+		buf := make([]byte, v.Get("byteLength").Int())
+		js.CopyBytesToGo(buf, v)
+		head = append(head, buf...)
+	}
+	// Cancelling one branch of a tee leaves the other whole.
+	reader.Call("cancel")
+
+	if len(head) > n {
+		head = head[:n]
+	}
+	return head, rest, nil
+}
@@ -0,0 +1,85 @@
+//go:build !wasm
+
+package r2
+
+import (
+	"bytes"
+	"io"
+	"os"
+
+	"github.com/tinywasm/fmt"
+)
+
+// Stream is a body that is piped, never held whole: natively an io.ReadCloser — a request
+// body, an open blob — and a ReadableStream at the edge (stream.go).
+type Stream = io.ReadCloser
+
+// PutStream copies body into the object under key and closes it. size is the exact byte
+// count, as R2 requires of a stream: a body that turns out longer or shorter fails the write
+// and leaves the previous object, if any, untouched.
+func (b *Bucket) PutStream(key string, body Stream, size int64, opts PutOptions) (ObjectInfo, error) {
+	defer body.Close()
+	return b.put(key, body, size, opts)
+}
+
+// GetStream opens the object's body for the response to pipe to the client; the caller
+// closes it. The errors and the info on a failed precondition are GetIf's.
+func (b *Bucket) GetStream(key string, cond Conditions) (Stream, ObjectInfo, error) {
+	info, err := b.Head(key)
+	if err != nil {
+		return nil, info, err
+	}
+	if !holds(cond, localMeta{ETag: info.ETag, Uploaded: info.Uploaded}, true) {
+		return nil, info, keyErr(key, ErrPreconditionFailed)
+	}
+	f, err := os.Open(b.blob(key))
+	if os.IsNotExist(err) {
+		return nil, ObjectInfo{}, keyErr(key, ErrNotFound) // deleted since the Head
+	}
+	if err != nil {
+		return nil, info, fmt.Errf("r2: get %s: %s", key, err.Error())
+	}
+	return f, info, nil
+}
+
+// GetRange is GetStream for length bytes from offset: what an HTTP Range request is served
+// from. info still describes the whole object — Size is its full size. A range past the end
+// is an error: resolve it against Head's Size first.
+func (b *Bucket) GetRange(key string, offset, length int64, cond Conditions) (Stream, ObjectInfo, error) {
+	body, info, err := b.GetStream(key, cond)
+	if err != nil {
+		return nil, info, err
+	}
+	if offset < 0 || length < 0 || offset+length > info.Size {
+		body.Close()
+		return nil, info, fmt.Errf("r2: get %s: range %d+%d outside %d bytes", key, offset, length, info.Size)
+	}
+	f := body.(*os.File)
+	if _, err := f.Seek(offset, io.SeekStart); err != nil {
+		f.Close()
+		return nil, info, fmt.Errf("r2: get %s: %s", key, err.Error())
+	}
+	return readCloser{io.LimitReader(f, length), f}, info, nil
+}
+
+// Peek reads the first n bytes of body — enough to judge its type by the magic bytes — and
+// returns them with a stream that still yields the whole body, those bytes included. Only
+// what was peeked is held in memory.
+func Peek(body Stream, n int) ([]byte, Stream, error) {
+	head := make([]byte, n)
+	m, err := io.ReadFull(body, head)
+	if err != nil && err != io.EOF && err != io.ErrUnexpectedEOF {
+		body.Close()
+		return nil, nil, fmt.Errf("r2: peek: %s", err.Error())
+	}
+	head = head[:m]
+	rest := io.MultiReader(bytes.NewReader(append([]byte(nil), head...)), body)
+	return head, readCloser{rest, body}, nil
+}
+
+// readCloser reads through Reader — the peeked bytes then the rest, or one range of a blob —
+// and closes what lies underneath it.
+type readCloser struct {
+	io.Reader
+	io.Closer
+}
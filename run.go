@@ -113,6 +113,23 @@ func RunDeploy(envPath string, out io.Writer) error {
 		HttpClient: http.DefaultClient,
 	}
 
+	// Provision before anything reads the IDs — the migration check below included.
+	if cfg.Provision {
+		provisioned, err := g.Provision(client)
+		if rerr := reportProvisioned(envPath, out, provisioned); err == nil {
+			err = rerr
+		}
+		if err != nil {
+			return err
+		}
+	}
+
+	if cfg.RequireMigrated {
+		if err := g.checkMigrated(); err != nil {
+			return err
+		}
+	}
+
 	var results []DeployResult
 
 	// Deploy as standalone Worker only when Entry is set AND no Pages Functions
@@ -170,6 +187,9 @@ Commands:
   auth      Validate CLOUDFLARE_API_TOKEN from environment
   build     Build the project (compiles WASM and/or copies assets)
   deploy    Deploy the project to Cloudflare (requires CLOUDFLARE_API_TOKEN env var)
+  dev       Run web/server.go natively and restart it on every Go change
+  d1        D1 database tools: d1 migrate up|status|create <name>, d1 drift,
+            d1 export [-o file], d1 import <file>
 
 Flags:
   -env string
@@ -177,6 +197,15 @@ Flags:
 
 Auth Flags:
   -check    Verify token from environment
+
+Deploy Flags:
+  -provision  Create the configured D1, R2 and KV resources that do not exist (PROVISION=true)
+
+D1 Migrate / Drift Flags:
+  -remote   Run against the deployed database (default: the local one)
+  -emit     (drift) Write a migration with this name that closes the drift
+  -backup   (migrate up) Export the database to D1_BACKUP_DIR first
+  -o        (export) Dump file; default a timestamped file in D1_BACKUP_DIR
 `
 }
 
@@ -0,0 +1,205 @@
+// Package session is a ready edge.Config.Authn: the caller's identity travels in a cookie
+// the server signed, so any Worker instance can trust it without a lookup.
+//
+//	sess, _ := session.New("SESSION_SECRET")
+//	r := edge.NewRouter(edge.Config{Authn: sess.Authn(), Authorize: app.Authorize})
+//
+//	r.Post("/login", func(ctx router.Context) {
+//	    // … check the credentials, then:
+//	    sess.Issue(ctx, user.ID)
+//	}).Public()
+//
+// The cookie holds the user ID and an expiry, and an HMAC-SHA256 over both (package
+// webcrypto). It is not encrypted: the user ID is readable by whoever holds the cookie, which
+// is the user. Nothing else about the session is stored anywhere, so a cookie cannot be
+// revoked before it expires except by rotating the secret.
+package session
+
+import (
+	"github.com/tinywasm/fmt"
+	"github.com/tinywasm/goflare/cloudflare"
+	"github.com/tinywasm/goflare/internal/clock"
+	"github.com/tinywasm/goflare/log"
+	"github.com/tinywasm/goflare/webcrypto"
+	"github.com/tinywasm/router"
+)
+
+const (
+	// DefaultName is the cookie's name unless Name says otherwise. The __Host- prefix makes
+	// the browser refuse it unless it is Secure, on Path=/ and without a Domain: no sibling
+	// subdomain can set or overwrite it.
+	DefaultName = "__Host-session"
+
+	// DefaultTTL is how long, in seconds, an issued session lasts: a week.
+	DefaultTTL = 7 * 24 * 60 * 60
+)
+
+// Sessions issues, verifies and clears signed session cookies.
+type Sessions struct {
+	secretVar string
+	previous  []string
+	name      string
+	ttl       int64
+}
+
+// New signs sessions with the secret named secretVar, read through cloudflare.Env on every
+// request — `wrangler secret put` at the edge, .dev.vars locally. Sessions signed with the
+// secrets named in previous still verify, and are re-signed with the current one as they
+// arrive. To rotate: copy the current value to a second secret, name it in previous, set a
+// new current value, and drop previous once DefaultTTL has passed.
+//
+// If secretVar is unset when a cookie arrives, the request is answered 500: treating every
+// caller as anonymous would log everybody out without a trace of why.
+func New(secretVar string, previous ...string) (*Sessions, error) {
+	if secretVar == "" {
+		return nil, fmt.Err("session: empty secret name")
+	}
+	return &Sessions{secretVar: secretVar, previous: previous, name: DefaultName, ttl: DefaultTTL}, nil
+}
+
+// Name changes the cookie's name. Keep the __Host- prefix unless the app is served over
+// plain HTTP on a host other than localhost, where a Secure cookie is never sent.
+func (s *Sessions) Name(name string) *Sessions {
+	s.name = name
+	return s
+}
+
+// TTL changes how long, in seconds, an issued session lasts. It must be positive: Issue
+// refuses to start a session that would be expired before it arrives.
+func (s *Sessions) TTL(seconds int64) *Sessions {
+	s.ttl = seconds
+	return s
+}
+
+// Authn returns the middleware for edge.Config.Authn. A valid, unexpired cookie sets the
+// caller's UserID; a missing, tampered or expired one leaves the caller anonymous, which the
+// access gate then answers for.
+func (s *Sessions) Authn() router.Middleware {
+	return func(next router.HandlerFunc) router.HandlerFunc {
+		return func(ctx router.Context) {
+			if c, ok := ctx.Cookie(s.name); ok && c.Value != "" {
+				uid, exp, stale, err := s.verify(c.Value)
+				if err != nil {
+					log.Fail(500, ctx.Method(), ctx.Path(), err)
+					ctx.WriteStatus(500)
+					return
+				}
+				if uid != "" {
+					ctx.SetUserID(uid)
+					if stale {
+						// Signed with a previous secret: move it to the current one, same expiry.
+						if value, err := s.sign(uid, exp); err == nil {
+							s.set(ctx, value, exp-clock.Now())
+						}
+					}
+				}
+			}
+			next(ctx)
+		}
+	}
+}
+
+// Issue starts a session for userID: it sets the cookie, and the caller's identity for the
+// rest of this request. Call it once the credentials have been checked.
+func (s *Sessions) Issue(ctx router.Context, userID string) error {
+	if userID == "" {
+		return fmt.Err("session: empty user ID")
+	}
+	if s.ttl <= 0 {
+		return fmt.Errf("session: TTL %d is not positive", s.ttl)
+	}
+	exp := clock.Now() + s.ttl
+	value, err := s.sign(userID, exp)
+	if err != nil {
+		return err
+	}
+	s.set(ctx, value, s.ttl)
+	ctx.SetUserID(userID)
+	return nil
+}
+
+// Clear ends the session: it tells the browser to drop the cookie, and the caller is
+// anonymous for the rest of this request.
+func (s *Sessions) Clear(ctx router.Context) {
+	ctx.SetCookie(s.cookie("", -1))
+	ctx.SetUserID("")
+}
+
+// set sends the session cookie with value, kept by the browser for maxAge seconds.
+func (s *Sessions) set(ctx router.Context, value string, maxAge int64) {
+	ctx.SetCookie(s.cookie(value, int(maxAge)))
+}
+
+// cookie is the session cookie with the safe attributes: unreadable from JavaScript, sent only
+// over HTTPS, and not on cross-site subrequests (a form POSTed from another site carries no
+// session). A negative maxAge deletes it.
+func (s *Sessions) cookie(value string, maxAge int) router.Cookie {
+	return router.Cookie{
+		Name:     s.name,
+		Value:    value,
+		Path:     "/",
+		MaxAge:   maxAge,
+		Secure:   true,
+		HttpOnly: true,
+		SameSite: router.SameSiteLax,
+	}
+}
+
+// sign builds a cookie value — the user ID in hex, the expiry, the signature — under the
+// current secret. Hex keeps any user ID inside the characters a cookie may carry.
+func (s *Sessions) sign(uid string, exp int64) (string, error) {
+	secret := cloudflare.Env(s.secretVar)
+	if secret == "" {
+		return "", fmt.Errf("session: secret %s is not set", s.secretVar)
+	}
+	e := fmt.Convert(exp).String()
+	sig, err := mac(secret, uid, e)
+	if err != nil {
+		return "", err
+	}
+	return webcrypto.Hex([]byte(uid)) + "." + e + "." + webcrypto.Hex(sig), nil
+}
+
+// verify returns the user ID of a well-signed, unexpired cookie value and its expiry, or ""
+// for anything else. stale reports it verified only under a previous secret. err is only the
+// current secret missing.
+func (s *Sessions) verify(value string) (uid string, exp int64, stale bool, err error) {
+	current := cloudflare.Env(s.secretVar)
+	if current == "" {
+		return "", 0, false, fmt.Errf("session: secret %s is not set", s.secretVar)
+	}
+
+	parts := fmt.Split(value, ".")
+	if len(parts) != 3 {
+		return "", 0, false, nil
+	}
+	rawUID, ok1 := webcrypto.Unhex(parts[0])
+	sig, ok2 := webcrypto.Unhex(parts[2])
+	exp, err = fmt.Convert(parts[1]).Int64()
+	if !ok1 || !ok2 || err != nil || len(rawUID) == 0 || exp <= clock.Now() {
+		return "", 0, false, nil
+	}
+
+	for i, name := range append([]string{s.secretVar}, s.previous...) {
+		secret := current
+		if i > 0 {
+			if secret = cloudflare.Env(name); secret == "" {
+				continue // a rotation that is over
+			}
+		}
+		want, err := mac(secret, string(rawUID), parts[1])
+		if err != nil {
+			return "", 0, false, err
+		}
+		if webcrypto.Equal(sig, want) {
+			return string(rawUID), exp, i > 0, nil
+		}
+	}
+	return "", 0, false, nil
+}
+
+// mac is the HMAC over what a session asserts: who, and until when. The leading "session"
+// keeps it from ever verifying as another goflare signature under a shared secret.
+func mac(secret, uid, exp string) ([]byte, error) {
+	return webcrypto.HMAC([]byte(secret), []byte("session\x00"+uid+"\x00"+exp))
+}
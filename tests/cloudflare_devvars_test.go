@@ -0,0 +1,87 @@
+//go:build !wasm
+
+package goflare_test
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/tinywasm/goflare/cloudflare"
+)
+
+func useDevVars(t *testing.T, content string) {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), ".dev.vars")
+	if err := os.WriteFile(path, []byte(content), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	cloudflare.SetDevVarsPath(path)
+	t.Cleanup(func() { cloudflare.SetDevVarsPath("") })
+}
+
+func TestDevVars_WranglerFormat(t *testing.T) {
+	useDevVars(t, `# local secrets
+API_KEY=abc123
+export REGION = eu  # trailing comment
+SINGLE='keep \n literal'
+DOUBLE="line1\nline2 \"quoted\""
+MULTI="first
+second"
+EMPTY=
+`)
+
+	cases := map[string]string{
+		"API_KEY": "abc123",
+		"REGION":  "eu",
+		"SINGLE":  `keep \n literal`,
+		"DOUBLE":  "line1\nline2 \"quoted\"",
+		"MULTI":   "first\nsecond",
+		"EMPTY":   "",
+	}
+	for key, want := range cases {
+		got, ok := cloudflare.Lookup(key)
+		if !ok || got != want {
+			t.Errorf("Lookup(%s) = %q, %v; want %q", key, got, ok, want)
+		}
+	}
+	if _, ok := cloudflare.Lookup("NOT_THERE"); ok {
+		t.Error("a key in neither source was found")
+	}
+}
+
+func TestDevVars_OSEnvironmentWins(t *testing.T) {
+	useDevVars(t, "GOFLARE_TEST_TOKEN=from-file\n")
+
+	if got := cloudflare.Env("GOFLARE_TEST_TOKEN"); got != "from-file" {
+		t.Errorf("Env = %q, want the .dev.vars value", got)
+	}
+	t.Setenv("GOFLARE_TEST_TOKEN", "from-os")
+	if got := cloudflare.Env("GOFLARE_TEST_TOKEN"); got != "from-os" {
+		t.Errorf("Env = %q, want the exported value to override the file", got)
+	}
+}
+
+func TestDevVars_MissingFileIsNoVars(t *testing.T) {
+	cloudflare.SetDevVarsPath(filepath.Join(t.TempDir(), "absent"))
+	t.Cleanup(func() { cloudflare.SetDevVarsPath("") })
+
+	if got := cloudflare.EnvOr("GOFLARE_TEST_UNSET", "fallback"); got != "fallback" {
+		t.Errorf("EnvOr = %q", got)
+	}
+}
+
+func TestEnvJSON_DecodesAVar(t *testing.T) {
+	useDevVars(t, `SIGNUP={"email":"a@b.c"}`+"\n")
+
+	var got signupArgs
+	if err := cloudflare.EnvJSON("SIGNUP", &got); err != nil {
+		t.Fatal(err)
+	}
+	if got.Email != "a@b.c" {
+		t.Errorf("Email = %q", got.Email)
+	}
+	if err := cloudflare.EnvJSON("NOT_THERE", &got); err == nil {
+		t.Error("EnvJSON of an unset var must fail")
+	}
+}
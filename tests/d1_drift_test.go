@@ -0,0 +1,120 @@
+//go:build !wasm
+
+package goflare_test
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/tinywasm/goflare/d1"
+	"github.com/tinywasm/model"
+)
+
+// noteV1 is the note model as first deployed; noteV2 gained a field in code.
+type noteV1 struct {
+	ID    int64
+	Title string
+}
+
+func (n *noteV1) ModelName() string { return "note" }
+func (n *noteV1) Schema() []model.Field {
+	return []model.Field{{Name: "id", Type: model.Int()}, {Name: "title", Type: model.Text()}}
+}
+func (n *noteV1) Pointers() []any { return []any{&n.ID, &n.Title} }
+func (n *noteV1) IsNil() bool     { return n == nil }
+func (n *noteV1) EncodeFields(w model.FieldWriter) {
+	w.Int("id", n.ID)
+	w.String("title", n.Title)
+}
+func (n *noteV1) DecodeFields(r model.FieldReader) {
+	n.ID, _ = r.Int("id")
+	n.Title, _ = r.String("title")
+}
+
+type noteV2 struct {
+	ID    int64
+	Title string
+	Body  string
+}
+
+func (n *noteV2) ModelName() string { return "note" }
+func (n *noteV2) Schema() []model.Field {
+	return []model.Field{
+		{Name: "id", Type: model.Int()}, {Name: "title", Type: model.Text()}, {Name: "body", Type: model.Text()},
+	}
+}
+func (n *noteV2) Pointers() []any { return []any{&n.ID, &n.Title, &n.Body} }
+func (n *noteV2) IsNil() bool     { return n == nil }
+func (n *noteV2) EncodeFields(w model.FieldWriter) {
+	w.Int("id", n.ID)
+	w.String("title", n.Title)
+	w.String("body", n.Body)
+}
+func (n *noteV2) DecodeFields(r model.FieldReader) {
+	n.ID, _ = r.Int("id")
+	n.Title, _ = r.String("title")
+	n.Body, _ = r.String("body")
+}
+
+func TestD1Drift_MissingTableAndItsFix(t *testing.T) {
+	db, _ := d1.NewLocal(":memory:")
+	defer db.Close()
+
+	drift, err := d1.Diff(db, &noteV2{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(drift) != 1 || drift[0].Kind != d1.MissingTable || drift[0].Table != "note" {
+		t.Fatalf("drift = %v", drift)
+	}
+
+	if err := db.RawConn().Exec(d1.DriftSQL(drift)); err != nil {
+		t.Fatalf("the emitted migration does not apply: %v", err)
+	}
+	if drift, _ := d1.Diff(db, &noteV2{}); len(drift) != 0 {
+		t.Errorf("after the fix, drift = %v", drift)
+	}
+}
+
+func TestD1Drift_ColumnAddedInCodeOnly(t *testing.T) {
+	db, _ := d1.NewLocal(":memory:")
+	defer db.Close()
+	if err := d1.CreateTables(&noteV1{})(db); err != nil {
+		t.Fatal(err)
+	}
+	db.RawConn().Exec(`INSERT INTO note (id, title) VALUES (1, 'kept')`)
+
+	drift, err := d1.Diff(db, &noteV2{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(drift) != 1 || drift[0].Kind != d1.MissingColumn || drift[0].Column != "body" {
+		t.Fatalf("drift = %v, want the missing note.body", drift)
+	}
+
+	sql := d1.DriftSQL(drift)
+	if !strings.Contains(sql, "ADD COLUMN") {
+		t.Errorf("migration = %q", sql)
+	}
+	if err := db.RawConn().Exec(sql); err != nil {
+		t.Fatalf("the emitted migration does not apply to a table with rows: %v", err)
+	}
+	if drift, _ := d1.Diff(db, &noteV2{}); len(drift) != 0 {
+		t.Errorf("after the fix, drift = %v", drift)
+	}
+}
+
+func TestD1Drift_ExtraColumnIsReportedNotDropped(t *testing.T) {
+	db, _ := d1.NewLocal(":memory:")
+	defer db.Close()
+	d1.CreateTables(&noteV2{})(db)
+	db.RawConn().Exec(`ALTER TABLE note ADD COLUMN legacy TEXT`)
+
+	drift, _ := d1.Diff(db, &noteV2{})
+	if len(drift) != 1 || drift[0].Kind != d1.ExtraColumn || drift[0].Column != "legacy" {
+		t.Fatalf("drift = %v", drift)
+	}
+	if sql := d1.DriftSQL(drift); !strings.HasPrefix(sql, "-- review by hand") {
+		t.Errorf("an extra column must not be dropped automatically: %q", sql)
+	}
+}
@@ -0,0 +1,144 @@
+//go:build !wasm
+
+package goflare_test
+
+import (
+	"bytes"
+	"encoding/json"
+	"io"
+	"net/http"
+	"os"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/tinywasm/goflare"
+	"github.com/tinywasm/goflare/d1"
+)
+
+func TestD1Dump_RestoresIntoAnEmptyDatabase(t *testing.T) {
+	src, _ := d1.NewLocal(":memory:")
+	defer src.Close()
+	conn := src.RawConn()
+	conn.Exec(`CREATE TABLE doc (id INTEGER PRIMARY KEY, title TEXT, raw BLOB, score REAL)`)
+	conn.Exec(`CREATE INDEX doc_title ON doc(title)`)
+	conn.Exec(`INSERT INTO doc VALUES (1, 'it''s', X'FF00', 1.5), (2, NULL, NULL, NULL)`)
+
+	var dump bytes.Buffer
+	if err := d1.Dump(src, &dump); err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(dump.String(), "CREATE INDEX doc_title") {
+		t.Errorf("the index is missing from the dump:\n%s", dump.String())
+	}
+
+	dst, _ := d1.NewLocal(":memory:")
+	defer dst.Close()
+	if err := d1.Restore(dst, dump.String()); err != nil {
+		t.Fatalf("restore: %v\n%s", err, dump.String())
+	}
+	var title string
+	var raw []byte
+	var score float64
+	if err := dst.RawConn().QueryRow(`SELECT title, raw, score FROM doc WHERE id = 1`).Scan(&title, &raw, &score); err != nil {
+		t.Fatal(err)
+	}
+	if title != "it's" || !bytes.Equal(raw, []byte{0xFF, 0x00}) || score != 1.5 {
+		t.Errorf("restored row = %q %v %v", title, raw, score)
+	}
+}
+
+func TestD1Export_RemotePollsThenDownloads(t *testing.T) {
+	os.Setenv("CLOUDFLARE_API_TOKEN", "tok")
+	defer os.Unsetenv("CLOUDFLARE_API_TOKEN")
+
+	polls := 0
+	var base string // the server's own URL, for the signed download link
+	srv := MockHTTPServer(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/dump.sql" {
+			if r.Header.Get("Authorization") != "" {
+				t.Error("the API token was sent to the signed download URL")
+			}
+			w.Write([]byte("CREATE TABLE t (id INTEGER);\n"))
+			return
+		}
+		if r.URL.Path != "/accounts/acc/d1/database/db-id/export" {
+			t.Errorf("unexpected request %s", r.URL.Path)
+		}
+		var req map[string]any
+		json.NewDecoder(r.Body).Decode(&req)
+		polls++
+		if polls == 1 {
+			if req["current_bookmark"] != nil {
+				t.Error("the first call must start the export, not poll it")
+			}
+			w.Write([]byte(`{"success":true,"result":{"at_bookmark":"bm1","status":"active"}}`))
+			return
+		}
+		if req["current_bookmark"] != "bm1" {
+			t.Errorf("poll without the bookmark: %v", req)
+		}
+		w.Write([]byte(`{"success":true,"result":{"status":"complete","result":{"signed_url":"` + base + `/dump.sql"}}}`))
+	})
+	defer srv.Close()
+	base = srv.URL
+
+	g := goflare.New(&goflare.Config{AccountID: "acc", D1DatabaseID: "db-id"})
+	g.BaseURL = srv.URL
+	g.RetryBackoff = time.Millisecond
+
+	var out bytes.Buffer
+	if err := g.ExportD1(&out, true); err != nil {
+		t.Fatal(err)
+	}
+	if out.String() != "CREATE TABLE t (id INTEGER);\n" || polls != 2 {
+		t.Errorf("dump = %q after %d calls", out.String(), polls)
+	}
+}
+
+func TestD1Import_RemoteUploadsAndIngests(t *testing.T) {
+	os.Setenv("CLOUDFLARE_API_TOKEN", "tok")
+	defer os.Unsetenv("CLOUDFLARE_API_TOKEN")
+
+	var uploaded []byte
+	var actions []string
+	var base string
+	srv := MockHTTPServer(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/upload" {
+			uploaded, _ = io.ReadAll(r.Body)
+			return
+		}
+		var req map[string]any
+		json.NewDecoder(r.Body).Decode(&req)
+		action, _ := req["action"].(string)
+		actions = append(actions, action)
+		switch action {
+		case "init":
+			w.Write([]byte(`{"success":true,"result":{"upload_url":"` + base + `/upload","filename":"f.sql"}}`))
+		case "ingest":
+			if req["filename"] != "f.sql" {
+				t.Errorf("ingest of %v", req["filename"])
+			}
+			w.Write([]byte(`{"success":true,"result":{"at_bookmark":"bm","status":"active"}}`))
+		default:
+			w.Write([]byte(`{"success":true,"result":{"status":"complete"}}`))
+		}
+	})
+	defer srv.Close()
+	base = srv.URL
+
+	g := goflare.New(&goflare.Config{AccountID: "acc", D1DatabaseID: "db-id"})
+	g.BaseURL = srv.URL
+	g.RetryBackoff = time.Millisecond
+
+	sql := []byte("INSERT INTO t VALUES (1);\n")
+	if err := g.ImportD1(sql, true); err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(uploaded, sql) {
+		t.Errorf("uploaded %q", uploaded)
+	}
+	if strings.Join(actions, ",") != "init,ingest,poll" {
+		t.Errorf("actions = %v", actions)
+	}
+}
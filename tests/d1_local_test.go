@@ -0,0 +1,222 @@
+//go:build !wasm
+
+package goflare_test
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/tinywasm/goflare/cloudflare"
+	"github.com/tinywasm/goflare/d1"
+	"github.com/tinywasm/orm"
+)
+
+func TestD1Local_RoundtripAndNotFound(t *testing.T) {
+	db, err := d1.NewLocal(":memory:")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+	conn := db.RawConn()
+
+	if err := conn.Exec(`CREATE TABLE note (id INTEGER PRIMARY KEY, body TEXT, raw BLOB)`); err != nil {
+		t.Fatal(err)
+	}
+	if err := conn.Exec(`INSERT INTO note (id, body, raw) VALUES (?, ?, ?)`, 1, "hi", []byte{0xFF, 0x00}); err != nil {
+		t.Fatal(err)
+	}
+
+	var body string
+	var raw []byte
+	if err := conn.QueryRow(`SELECT body, raw FROM note WHERE id = ?`, 1).Scan(&body, &raw); err != nil {
+		t.Fatal(err)
+	}
+	if body != "hi" || len(raw) != 2 || raw[0] != 0xFF {
+		t.Errorf("got %q %v", body, raw)
+	}
+
+	if err := conn.QueryRow(`SELECT body FROM note WHERE id = ?`, 2).Scan(&body); err != orm.ErrNotFound {
+		t.Errorf("missing row: err = %v, want orm.ErrNotFound as at the edge", err)
+	}
+
+	rows, err := conn.Query(`SELECT id, body FROM note`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer rows.Close()
+	if cols, _ := rows.Columns(); len(cols) != 2 || cols[1] != "body" {
+		t.Errorf("columns = %v", cols)
+	}
+}
+
+func TestD1Local_ForeignKeysAreEnforcedAsInD1(t *testing.T) {
+	db, _ := d1.NewLocal(":memory:")
+	defer db.Close()
+	conn := db.RawConn()
+
+	conn.Exec(`CREATE TABLE owner (id INTEGER PRIMARY KEY)`)
+	conn.Exec(`CREATE TABLE pet (id INTEGER PRIMARY KEY, owner_id INTEGER REFERENCES owner(id))`)
+	if err := conn.Exec(`INSERT INTO pet (id, owner_id) VALUES (1, 99)`); err == nil {
+		t.Error("an orphan row was accepted: D1 enforces foreign keys")
+	}
+}
+
+func TestD1Local_DDLCompilerIsForwarded(t *testing.T) {
+	db, _ := d1.NewLocal(":memory:")
+	defer db.Close()
+	if _, ok := d1.DDLCompiler(db.RawConn()); !ok {
+		t.Error("the local connection does not expose CompileDDL")
+	}
+}
+
+func TestD1Local_NewEdgeByBindingName(t *testing.T) {
+	dir := t.TempDir()
+	cloudflare.SetStateDir(dir)
+	t.Cleanup(func() { cloudflare.SetStateDir("") })
+	t.Setenv("D1_DATABASE_NAME", "")
+
+	for _, bad := range []string{"", "../DB", "a/b", ".DB", "DBB"} {
+		if _, err := d1.NewEdge(bad); err != d1.ErrDatabaseNotFound {
+			t.Errorf("NewEdge(%q): err = %v, want ErrDatabaseNotFound", bad, err)
+		}
+	}
+
+	db, err := d1.NewEdge("DB")
+	if err != nil {
+		t.Fatal(err)
+	}
+	db.RawConn().Exec(`CREATE TABLE kv (k TEXT PRIMARY KEY, v TEXT)`)
+	db.RawConn().Exec(`INSERT INTO kv VALUES ('a', 'b')`)
+	db.Close()
+
+	if _, err := os.Stat(filepath.Join(dir, "d1", "DB.sqlite")); err != nil {
+		t.Fatalf("binding DB is not stored at <state>/d1/DB.sqlite: %v", err)
+	}
+	again, _ := d1.NewEdge("DB")
+	defer again.Close()
+	var v string
+	if err := again.RawConn().QueryRow(`SELECT v FROM kv WHERE k = 'a'`).Scan(&v); err != nil || v != "b" {
+		t.Errorf("reopening the binding lost its data: %q, %v", v, err)
+	}
+	if _, err := os.Stat(filepath.Join(dir, "d1", "DBB.sqlite")); err == nil {
+		t.Error("a mistyped binding must not get a database of its own")
+	}
+}
+
+func TestD1Local_NewEdgeOpensOnlyTheConfiguredBinding(t *testing.T) {
+	cloudflare.SetStateDir(t.TempDir())
+	t.Cleanup(func() { cloudflare.SetStateDir("") })
+	cloudflare.SetD1Binding("MAIN")
+	t.Cleanup(func() { cloudflare.SetD1Binding("") })
+
+	if _, err := d1.NewEdge("DB"); err != d1.ErrDatabaseNotFound {
+		t.Errorf("NewEdge(DB) with MAIN configured: err = %v, want ErrDatabaseNotFound", err)
+	}
+	db, err := d1.NewEdge("MAIN")
+	if err != nil {
+		t.Fatal(err)
+	}
+	db.Close()
+
+	cloudflare.SetD1Binding("")
+	t.Setenv("D1_DATABASE_NAME", "USERS")
+	if got := cloudflare.D1Binding(); got != "USERS" {
+		t.Errorf("D1Binding() = %q, want the D1_DATABASE_NAME it was deployed under", got)
+	}
+}
+
+func TestD1Local_BatchIsAtomicWithPerStatementResults(t *testing.T) {
+	db, _ := d1.NewLocal(":memory:")
+	defer db.Close()
+	conn := db.RawConn()
+	conn.Exec(`CREATE TABLE orders (id INTEGER PRIMARY KEY AUTOINCREMENT, customer TEXT NOT NULL)`)
+	conn.Exec(`CREATE TABLE items (order_id INTEGER NOT NULL REFERENCES orders(id), sku TEXT NOT NULL)`)
+
+	res, err := d1.Batch(db,
+		d1.Statement(`INSERT INTO orders (customer) VALUES (?)`, "ana"),
+		d1.Statement(`INSERT INTO items (order_id, sku) VALUES (last_insert_rowid(), ?)`, "A1"),
+		d1.Statement(`UPDATE orders SET customer = ? WHERE id > 0`, "ana b."),
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(res) != 3 || res[0].LastRowID != 1 || res[0].Changes != 1 || res[2].Changes != 1 {
+		t.Errorf("results = %+v", res)
+	}
+
+	// The second statement breaks NOT NULL: the first must not survive it.
+	_, err = d1.Batch(db,
+		d1.Statement(`INSERT INTO orders (customer) VALUES (?)`, "bea"),
+		d1.Statement(`INSERT INTO items (order_id, sku) VALUES (last_insert_rowid(), NULL)`),
+	)
+	if err == nil {
+		t.Fatal("a failing batch reported success")
+	}
+	var n int64
+	conn.QueryRow(`SELECT COUNT(*) FROM orders`).Scan(&n)
+	if n != 1 {
+		t.Errorf("orders = %d after a failed batch, want 1: the batch half-applied", n)
+	}
+}
+
+func TestD1Local_ExecReportsInsertedIDAndChanges(t *testing.T) {
+	db, _ := d1.NewLocal(":memory:")
+	defer db.Close()
+	db.RawConn().Exec(`CREATE TABLE tag (id INTEGER PRIMARY KEY AUTOINCREMENT, name TEXT)`)
+
+	for want := int64(1); want <= 2; want++ {
+		res, err := d1.Exec(db, `INSERT INTO tag (name) VALUES (?)`, "t")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if res.LastRowID != want || res.Changes != 1 || res.RowsWritten != 1 {
+			t.Errorf("insert %d: result = %+v", want, res)
+		}
+	}
+
+	res, err := d1.Exec(db, `UPDATE tag SET name = ?`, "u")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if res.Changes != 2 || res.DurationMs < 0 {
+		t.Errorf("update: result = %+v", res)
+	}
+}
+
+func TestD1Local_ErrorsAreClassified(t *testing.T) {
+	db, _ := d1.NewLocal(":memory:")
+	defer db.Close()
+	conn := db.RawConn()
+	conn.Exec(`CREATE TABLE owner (id INTEGER PRIMARY KEY, email TEXT NOT NULL UNIQUE)`)
+	conn.Exec(`CREATE TABLE pet (id INTEGER PRIMARY KEY, owner_id INTEGER REFERENCES owner(id))`)
+	conn.Exec(`INSERT INTO owner (id, email) VALUES (1, 'a@b.c')`)
+
+	cases := []struct {
+		name   string
+		query  string
+		class  error
+		status int
+	}{
+		{"duplicate", `INSERT INTO owner (id, email) VALUES (2, 'a@b.c')`, d1.ErrUniqueViolation, 409},
+		{"dangling reference", `INSERT INTO pet (id, owner_id) VALUES (1, 99)`, d1.ErrForeignKey, 409},
+		{"missing value", `INSERT INTO owner (id) VALUES (3)`, d1.ErrNotNull, 400},
+		{"bad sql", `INSERT INTO owner VALUE (4)`, d1.ErrSyntax, 500},
+	}
+	for _, c := range cases {
+		err := conn.Exec(c.query)
+		if !errors.Is(err, c.class) {
+			t.Errorf("%s: err = %v, want it classified as %v", c.name, err, c.class)
+		}
+		if got := d1.Status(err); got != c.status {
+			t.Errorf("%s: Status = %d, want %d", c.name, got, c.status)
+		}
+	}
+
+	var email string
+	err := conn.QueryRow(`SELECT email FROM owner WHERE id = 42`).Scan(&email)
+	if got := d1.Status(err); got != 404 {
+		t.Errorf("not found: Status = %d, want 404", got)
+	}
+}
@@ -0,0 +1,119 @@
+//go:build !wasm
+
+package goflare_test
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/tinywasm/goflare/d1"
+	"github.com/tinywasm/orm"
+)
+
+func writeMigration(t *testing.T, dir, file, sql string) {
+	t.Helper()
+	if err := os.WriteFile(filepath.Join(dir, file), []byte(sql), 0o644); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestD1Migrate_AppliesInOrderOnce(t *testing.T) {
+	dir := t.TempDir()
+	writeMigration(t, dir, "0010_add_email.sql", `ALTER TABLE users ADD COLUMN email TEXT;`)
+	writeMigration(t, dir, "0002_create_users.sql", "CREATE TABLE users (id INTEGER PRIMARY KEY);\nCREATE INDEX users_id ON users(id);")
+	writeMigration(t, dir, "README.md", "not a migration")
+
+	migs, err := d1.LoadMigrations(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(migs) != 2 || migs[0].ID() != "0002_create_users" || migs[1].ID() != "0010_add_email" {
+		t.Fatalf("loaded %+v, want numeric order", migs)
+	}
+
+	db, _ := d1.NewLocal(":memory:")
+	defer db.Close()
+
+	applied, err := d1.Migrate(db, migs)
+	if err != nil || len(applied) != 2 {
+		t.Fatalf("first run: applied %d, err %v", len(applied), err)
+	}
+	if err := db.RawConn().Exec(`INSERT INTO users (id, email) VALUES (1, 'a@b.c')`); err != nil {
+		t.Errorf("the schema is not the migrated one: %v", err)
+	}
+
+	applied, err = d1.Migrate(db, migs)
+	if err != nil || len(applied) != 0 {
+		t.Errorf("second run: applied %d, err %v — a migration ran twice", len(applied), err)
+	}
+	if pending, _ := d1.Pending(db, migs); len(pending) != 0 {
+		t.Errorf("pending = %+v", pending)
+	}
+}
+
+func TestD1Migrate_EditedMigrationIsRefused(t *testing.T) {
+	dir := t.TempDir()
+	writeMigration(t, dir, "0001_create_users.sql", `CREATE TABLE users (id INTEGER PRIMARY KEY);`)
+	db, _ := d1.NewLocal(":memory:")
+	defer db.Close()
+
+	migs, _ := d1.LoadMigrations(dir)
+	d1.Migrate(db, migs)
+
+	writeMigration(t, dir, "0001_create_users.sql", `CREATE TABLE users (id INTEGER PRIMARY KEY, name TEXT);`)
+	writeMigration(t, dir, "0002_more.sql", `CREATE TABLE more (id INTEGER);`)
+	migs, _ = d1.LoadMigrations(dir)
+
+	states, _ := d1.MigrationStatus(db, migs)
+	if !states[0].Changed || states[1].Applied {
+		t.Errorf("status = %+v", states)
+	}
+	if _, err := d1.Migrate(db, migs); err == nil || !strings.Contains(err.Error(), "0001_create_users") {
+		t.Errorf("err = %v, want a refusal naming the edited migration", err)
+	}
+}
+
+func TestD1Migrate_FailingStepLeavesNothing(t *testing.T) {
+	dir := t.TempDir()
+	writeMigration(t, dir, "0001_broken.sql", "CREATE TABLE half (id INTEGER);\nINSERT INTO nowhere VALUES (1);")
+	db, _ := d1.NewLocal(":memory:")
+	defer db.Close()
+
+	migs, _ := d1.LoadMigrations(dir)
+	if _, err := d1.Migrate(db, migs); err == nil {
+		t.Fatal("a broken migration reported success")
+	}
+	if err := db.RawConn().Exec(`INSERT INTO half VALUES (1)`); err == nil {
+		t.Error("the first half of a failed migration was committed")
+	}
+	if pending, _ := d1.Pending(db, migs); len(pending) != 1 {
+		t.Error("a failed migration was recorded as applied")
+	}
+}
+
+func TestD1Migrate_GoStepsAndCreate(t *testing.T) {
+	dir := t.TempDir()
+	path, err := d1.CreateMigration(dir, "Create Users!")
+	if err != nil || filepath.Base(path) != "0001_create_users.sql" {
+		t.Fatalf("CreateMigration = %q, %v", path, err)
+	}
+	path, _ = d1.CreateMigration(dir, "seed")
+	if filepath.Base(path) != "0002_seed.sql" {
+		t.Errorf("next migration = %q", path)
+	}
+
+	ran := 0
+	step := d1.GoMigration("0001", "go_step", func(db *orm.DB) error {
+		ran++
+		return db.RawConn().Exec(`CREATE TABLE from_go (id INTEGER)`)
+	})
+	db, _ := d1.NewLocal(":memory:")
+	defer db.Close()
+	d1.Migrate(db, []d1.Migration{step})
+	d1.Migrate(db, []d1.Migration{step})
+	if ran != 1 {
+		t.Errorf("Go step ran %d times, want 1", ran)
+	}
+}
@@ -0,0 +1,169 @@
+//go:build !wasm
+
+package goflare_test
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/tinywasm/goflare"
+	"github.com/tinywasm/goflare/d1"
+)
+
+// d1QueryServer stands in for the D1 /query endpoint: it records the last request and
+// answers with reply.
+func d1QueryServer(t *testing.T, reply string, status int, got *map[string]any) *goflare.CfClient {
+	t.Helper()
+	server := MockHTTPServer(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost || r.URL.Path != "/accounts/acc/d1/database/db-id/query" {
+			t.Errorf("unexpected request %s %s", r.Method, r.URL.Path)
+		}
+		if r.Header.Get("Authorization") != "Bearer tok" {
+			t.Errorf("Authorization = %q", r.Header.Get("Authorization"))
+		}
+		json.NewDecoder(r.Body).Decode(got)
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(status)
+		w.Write([]byte(reply))
+	})
+	t.Cleanup(server.Close)
+	return &goflare.CfClient{Token: "tok", BaseURL: server.URL, HttpClient: http.DefaultClient}
+}
+
+func TestD1Remote_QueryKeepsColumnOrder(t *testing.T) {
+	var req map[string]any
+	client := d1QueryServer(t, `{"success":true,"errors":[],"result":[{"success":true,
+		"results":[{"name":"ana","id":7,"score":1.5,"note":null},{"name":"bea","id":8,"score":2,"note":"x"}],
+		"meta":{"changes":0,"last_row_id":0,"rows_written":0,"duration":0.2}}]}`, 200, &req)
+
+	db, err := d1.NewRemote(client, "acc", "db-id")
+	if err != nil {
+		t.Fatal(err)
+	}
+	rows, err := db.RawConn().Query(`SELECT name, id, score, note FROM users WHERE id > ?`, 6)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if req["sql"] != "SELECT name, id, score, note FROM users WHERE id > ?" {
+		t.Errorf("sql = %v", req["sql"])
+	}
+	if params, _ := req["params"].([]any); len(params) != 1 || params[0] != float64(6) {
+		t.Errorf("params = %v", req["params"])
+	}
+
+	cols, _ := rows.Columns()
+	if strings.Join(cols, ",") != "name,id,score,note" {
+		t.Errorf("columns = %v, want the order of the result objects", cols)
+	}
+	var names []string
+	for rows.Next() {
+		var name, note string
+		var id int64
+		var score float64
+		if err := rows.Scan(&name, &id, &score, &note); err != nil {
+			t.Fatal(err)
+		}
+		names = append(names, name)
+	}
+	if strings.Join(names, ",") != "ana,bea" {
+		t.Errorf("rows = %v", names)
+	}
+}
+
+func TestD1Remote_ExecReportsMeta(t *testing.T) {
+	var req map[string]any
+	client := d1QueryServer(t, `{"success":true,"errors":[],"result":[{"success":true,"results":[],
+		"meta":{"changes":1,"last_row_id":42,"rows_written":2,"duration":0.5}}]}`, 200, &req)
+
+	db, _ := d1.NewRemote(client, "acc", "db-id")
+	res, err := d1.Exec(db, `INSERT INTO users (name) VALUES (?)`, "ana")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if res.LastRowID != 42 || res.Changes != 1 || res.RowsWritten != 2 {
+		t.Errorf("result = %+v", res)
+	}
+	if err := db.RawConn().Exec(`INSERT INTO files (data) VALUES (?)`, []byte{1}); err == nil {
+		t.Error("a blob parameter was sent over JSON")
+	}
+}
+
+func TestD1Remote_BatchIsOneScript(t *testing.T) {
+	var req map[string]any
+	client := d1QueryServer(t, `{"success":true,"errors":[],"result":[
+		{"success":true,"results":[],"meta":{"changes":1,"last_row_id":7,"rows_written":1,"duration":0.1}},
+		{"success":true,"results":[],"meta":{"changes":2,"last_row_id":9,"rows_written":2,"duration":0.1}}]}`, 200, &req)
+
+	db, _ := d1.NewRemote(client, "acc", "db-id")
+	res, err := d1.Batch(db,
+		d1.Statement(`INSERT INTO orders (customer, note) VALUES (?, '?');`, "o'brien"),
+		d1.Statement(`INSERT INTO order_items (order_id, qty, price) VALUES (?, ?, ?)`, 7, 2, 1.5),
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := "INSERT INTO orders (customer, note) VALUES ('o''brien', '?');\n" +
+		"INSERT INTO order_items (order_id, qty, price) VALUES (7, 2, 1.5)"
+	if req["sql"] != want {
+		t.Errorf("sql = %q\nwant %q", req["sql"], want)
+	}
+	if len(res) != 2 || res[0].LastRowID != 7 || res[1].Changes != 2 {
+		t.Errorf("results = %+v", res)
+	}
+
+	if _, err := d1.Batch(db, d1.Statement(`INSERT INTO t (a, b) VALUES (?, ?)`, 1)); err == nil {
+		t.Error("a statement missing an argument was sent")
+	}
+}
+
+func TestD1Remote_APIErrorsAreClassified(t *testing.T) {
+	var req map[string]any
+	client := d1QueryServer(t, `{"success":false,"result":null,"errors":[{"code":7500,
+		"message":"UNIQUE constraint failed: users.email: SQLITE_CONSTRAINT"}]}`, 400, &req)
+
+	db, _ := d1.NewRemote(client, "acc", "db-id")
+	err := db.RawConn().Exec(`INSERT INTO users (email) VALUES (?)`, "a@b.c")
+	if !errors.Is(err, d1.ErrUniqueViolation) || d1.Status(err) != 409 {
+		t.Errorf("err = %v, want a unique violation (409)", err)
+	}
+	if !strings.Contains(err.Error(), "users.email") {
+		t.Errorf("the API's detail was lost: %v", err)
+	}
+
+	var n int
+	if err := db.RawConn().QueryRow(`SELECT 1`).Scan(&n); err == nil {
+		t.Error("QueryRow swallowed the API error")
+	}
+}
+
+func TestD1Remote_UnknownDatabaseIsNotFound(t *testing.T) {
+	var req map[string]any
+	client := d1QueryServer(t, `{"success":false,"result":null,"errors":[{"code":7404,
+		"message":"The database db-id could not be found"}]}`, 404, &req)
+
+	db, _ := d1.NewRemote(client, "acc", "db-id")
+	err := db.RawConn().Exec(`SELECT 1`)
+	if !errors.Is(err, d1.ErrDatabaseNotFound) {
+		t.Errorf("err = %v, want ErrDatabaseNotFound", err)
+	}
+	if !strings.Contains(err.Error(), "db-id") {
+		t.Errorf("the API's detail was lost: %v", err)
+	}
+}
+
+func TestD1Remote_MissingIDs(t *testing.T) {
+	if _, err := d1.NewRemote(&goflare.CfClient{}, "acc", ""); err != d1.ErrDatabaseNotFound {
+		t.Errorf("err = %v, want ErrDatabaseNotFound", err)
+	}
+
+	os.Setenv("CLOUDFLARE_API_TOKEN", "tok")
+	defer os.Unsetenv("CLOUDFLARE_API_TOKEN")
+	g := goflare.New(&goflare.Config{AccountID: "acc"})
+	if _, err := g.RemoteD1(); !errors.Is(err, d1.ErrDatabaseNotFound) {
+		t.Errorf("RemoteD1 without D1_DATABASE_ID: err = %v", err)
+	}
+}
@@ -0,0 +1,70 @@
+//go:build !wasm
+
+package goflare_test
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/tinywasm/goflare/devserver"
+	"github.com/tinywasm/router"
+)
+
+func newDevEdge(t *testing.T) *httptest.Server {
+	t.Helper()
+	env := newTestEnv(t)
+	env.writePublic("app.js", "console.log(1)")
+
+	e := devserver.NewEdge(devserver.EdgeConfig{PublicDir: env.PublicDir, StateDir: t.TempDir()})
+	e.Router().Get("/api/hello", func(ctx router.Context) {
+		ctx.SetHeader("Content-Type", "text/plain")
+		ctx.Write([]byte("world"))
+	}).Public()
+	e.Router().Get("/api/private", func(ctx router.Context) {
+		ctx.Write([]byte("secret"))
+	}).Authenticated()
+
+	srv := httptest.NewServer(e)
+	t.Cleanup(srv.Close)
+	return srv
+}
+
+func get(t *testing.T, url string) (int, string) {
+	t.Helper()
+	resp, err := http.Get(url)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	body, _ := io.ReadAll(resp.Body)
+	return resp.StatusCode, string(body)
+}
+
+func TestDevEdge_ServesStaticAndEdgeOnOnePort(t *testing.T) {
+	srv := newDevEdge(t)
+
+	if status, body := get(t, srv.URL+"/"); status != 200 || body != "<h1>test</h1>" {
+		t.Errorf("GET / = %d %q, want index.html from PublicDir", status, body)
+	}
+	if status, body := get(t, srv.URL+"/app.js"); status != 200 || body != "console.log(1)" {
+		t.Errorf("GET /app.js = %d %q", status, body)
+	}
+	if status, body := get(t, srv.URL+"/api/hello"); status != 200 || body != "world" {
+		t.Errorf("GET /api/hello = %d %q, want the edge route", status, body)
+	}
+}
+
+func TestDevEdge_RunsTheEdgeAccessGate(t *testing.T) {
+	srv := newDevEdge(t)
+
+	// No Authn configured: the caller is anonymous, and the gate must say so exactly as the
+	// deployed Worker would.
+	if status, _ := get(t, srv.URL+"/api/private"); status != 403 {
+		t.Errorf("GET /api/private = %d, want 403", status)
+	}
+	if status, _ := get(t, srv.URL+"/api/nope"); status != 404 {
+		t.Errorf("GET /api/nope = %d, want 404", status)
+	}
+}
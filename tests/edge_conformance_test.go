@@ -1,5 +1,3 @@
-//go:build wasm
-
 package goflare_test
 
 import (
@@ -46,12 +46,20 @@ type captureRouter struct {
 	putRoute *captureRoute
 }
 
+// Get and Put keep only the routes on the Store prefix itself: the multipart routes that
+// hang off it (<prefix>uploads/) are registered with the same methods.
 func (r *captureRouter) Get(path string, h router.HandlerFunc) router.Route {
+	if path != filesPrefix {
+		return &captureRoute{}
+	}
 	r.get = h
 	r.getRoute = &captureRoute{}
 	return r.getRoute
 }
 func (r *captureRouter) Put(path string, h router.HandlerFunc) router.Route {
+	if path != filesPrefix {
+		return &captureRoute{}
+	}
 	r.put = h
 	r.putRoute = &captureRoute{}
 	return r.putRoute
@@ -140,6 +148,24 @@ func fakeBucket(store map[string][]byte, contentTypes map[string]string) js.Valu
 		return promise(obj)
 	}))
 
+	// head is get without the body: R2 answers an R2Object, or null for a missing key.
+	b.Set("head", js.FuncOf(func(_ js.Value, args []js.Value) any {
+		key := args[0].String()
+		data, ok := store[key]
+		if !ok {
+			return promise(js.Null())
+		}
+		obj := js.Global().Get("Object").New()
+		obj.Set("key", key)
+		obj.Set("size", len(data))
+		httpMetadata := js.Global().Get("Object").New()
+		if ct, ok := contentTypes[key]; ok {
+			httpMetadata.Set("contentType", ct)
+		}
+		obj.Set("httpMetadata", httpMetadata)
+		return promise(obj)
+	}))
+
 	b.Set("delete", js.FuncOf(func(_ js.Value, args []js.Value) any {
 		key := args[0].String()
 		delete(store, key)
@@ -0,0 +1,114 @@
+package goflare_test
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/tinywasm/goflare/edge"
+	"github.com/tinywasm/model"
+	"github.com/tinywasm/router"
+)
+
+// signupArgs is a route's declared input: an email, which is required.
+type signupArgs struct {
+	Email string
+}
+
+func (s *signupArgs) Schema() []model.Field {
+	return []model.Field{{Name: "email", Type: model.Text(), NotNull: true}}
+}
+func (s *signupArgs) Pointers() []any { return []any{&s.Email} }
+func (s *signupArgs) IsNil() bool     { return s == nil }
+func (s *signupArgs) DecodeFields(r model.FieldReader) {
+	if v, ok := r.String("email"); ok {
+		s.Email = v
+	}
+}
+
+func newSignupRouter(ran *bool) router.Router {
+	r := edge.NewRouter(edge.Config{})
+	r.Post("/signup", func(ctx router.Context) {
+		*ran = true
+		ctx.WriteStatus(201)
+	}).Public().Accepts(&signupArgs{})
+	return r
+}
+
+func TestEdgeValidate_MissingFieldIs400AndSkipsHandler(t *testing.T) {
+	ran := false
+	r := newSignupRouter(&ran)
+
+	ctx := &conformanceCtx{method: "POST", path: "/signup"}
+	edge.Dispatch(r, ctx)
+
+	if ctx.status != 400 {
+		t.Fatalf("status = %d, want 400", ctx.status)
+	}
+	if ran {
+		t.Error("the handler ran on input its declared model rejected")
+	}
+	if !bytes.Contains(ctx.out, []byte(`"field":"email"`)) {
+		t.Errorf("body does not name the field: %s", ctx.out)
+	}
+	if got := ctx.headers["Content-Type"]; got != "application/json" {
+		t.Errorf("Content-Type = %q, want application/json", got)
+	}
+}
+
+func TestEdgeValidate_MalformedBodyIs400(t *testing.T) {
+	ran := false
+	r := newSignupRouter(&ran)
+
+	ctx := &conformanceCtx{method: "POST", path: "/signup", body: []byte("{not json")}
+	edge.Dispatch(r, ctx)
+
+	if ctx.status != 400 {
+		t.Fatalf("status = %d, want 400", ctx.status)
+	}
+	if ran {
+		t.Error("the handler ran on a body that does not decode")
+	}
+}
+
+func TestEdgeValidate_DeclaredValueIsNeverWritten(t *testing.T) {
+	declared := &signupArgs{}
+	r := edge.NewRouter(edge.Config{})
+	r.Post("/signup", func(ctx router.Context) { ctx.WriteStatus(201) }).Public().Accepts(declared)
+
+	ctx := &conformanceCtx{method: "POST", path: "/signup", body: []byte(`{"email":"a@b.c"}`)}
+	edge.Dispatch(r, ctx)
+
+	if declared.Email != "" {
+		t.Errorf("the declared model was decoded into: Email = %q", declared.Email)
+	}
+}
+
+// nestedArgs has a field edge cannot copy per request.
+type nestedArgs struct{ Inner signupArgs }
+
+func (n *nestedArgs) Schema() []model.Field { return []model.Field{{Name: "inner"}} }
+func (n *nestedArgs) Pointers() []any       { return []any{&n.Inner} }
+
+func TestEdgeValidate_UncheckableModelRefusesToStart(t *testing.T) {
+	r := edge.NewRouter(edge.Config{})
+	r.Post("/nested", func(ctx router.Context) {}).Public().Accepts(&nestedArgs{})
+
+	defer func() {
+		if recover() == nil {
+			t.Error("Validate accepted a model whose fields cannot be checked")
+		}
+	}()
+	edge.Validate(r)
+}
+
+func TestEdgeValidate_RouteWithoutAcceptsIsUntouched(t *testing.T) {
+	r := edge.NewRouter(edge.Config{})
+	r.Post("/free", func(ctx router.Context) { ctx.WriteStatus(204) }).Public()
+
+	ctx := &conformanceCtx{method: "POST", path: "/free", body: []byte("{not json")}
+	edge.Dispatch(r, ctx)
+
+	if ctx.status != 204 {
+		t.Errorf("status = %d, want 204: no declared model, nothing to enforce", ctx.status)
+	}
+}
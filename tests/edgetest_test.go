@@ -0,0 +1,77 @@
+package goflare_test
+
+import (
+	"testing"
+
+	"github.com/tinywasm/goflare/edge"
+	"github.com/tinywasm/goflare/edge/edgetest"
+	"github.com/tinywasm/router"
+)
+
+// sessionAuthn reads the identity from a cookie, the way a deployed app does.
+func sessionAuthn(next router.HandlerFunc) router.HandlerFunc {
+	return func(ctx router.Context) {
+		if c, ok := ctx.Cookie("session"); ok {
+			ctx.SetUserID(c.Value)
+		}
+		next(ctx)
+	}
+}
+
+func newMeRouter() router.Router {
+	r := edge.NewRouter(edge.Config{Authn: sessionAuthn})
+	r.Get("/api/me", func(ctx router.Context) {
+		ctx.SetHeader("Content-Type", "text/plain")
+		ctx.SetCookie(router.Cookie{Name: "seen", Value: "1", Path: "/"})
+		ctx.Write([]byte(ctx.UserID()))
+	}).Authenticated()
+	r.Get("/api/health", func(ctx router.Context) {
+		ctx.Write([]byte("ok"))
+	}).Public()
+	return r
+}
+
+func TestEdgetest_AuthnCookieReachesTheHandler(t *testing.T) {
+	c := edgetest.Serve(newMeRouter(), edgetest.NewRequest("GET", "/api/me").WithCookie("session", "u1"))
+
+	c.ExpectStatus(t, 200)
+	c.ExpectBody(t, "u1")
+	c.ExpectHeader(t, "Content-Type", "text/plain")
+	if got := c.SetCookies(); len(got) != 1 || got[0].Name != "seen" {
+		t.Errorf("SetCookies = %v, want the one cookie the handler set", got)
+	}
+}
+
+func TestEdgetest_AnonymousIsRefusedByTheGate(t *testing.T) {
+	c := edgetest.Serve(newMeRouter(), edgetest.NewRequest("GET", "/api/me"))
+
+	c.ExpectStatus(t, 403)
+}
+
+func TestEdgetest_WithUserPresetsTheIdentity(t *testing.T) {
+	c := edgetest.Serve(newMeRouter(), edgetest.NewRequest("GET", "/api/me").WithUser("u2"))
+
+	c.ExpectStatus(t, 200)
+	c.ExpectBody(t, "u2")
+}
+
+func TestEdgetest_UnknownPathAndWrongMethod(t *testing.T) {
+	r := newMeRouter()
+
+	edgetest.Serve(r, edgetest.NewRequest("GET", "/nope")).ExpectStatus(t, 404)
+	edgetest.Serve(r, edgetest.NewRequest("POST", "/api/health")).ExpectStatus(t, 405)
+}
+
+func TestEdgetest_RequestHeadersMatchInAnyCase(t *testing.T) {
+	r := edge.NewRouter(edge.Config{})
+	r.Get("/echo", func(ctx router.Context) {
+		ctx.Write([]byte(ctx.GetHeader("Content-Type") + "|" + ctx.GetHeader("x-trace")))
+	}).Public()
+
+	// The Workers runtime hands header names lowercased; a client may send any case.
+	c := edgetest.Serve(r, edgetest.NewRequest("GET", "/echo").
+		WithHeader("content-type", "text/csv").
+		WithHeader("X-Trace", "t1"))
+
+	c.ExpectBody(t, "text/csv|t1")
+}
@@ -0,0 +1,102 @@
+//go:build !wasm
+
+package goflare_test
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"sync"
+	"testing"
+
+	"github.com/tinywasm/goflare/edge/edgetest"
+	"github.com/tinywasm/goflare/files"
+	"github.com/tinywasm/goflare/webcrypto"
+)
+
+// memRefs is a Refs in memory.
+type memRefs struct {
+	mu sync.Mutex
+	n  map[[2]string]int64
+}
+
+func (m *memRefs) Ref(key, owner string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.n[[2]string{key, owner}]++
+	return nil
+}
+
+func (m *memRefs) Holds(key, owner string) (bool, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return m.n[[2]string{key, owner}] > 0, nil
+}
+
+func (m *memRefs) Unref(key, owner string) (int64, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if k := [2]string{key, owner}; m.n[k] > 0 {
+		m.n[k]--
+	}
+	var total int64
+	for k, n := range m.n {
+		if k[0] == key {
+			total += n
+		}
+	}
+	return total, nil
+}
+
+func TestWebcrypto_SHA256(t *testing.T) {
+	sum, err := webcrypto.SHA256([]byte("abc"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := webcrypto.Hex(sum); got != "ba7816bf8f01cfea414140de5dae2223b00361a396177a9cb410ff61f20015ad" {
+		t.Errorf("SHA256(abc) = %s", got)
+	}
+}
+
+func TestFilesDedup_SameBytesOneObject(t *testing.T) {
+	r, b := newFilesStore(t, func(s *files.Store) { s.ContentAddressed(nil) })
+	sum := sha256.Sum256(logoPNG)
+	want := hex.EncodeToString(sum[:]) + ".png"
+
+	first := putFile(r, "u1", logoPNG)
+	first.ExpectStatus(t, 201)
+	second := putFile(r, "u2", logoPNG)
+	second.ExpectStatus(t, 200) // found, not written
+	for _, c := range []*edgetest.Context{first, second} {
+		if key := string(c.ResponseBody()); key != want {
+			t.Errorf("key = %q, want %q", key, want)
+		}
+	}
+	objects, err := b.List("")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(objects) != 1 {
+		t.Errorf("%d objects stored, want 1", len(objects))
+	}
+	serveKey(r, want).ExpectHeader(t, "Cache-Control", "public, max-age=31536000, immutable")
+
+	// Without references nobody knows who still uses a file, so it cannot be deleted.
+	edgetest.Serve(r, edgetest.NewRequest("DELETE", "/api/files/"+want).WithUser("u1")).
+		ExpectStatus(t, 405)
+}
+
+func TestFilesDedup_DeleteDropsAReferenceAndTheLastOneTheObject(t *testing.T) {
+	r, _ := newFilesStore(t, func(s *files.Store) { s.ContentAddressed(&memRefs{n: map[[2]string]int64{}}) })
+	key := uploadAs(t, r, "u1")
+	putFile(r, "u2", logoPNG).ExpectStatus(t, 200)
+	del := func(user string) *edgetest.Context {
+		return edgetest.Serve(r, edgetest.NewRequest("DELETE", "/api/files/"+key).WithUser(user))
+	}
+
+	del("u3").ExpectStatus(t, 404) // never uploaded it
+	del("u1").ExpectStatus(t, 204)
+	serveKey(r, key).ExpectStatus(t, 200) // u2 still uses it
+	del("u1").ExpectStatus(t, 404)
+	del("u2").ExpectStatus(t, 204)
+	serveKey(r, key).ExpectStatus(t, 404)
+}
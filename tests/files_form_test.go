@@ -0,0 +1,107 @@
+//go:build !wasm
+
+package goflare_test
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/tinywasm/goflare/edge/edgetest"
+	"github.com/tinywasm/goflare/files"
+	"github.com/tinywasm/router"
+)
+
+const formBoundary = "----form7MA4YWxkTrZu0gW"
+
+// formBody builds what a browser sends for a form: text fields, then one file under field.
+func formBody(field, filename string, file []byte, fields ...string) []byte {
+	var b bytes.Buffer
+	for i := 0; i+1 < len(fields); i += 2 {
+		b.WriteString("--" + formBoundary + "\r\n")
+		b.WriteString(`Content-Disposition: form-data; name="` + fields[i] + `"` + "\r\n\r\n")
+		b.WriteString(fields[i+1] + "\r\n")
+	}
+	b.WriteString("--" + formBoundary + "\r\n")
+	b.WriteString(`Content-Disposition: form-data; name="` + field + `"; filename="` + filename + `"` + "\r\n")
+	b.WriteString("Content-Type: image/png\r\n\r\n")
+	b.Write(file)
+	b.WriteString("\r\n--" + formBoundary + "--\r\n")
+	return b.Bytes()
+}
+
+func postForm(r router.Router, user string, body []byte) *edgetest.Context {
+	return edgetest.Serve(r, edgetest.NewRequest("POST", "/api/files/").
+		WithUser(user).
+		WithHeader("Content-Type", "multipart/form-data; boundary="+formBoundary).
+		WithBody(body))
+}
+
+func TestParseForm_FieldsAndFile(t *testing.T) {
+	body := formBody("file", "a;b.png", logoPNG, "caption", "a red logo", "alt", "")
+	f, err := files.ParseForm(body, `multipart/form-data; boundary="`+formBoundary+`"`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if f.Fields["caption"] != "a red logo" {
+		t.Errorf("caption = %q", f.Fields["caption"])
+	}
+	if v, ok := f.Fields["alt"]; !ok || v != "" {
+		t.Errorf("an empty field must still be there: %q, %v", v, ok)
+	}
+	file, ok := f.File("file")
+	if !ok {
+		t.Fatal("no file part")
+	}
+	if file.Filename != "a;b.png" || file.ContentType != "image/png" || !bytes.Equal(file.Data, logoPNG) {
+		t.Errorf("file = %q %q %d bytes", file.Filename, file.ContentType, len(file.Data))
+	}
+
+	if _, err := files.ParseForm(body[:len(body)-10], "multipart/form-data; boundary="+formBoundary); err == nil {
+		t.Error("a body cut before its closing delimiter must be refused")
+	}
+	if _, err := files.ParseForm(body, "multipart/form-data"); err == nil {
+		t.Error("a form without a boundary must be refused")
+	}
+}
+
+func TestFilesForm_UploadKeepsOnlyTheNamedFields(t *testing.T) {
+	r, b := newFilesStore(t, func(s *files.Store) { s.KeepFields("caption") })
+
+	c := postForm(r, "u1", formBody("file", "logo.png", logoPNG, "caption", "a red logo", "owner", "u2"))
+	c.ExpectStatus(t, 201)
+	info, err := b.Head(string(c.ResponseBody()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if info.CustomMetadata["form-caption"] != "a red logo" {
+		t.Errorf("caption not kept: %v", info.CustomMetadata)
+	}
+	if info.CustomMetadata["owner"] != "u1" || info.CustomMetadata["form-owner"] != "" {
+		t.Errorf("a form field must not touch the owner: %v", info.CustomMetadata)
+	}
+}
+
+func TestFilesForm_SameChecksAsARawUpload(t *testing.T) {
+	r, _ := newFilesStore(t, func(s *files.Store) { s.MaxSize(len(logoPNG)) })
+
+	svg := []byte("<svg></svg>")
+	postForm(r, "u1", formBody("file", "logo.png", svg[:len(logoPNG)])).ExpectStatus(t, 415) // the filename is not the type
+	postForm(r, "u1", formBody("file", "big.png", append(bytes.Clone(logoPNG), 0))).ExpectStatus(t, 413)
+	postForm(r, "u1", formBody("avatar", "logo.png", logoPNG)).ExpectStatus(t, 400)
+	postForm(r, "u1", []byte("not a form")).ExpectStatus(t, 400)
+	postForm(r, "u1", formBody("file", "logo.png", logoPNG)).ExpectStatus(t, 201)
+}
+
+func TestFilesForm_LowercaseContentTypeIsAForm(t *testing.T) {
+	r, b := newFilesStore(t, nil)
+
+	// The Workers runtime hands header names lowercased.
+	c := edgetest.Serve(r, edgetest.NewRequest("POST", "/api/files/").
+		WithUser("u1").
+		WithHeader("content-type", "multipart/form-data; boundary="+formBoundary).
+		WithBody(formBody("file", "logo.png", logoPNG)))
+	c.ExpectStatus(t, 201)
+	if got, _, err := b.Get(string(c.ResponseBody())); err != nil || !bytes.Equal(got, logoPNG) {
+		t.Errorf("stored %d bytes, %v: the file must be parsed out of the form", len(got), err)
+	}
+}
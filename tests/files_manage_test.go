@@ -0,0 +1,92 @@
+//go:build !wasm
+
+package goflare_test
+
+import (
+	"testing"
+
+	"github.com/tinywasm/fmt"
+	"github.com/tinywasm/goflare/edge/edgetest"
+	"github.com/tinywasm/goflare/files"
+	"github.com/tinywasm/model"
+	"github.com/tinywasm/router"
+)
+
+// allowOnly grants the listed actions and nothing else.
+func allowOnly(actions ...model.Action) model.Authorizer {
+	return func(_ string, _ model.Resource, action model.Action) bool {
+		for _, x := range actions {
+			if x == action {
+				return true
+			}
+		}
+		return false
+	}
+}
+
+func uploadAs(t *testing.T, r router.Router, user string) string {
+	t.Helper()
+	c := edgetest.Serve(r, edgetest.NewRequest("PUT", "/api/files/").
+		WithUser(user).
+		WithBody(logoPNG).
+		WithHeader("Content-Length", fmt.Convert(len(logoPNG)).String()))
+	c.ExpectStatus(t, 201)
+	return string(c.ResponseBody())
+}
+
+func TestFilesManage_ListShowsOnlyTheCallersFiles(t *testing.T) {
+	r, _ := newFilesStore(t, nil)
+	mine := uploadAs(t, r, "u1")
+	uploadAs(t, r, "u2")
+	startUpload(t, r, "u1", 20, "image/png").ExpectStatus(t, 201) // its record is not a file
+
+	c := edgetest.Serve(r, edgetest.NewRequest("GET", "/api/files").WithUser("u1"))
+	c.ExpectStatus(t, 200)
+	c.ExpectBody(t, mine+"\t"+fmt.Convert(len(logoPNG)).String()+"\timage/png\n")
+}
+
+func TestFilesManage_DeleteOnlyTheCallersFile(t *testing.T) {
+	r, b := newFilesStore(t, nil)
+	key := uploadAs(t, r, "u1")
+
+	edgetest.Serve(r, edgetest.NewRequest("DELETE", "/api/files/"+key).WithUser("u2")).
+		ExpectStatus(t, 404) // someone else's is a missing one
+	if _, err := b.Head(key); err != nil {
+		t.Fatalf("another caller's delete must leave the file: %v", err)
+	}
+
+	edgetest.Serve(r, edgetest.NewRequest("DELETE", "/api/files/"+key).WithUser("u1")).
+		ExpectStatus(t, 204)
+	if _, err := b.Head(key); err == nil {
+		t.Error("the owner's delete must remove the file")
+	}
+	edgetest.Serve(r, edgetest.NewRequest("DELETE", "/api/files/"+key).WithUser("u1")).
+		ExpectStatus(t, 404)
+}
+
+func TestFilesManage_PerOwnerDeletesTheCallersOneObject(t *testing.T) {
+	r, b := newFilesStore(t, func(s *files.Store) { s.PerOwner() })
+	uploadAs(t, r, "u1")
+	uploadAs(t, r, "u2")
+
+	edgetest.Serve(r, edgetest.NewRequest("GET", "/api/files").WithUser("u1")).
+		ExpectBody(t, "u1\t"+fmt.Convert(len(logoPNG)).String()+"\timage/png\n")
+	edgetest.Serve(r, edgetest.NewRequest("DELETE", "/api/files/u2").WithUser("u1")).
+		ExpectStatus(t, 404)
+	edgetest.Serve(r, edgetest.NewRequest("DELETE", "/api/files/").WithUser("u1")).
+		ExpectStatus(t, 204)
+	if _, err := b.Head("u1"); err == nil {
+		t.Error("u1's avatar must be gone")
+	}
+	if _, err := b.Head("u2"); err != nil {
+		t.Errorf("u2's avatar must stay: %v", err)
+	}
+}
+
+func TestFilesManage_EachRouteHasItsOwnAction(t *testing.T) {
+	r, _ := mountFiles(t, allowOnly(model.Create), nil)
+	key := uploadAs(t, r, "u1")
+
+	edgetest.Serve(r, edgetest.NewRequest("GET", "/api/files").WithUser("u1")).ExpectStatus(t, 403)
+	edgetest.Serve(r, edgetest.NewRequest("DELETE", "/api/files/"+key).WithUser("u1")).ExpectStatus(t, 403)
+}
@@ -0,0 +1,118 @@
+//go:build !wasm
+
+package goflare_test
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/tinywasm/fmt"
+	"github.com/tinywasm/goflare/edge/edgetest"
+	"github.com/tinywasm/goflare/r2"
+	"github.com/tinywasm/router"
+)
+
+// startUpload begins a multipart upload as user and returns its token.
+func startUpload(t *testing.T, r router.Router, user string, size int, mime string) *edgetest.Context {
+	t.Helper()
+	return edgetest.Serve(r, edgetest.NewRequest("POST", "/api/files/uploads/").
+		WithUser(user).
+		WithHeader("X-Upload-Content-Length", fmt.Convert(size).String()).
+		WithHeader("X-Upload-Content-Type", mime))
+}
+
+func sendPart(r router.Router, user, token string, n int, data []byte) *edgetest.Context {
+	return edgetest.Serve(r, edgetest.NewRequest("PUT", "/api/files/uploads/"+token+"/"+fmt.Convert(n).String()).
+		WithUser(user).
+		WithBody(data).
+		WithHeader("Content-Length", fmt.Convert(len(data)).String()))
+}
+
+func TestFilesMultipart_PartsAssembleIntoOneServedFile(t *testing.T) {
+	r, b := newFilesStore(t, nil)
+	first := append(append([]byte{}, logoPNG...), bytes.Repeat([]byte{1}, r2.MinPartSize-len(logoPNG))...)
+	last := []byte("the tail")
+	total := len(first) + len(last)
+	s := startUpload(t, r, "u1", total, "image/png")
+	s.ExpectStatus(t, 201)
+	token := string(s.ResponseBody())
+
+	// Out of order, and the status says what arrived: what a resuming client asks.
+	sendPart(r, "u1", token, 2, last).ExpectStatus(t, 200)
+	status := edgetest.Serve(r, edgetest.NewRequest("GET", "/api/files/uploads/"+token).WithUser("u1"))
+	status.ExpectBody(t, "2\n")
+	early := edgetest.Serve(r, edgetest.NewRequest("POST", "/api/files/uploads/"+token).WithUser("u1"))
+	early.ExpectStatus(t, 400) // part 1 is missing
+
+	sendPart(r, "u1", token, 1, first).ExpectStatus(t, 200)
+	done := edgetest.Serve(r, edgetest.NewRequest("POST", "/api/files/uploads/"+token).WithUser("u1"))
+	done.ExpectStatus(t, 201)
+	key := string(done.ResponseBody())
+
+	got, ct, err := b.Get(key)
+	if err != nil || ct != "image/png" || !bytes.Equal(got, append(first, last...)) {
+		t.Fatalf("assembled object: %d bytes, %q, %v", len(got), ct, err)
+	}
+	if list, _ := b.List(".uploads/"); len(list) != 0 {
+		t.Errorf("a completed upload must leave no records behind: %+v", list)
+	}
+}
+
+func TestFilesMultipart_FirstPartMustBeTheAnnouncedType(t *testing.T) {
+	r, _ := newFilesStore(t, nil)
+
+	startUpload(t, r, "u1", 100, "image/svg+xml").ExpectStatus(t, 415)
+
+	s := startUpload(t, r, "u1", 100, "image/png")
+	token := string(s.ResponseBody())
+	sendPart(r, "u1", token, 1, []byte("<svg><script>alert(1)</script></svg>")).ExpectStatus(t, 415)
+	sendPart(r, "u1", token, 2, []byte("x")).ExpectStatus(t, 200) // only part 1 is sniffed
+}
+
+func TestFilesMultipart_TotalSizeIsCheckedAcrossParts(t *testing.T) {
+	r, _ := newFilesStore(t, nil)
+
+	startUpload(t, r, "u1", 11<<20, "image/png").ExpectStatus(t, 413) // over the 10 MiB default
+
+	s := startUpload(t, r, "u1", 20, "image/png")
+	token := string(s.ResponseBody())
+	sendPart(r, "u1", token, 1, logoPNG).ExpectStatus(t, 200)
+	sendPart(r, "u1", token, 2, bytes.Repeat([]byte{0}, 11)).ExpectStatus(t, 413) // 10+11 > 20
+	sendPart(r, "u1", token, 2, bytes.Repeat([]byte{0}, 5)).ExpectStatus(t, 200)
+
+	short := edgetest.Serve(r, edgetest.NewRequest("POST", "/api/files/uploads/"+token).WithUser("u1"))
+	short.ExpectStatus(t, 400) // 15 of the 20 bytes announced
+}
+
+func TestFilesMultipart_OnlyTheStarterMayContinueOrAbort(t *testing.T) {
+	r, b := newFilesStore(t, nil)
+	s := startUpload(t, r, "u1", 20, "image/png")
+	token := string(s.ResponseBody())
+
+	sendPart(r, "u2", token, 1, logoPNG).ExpectStatus(t, 404)
+	edgetest.Serve(r, edgetest.NewRequest("DELETE", "/api/files/uploads/"+token).WithUser("u2")).
+		ExpectStatus(t, 404)
+
+	edgetest.Serve(r, edgetest.NewRequest("DELETE", "/api/files/uploads/"+token).WithUser("u1")).
+		ExpectStatus(t, 204)
+	sendPart(r, "u1", token, 1, logoPNG).ExpectStatus(t, 404)
+	if list, _ := b.List(""); len(list) != 0 {
+		t.Errorf("an aborted upload must leave nothing behind: %+v", list)
+	}
+
+	edgetest.Serve(r, edgetest.NewRequest("GET", "/api/files/.uploads/"+token)).ExpectStatus(t, 404)
+}
+
+func TestFilesMultipart_LowercaseHeadersStartAnUpload(t *testing.T) {
+	r, _ := newFilesStore(t, nil)
+
+	// The Workers runtime hands header names lowercased.
+	c := edgetest.Serve(r, edgetest.NewRequest("POST", "/api/files/uploads/").
+		WithUser("u1").
+		WithHeader("x-upload-content-length", fmt.Convert(r2.MinPartSize+1).String()).
+		WithHeader("x-upload-content-type", "image/png"))
+	c.ExpectStatus(t, 201)
+	if len(c.ResponseBody()) == 0 {
+		t.Error("no upload token")
+	}
+}
@@ -0,0 +1,131 @@
+//go:build !wasm
+
+package goflare_test
+
+import (
+	"bytes"
+	"sync"
+	"testing"
+
+	"github.com/tinywasm/goflare/d1"
+	"github.com/tinywasm/goflare/edge/edgetest"
+	"github.com/tinywasm/goflare/files"
+	"github.com/tinywasm/router"
+)
+
+// memCounter is a Counter in memory: what a test needs, and what a single process could use.
+type memCounter struct {
+	mu    sync.Mutex
+	usage map[string]files.Quota
+}
+
+func (c *memCounter) Add(owner string, bytes, objects int64, q files.Quota) (bool, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	u := c.usage[owner]
+	u.Bytes += bytes
+	u.Objects += objects
+	if (q.Bytes > 0 && u.Bytes > q.Bytes) || (q.Objects > 0 && u.Objects > q.Objects) {
+		return false, nil
+	}
+	c.usage[owner] = u
+	return true, nil
+}
+
+func quotaStore(t *testing.T, q files.Quota, perOwner bool) (router.Router, *memCounter) {
+	t.Helper()
+	c := &memCounter{usage: map[string]files.Quota{}}
+	r, _ := newFilesStore(t, func(s *files.Store) {
+		s.Quota(q, c)
+		if perOwner {
+			s.PerOwner()
+		}
+	})
+	return r, c
+}
+
+func TestFilesQuota_BytesAreCountedAndDeleteGivesThemBack(t *testing.T) {
+	size := int64(len(logoPNG))
+	r, c := quotaStore(t, files.Quota{Bytes: size + size/2}, false)
+
+	first := uploadAs(t, r, "u1")
+	putFile(r, "u1", logoPNG).ExpectStatus(t, 507) // the second does not fit
+	uploadAs(t, r, "u2")                           // someone else's quota is their own
+
+	edgetest.Serve(r, edgetest.NewRequest("DELETE", "/api/files/"+first).WithUser("u1")).
+		ExpectStatus(t, 204)
+	if u := c.usage["u1"]; u.Bytes != 0 || u.Objects != 0 {
+		t.Errorf("after the delete, usage = %+v", u)
+	}
+	uploadAs(t, r, "u1")
+}
+
+func TestFilesQuota_ObjectCountIsCapped(t *testing.T) {
+	r, _ := quotaStore(t, files.Quota{Objects: 2}, false)
+
+	uploadAs(t, r, "u1")
+	uploadAs(t, r, "u1")
+	putFile(r, "u1", logoPNG).ExpectStatus(t, 507)
+}
+
+func TestFilesQuota_PerOwnerReplacementCountsOnlyTheDifference(t *testing.T) {
+	r, c := quotaStore(t, files.Quota{Objects: 1}, true)
+
+	uploadAs(t, r, "u1")
+	uploadAs(t, r, "u1") // replaces, so still one file
+	if u := c.usage["u1"]; u.Objects != 1 || u.Bytes != int64(len(logoPNG)) {
+		t.Errorf("usage = %+v, want one file of %d bytes", u, len(logoPNG))
+	}
+}
+
+func TestFilesQuota_MultipartCountsOnStartAndReleasesOnAbort(t *testing.T) {
+	r, c := quotaStore(t, files.Quota{Bytes: 85}, false)
+
+	startUpload(t, r, "u1", 200, "image/png").ExpectStatus(t, 507)
+	s := startUpload(t, r, "u1", 80, "image/png")
+	s.ExpectStatus(t, 201)
+	putFile(r, "u1", logoPNG).ExpectStatus(t, 507) // the 80 announced leave no room for the logo
+
+	edgetest.Serve(r, edgetest.NewRequest("DELETE", "/api/files/uploads/"+string(s.ResponseBody())).WithUser("u1")).
+		ExpectStatus(t, 204)
+	if u := c.usage["u1"]; u.Bytes != 0 {
+		t.Errorf("after the abort, usage = %+v", u)
+	}
+}
+
+func TestFilesQuota_D1CounterChecksInTheStatement(t *testing.T) {
+	db, _ := d1.NewLocal(":memory:")
+	defer db.Close()
+	if err := db.RawConn().Exec(files.UsageSchema); err != nil {
+		t.Fatal(err)
+	}
+	c := files.NewD1Counter(db)
+	q := files.Quota{Bytes: 100, Objects: 2}
+
+	for _, step := range []struct {
+		bytes, objects int64
+		fits           bool
+	}{
+		{150, 1, false}, // bigger than the whole quota
+		{60, 1, true},   // inserts the row
+		{30, 1, true},
+		{20, 0, false}, // 110 bytes
+		{5, 1, false},  // a third file
+		{-60, -1, true},
+		{20, 1, true},
+	} {
+		ok, err := c.Add("u1", step.bytes, step.objects, q)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if ok != step.fits {
+			t.Errorf("Add(%d, %d) = %v, want %v", step.bytes, step.objects, ok, step.fits)
+		}
+	}
+}
+
+func putFile(r router.Router, user string, body []byte) *edgetest.Context {
+	return edgetest.Serve(r, edgetest.NewRequest("PUT", "/api/files/").
+		WithUser(user).
+		WithBody(bytes.Clone(body)))
+}
@@ -0,0 +1,106 @@
+//go:build !wasm
+
+package goflare_test
+
+import (
+	"bytes"
+	"net/http"
+	"testing"
+
+	"github.com/tinywasm/fmt"
+	"github.com/tinywasm/goflare/edge/edgetest"
+	"github.com/tinywasm/goflare/files"
+	"github.com/tinywasm/router"
+)
+
+func serveKey(r router.Router, key string, headers ...string) *edgetest.Context {
+	req := edgetest.NewRequest("GET", "/api/files/"+key)
+	for i := 0; i+1 < len(headers); i += 2 {
+		req.WithHeader(headers[i], headers[i+1])
+	}
+	return edgetest.Serve(r, req)
+}
+
+func TestFilesServe_ValidatorsAnswer304(t *testing.T) {
+	r, _ := newFilesStore(t, nil)
+	key := uploadAs(t, r, "u1")
+
+	full := serveKey(r, key)
+	full.ExpectStatus(t, 200)
+	full.ExpectHeader(t, "Cache-Control", "public, max-age=31536000, immutable")
+	full.ExpectHeader(t, "Accept-Ranges", "bytes")
+	etag, modified := full.Header("ETag"), full.Header("Last-Modified")
+	if len(etag) < 3 || etag[0] != '"' {
+		t.Fatalf("ETag = %q, want a quoted tag", etag)
+	}
+	if _, err := http.ParseTime(modified); err != nil {
+		t.Fatalf("Last-Modified %q is not an HTTP date: %v", modified, err)
+	}
+
+	c := serveKey(r, key, "If-None-Match", `"other", W/`+etag)
+	c.ExpectStatus(t, 304)
+	c.ExpectHeader(t, "ETag", etag)
+	if len(c.ResponseBody()) != 0 {
+		t.Error("a 304 carries no body")
+	}
+	serveKey(r, key, "If-Modified-Since", modified).ExpectStatus(t, 304)
+	serveKey(r, key, "If-Modified-Since", "Sun, 06 Nov 1994 08:49:37 GMT").ExpectStatus(t, 200)
+	// If-None-Match wins: a stale tag is a full answer, whatever the date says.
+	serveKey(r, key, "If-None-Match", `"stale"`, "If-Modified-Since", modified).ExpectStatus(t, 200)
+}
+
+func TestFilesServe_RangeAnswers206(t *testing.T) {
+	r, _ := newFilesStore(t, nil)
+	key := uploadAs(t, r, "u1")
+	size := len(logoPNG)
+
+	c := serveKey(r, key, "Range", "bytes=2-5")
+	c.ExpectStatus(t, 206)
+	c.ExpectHeader(t, "Content-Range", "bytes 2-5/"+fmt.Convert(size).String())
+	c.ExpectHeader(t, "Content-Length", "4")
+	if !bytes.Equal(c.ResponseBody(), logoPNG[2:6]) {
+		t.Errorf("range body = %v, want %v", c.ResponseBody(), logoPNG[2:6])
+	}
+
+	tail := serveKey(r, key, "Range", "bytes=-3")
+	tail.ExpectStatus(t, 206)
+	if !bytes.Equal(tail.ResponseBody(), logoPNG[size-3:]) {
+		t.Errorf("suffix body = %v", tail.ResponseBody())
+	}
+
+	past := serveKey(r, key, "Range", "bytes="+fmt.Convert(size).String()+"-")
+	past.ExpectStatus(t, 416)
+	past.ExpectHeader(t, "Content-Range", "bytes */"+fmt.Convert(size).String())
+
+	// An If-Range that no longer names the object: the parts would not fit, send it whole.
+	stale := serveKey(r, key, "Range", "bytes=2-5", "If-Range", `"stale"`)
+	stale.ExpectStatus(t, 200)
+	if !bytes.Equal(stale.ResponseBody(), logoPNG) {
+		t.Error("a failed If-Range must answer the whole object")
+	}
+	etag := serveKey(r, key).Header("ETag")
+	serveKey(r, key, "Range", "bytes=2-5", "If-Range", etag).ExpectStatus(t, 206)
+}
+
+func TestFilesServe_LowercaseConditionalHeaders(t *testing.T) {
+	r, _ := newFilesStore(t, nil)
+	key := uploadAs(t, r, "u1")
+	full := serveKey(r, key)
+	etag, modified := full.Header("ETag"), full.Header("Last-Modified")
+
+	// The Workers runtime hands header names lowercased.
+	serveKey(r, key, "if-none-match", etag).ExpectStatus(t, 304)
+	serveKey(r, key, "if-modified-since", modified).ExpectStatus(t, 304)
+	serveKey(r, key, "range", "bytes=2-5").ExpectStatus(t, 206)
+	serveKey(r, key, "range", "bytes=2-5", "if-range", `"stale"`).ExpectStatus(t, 200)
+}
+
+func TestFilesServe_CachePolicyFollowsTheKeys(t *testing.T) {
+	var s *files.Store
+	r, _ := newFilesStore(t, func(st *files.Store) { s = st.PerOwner() })
+	uploadAs(t, r, "u1")
+	serveKey(r, "u1").ExpectHeader(t, "Cache-Control", "no-cache")
+
+	s.CacheControl("public, max-age=60")
+	serveKey(r, "u1").ExpectHeader(t, "Cache-Control", "public, max-age=60")
+}
@@ -0,0 +1,84 @@
+//go:build !wasm
+
+package goflare_test
+
+import (
+	"testing"
+
+	"github.com/tinywasm/fmt"
+	"github.com/tinywasm/goflare/edge/edgetest"
+	"github.com/tinywasm/goflare/files"
+	"github.com/tinywasm/router"
+)
+
+// privateStore is a Private Store, its secret set, and the Store to mint URLs with.
+func privateStore(t *testing.T) (router.Router, *files.Store) {
+	t.Helper()
+	t.Setenv("FILES_URL_SECRET", "test-secret")
+	var s *files.Store
+	r, _ := newFilesStore(t, func(st *files.Store) { s = st.Private("FILES_URL_SECRET") })
+	return r, s
+}
+
+// fetchURL requests a path that may carry a query, as a browser following the URL would.
+func fetchURL(r router.Router, url, user string) *edgetest.Context {
+	path, query := url, ""
+	if i := fmt.Index(url, "?"); i >= 0 {
+		path, query = url[:i], url[i+1:]
+	}
+	return edgetest.Serve(r, edgetest.NewRequest("GET", path).WithQuery(query).WithUser(user))
+}
+
+func TestFilesSign_PrivateServesOnlySignedURLs(t *testing.T) {
+	r, _ := privateStore(t)
+	key := uploadAs(t, r, "u1")
+
+	fetchURL(r, "/api/files/"+key, "").ExpectStatus(t, 403)
+
+	minted := fetchURL(r, "/api/files/.sign/"+key, "u1")
+	minted.ExpectStatus(t, 200)
+	url := string(minted.ResponseBody())
+	fetchURL(r, url, "").ExpectStatus(t, 200) // unbound: an <img src> needs no identity
+
+	tampered := url[:len(url)-1] + "0"
+	if tampered == url {
+		tampered = url[:len(url)-1] + "1"
+	}
+	fetchURL(r, tampered, "").ExpectStatus(t, 403)
+	fetchURL(r, "/api/files/other.png"+url[len("/api/files/")+len(key):], "").ExpectStatus(t, 403) // another key
+}
+
+func TestFilesSign_OnlyTheOwnerCanMintAURL(t *testing.T) {
+	r, _ := privateStore(t)
+	key := uploadAs(t, r, "u1")
+
+	fetchURL(r, "/api/files/.sign/"+key, "u2").ExpectStatus(t, 404)
+}
+
+func TestFilesSign_ExpiredAndBoundURLsAreRefused(t *testing.T) {
+	r, s := privateStore(t)
+	key := uploadAs(t, r, "u1")
+
+	expired, err := s.SignURL(key, -1, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	fetchURL(r, expired, "").ExpectStatus(t, 403)
+
+	bound := string(fetchURL(r, "/api/files/.sign/"+key+"?bind=1", "u1").ResponseBody())
+	fetchURL(r, bound, "u2").ExpectStatus(t, 403)
+	fetchURL(r, bound, "").ExpectStatus(t, 403)
+	fetchURL(r, bound, "u1").ExpectStatus(t, 200)
+}
+
+func TestFilesSign_MissingSecretFailsClosed(t *testing.T) {
+	r, s := privateStore(t)
+	key := uploadAs(t, r, "u1")
+	url, err := s.SignURL(key, 60, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	t.Setenv("FILES_URL_SECRET", "")
+	fetchURL(r, url, "").ExpectStatus(t, 500)
+}
@@ -0,0 +1,132 @@
+//go:build !wasm
+
+package goflare_test
+
+import (
+	"bytes"
+	"io"
+	"strings"
+	"testing"
+
+	"github.com/tinywasm/fmt"
+	"github.com/tinywasm/goflare/edge"
+	"github.com/tinywasm/goflare/edge/edgetest"
+	"github.com/tinywasm/goflare/files"
+	"github.com/tinywasm/goflare/r2"
+	"github.com/tinywasm/model"
+	"github.com/tinywasm/router"
+)
+
+// allowAll grants every permission: these tests are about the body, not the gate.
+func allowAll(string, model.Resource, model.Action) bool { return true }
+
+// newFilesStore mounts a Store over a fresh local bucket, configured by configure (which may
+// be nil), on a router that grants every permission.
+func newFilesStore(t *testing.T, configure func(*files.Store)) (router.Router, *r2.Bucket) {
+	t.Helper()
+	return mountFiles(t, allowAll, configure)
+}
+
+// mountFiles is newFilesStore with the router's Authorize chosen by the test.
+func mountFiles(t *testing.T, authorize model.Authorizer, configure func(*files.Store)) (router.Router, *r2.Bucket) {
+	t.Helper()
+	b, err := r2.NewLocal(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	s, err := files.New(b, "/api/files/")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if configure != nil {
+		configure(s)
+	}
+	r := edge.NewRouter(edge.Config{Authorize: authorize})
+	s.Mount(r)
+	return r, b
+}
+
+func TestFilesStream_UploadIsPipedAndServedBack(t *testing.T) {
+	r, b := newFilesStore(t, nil)
+	body := append(append([]byte{}, logoPNG...), bytes.Repeat([]byte{0xAB}, 4096)...)
+
+	up := edgetest.Serve(r, edgetest.NewRequest("PUT", "/api/files/").
+		WithUser("u1").
+		WithBody(body).
+		WithHeader("Content-Length", fmt.Convert(len(body)).String()))
+	up.ExpectStatus(t, 201)
+	key := string(up.ResponseBody())
+
+	info, err := b.Head(key)
+	if err != nil || info.Size != int64(len(body)) || info.ContentType != "image/png" {
+		t.Fatalf("stored object = %+v, %v", info, err)
+	}
+
+	get := edgetest.Serve(r, edgetest.NewRequest("GET", "/api/files/"+key))
+	get.ExpectStatus(t, 200)
+	get.ExpectHeader(t, "Content-Type", "image/png")
+	get.ExpectHeader(t, "Content-Length", fmt.Convert(len(body)).String())
+	if !get.Streamed() {
+		t.Error("the object must be piped to the client, not buffered")
+	}
+	if !bytes.Equal(get.ResponseBody(), body) {
+		t.Error("served bytes differ from the upload")
+	}
+}
+
+func TestFilesStream_LowercaseContentLengthIsPiped(t *testing.T) {
+	r, b := newFilesStore(t, nil)
+
+	// The Workers runtime hands header names lowercased.
+	up := edgetest.Serve(r, edgetest.NewRequest("PUT", "/api/files/").
+		WithUser("u1").
+		WithBody(logoPNG).
+		WithHeader("content-length", fmt.Convert(len(logoPNG)).String()))
+	up.ExpectStatus(t, 201)
+	if !up.Piped() {
+		t.Error("an upload with a declared length must be piped, not buffered")
+	}
+	if info, err := b.Head(string(up.ResponseBody())); err != nil || info.Size != int64(len(logoPNG)) {
+		t.Errorf("stored object = %+v, %v", info, err)
+	}
+}
+
+func TestFilesStream_TypeIsJudgedOnTheFirstBytes(t *testing.T) {
+	r, b := newFilesStore(t, nil)
+	svg := []byte(`<svg xmlns="http://www.w3.org/2000/svg"><script>alert(1)</script></svg>`)
+
+	c := edgetest.Serve(r, edgetest.NewRequest("PUT", "/api/files/").
+		WithUser("u1").
+		WithBody(svg).
+		WithHeader("Content-Length", fmt.Convert(len(svg)).String()))
+	c.ExpectStatus(t, 415)
+	if list, _ := b.List(""); len(list) != 0 {
+		t.Errorf("a rejected upload must store nothing, bucket holds %+v", list)
+	}
+}
+
+func TestR2Local_PutStreamRejectsAWrongSizeAndKeepsTheOldObject(t *testing.T) {
+	b, _ := r2.NewLocal(t.TempDir())
+	b.Put("k", []byte("old"), "text/plain")
+
+	head, body, err := r2.Peek(readCloser("new bytes"), 3)
+	if err != nil || string(head) != "new" {
+		t.Fatalf("Peek = %q, %v", head, err)
+	}
+	if _, err := b.PutStream("k", body, 100, r2.PutOptions{}); err == nil {
+		t.Error("a body shorter than declared must fail the write")
+	}
+	if got, _, _ := b.Get("k"); string(got) != "old" {
+		t.Errorf("a failed write replaced the object: %q", got)
+	}
+
+	_, body, _ = r2.Peek(readCloser("new bytes"), 3)
+	if _, err := b.PutStream("k", body, 9, r2.PutOptions{}); err != nil {
+		t.Fatal(err)
+	}
+	if got, _, _ := b.Get("k"); string(got) != "new bytes" {
+		t.Errorf("Peek must not lose the peeked bytes: got %q", got)
+	}
+}
+
+func readCloser(s string) io.ReadCloser { return io.NopCloser(strings.NewReader(s)) }
@@ -0,0 +1,169 @@
+//go:build !wasm
+
+package goflare_test
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/tinywasm/filetype"
+	"github.com/tinywasm/fmt"
+	"github.com/tinywasm/goflare/edge/edgetest"
+	"github.com/tinywasm/goflare/files"
+	"github.com/tinywasm/goflare/r2"
+)
+
+var (
+	jpegType = filetype.Type{Ext: ".jpg", MIME: "image/jpeg"}
+	pngType  = filetype.Type{Ext: ".png", MIME: "image/png"}
+)
+
+// jpegSegment is FF, the marker, and a big-endian length that counts itself.
+func jpegSegment(marker byte, payload string) []byte {
+	n := len(payload) + 2
+	return append([]byte{0xFF, marker, byte(n >> 8), byte(n)}, payload...)
+}
+
+// photoJPEG is a JPEG as a phone writes it: JFIF, EXIF with a position, XMP, an ICC profile,
+// a comment, then the image.
+func photoJPEG() []byte {
+	var b []byte
+	b = append(b, 0xFF, 0xD8)
+	b = append(b, jpegSegment(0xE0, "JFIF\x00\x01\x01")...)
+	b = append(b, jpegSegment(0xE1, "Exif\x00\x00GPS 40.4168N 3.7038W")...)
+	b = append(b, jpegSegment(0xE1, "http://ns.adobe.com/xap/1.0/\x00<x:xmpmeta/>")...)
+	b = append(b, jpegSegment(0xE2, "ICC_PROFILE\x00\x01\x01profile")...)
+	b = append(b, jpegSegment(0xED, "Photoshop 3.0\x008BIM IPTC")...)
+	b = append(b, jpegSegment(0xFE, "serial 1234")...)
+	b = append(b, jpegSegment(0xDA, "\x01\x01\x00")...)
+	b = append(b, "pixels"...)
+	return append(b, 0xFF, 0xD9)
+}
+
+func TestStrip_JPEGKeepsTheImageDropsTheMetadata(t *testing.T) {
+	out, err := files.Strip(jpegType, photoJPEG())
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, gone := range []string{"Exif", "GPS", "xmpmeta", "IPTC", "serial"} {
+		if bytes.Contains(out, []byte(gone)) {
+			t.Errorf("%q survived", gone)
+		}
+	}
+	for _, kept := range []string{"JFIF", "ICC_PROFILE", "pixels"} {
+		if !bytes.Contains(out, []byte(kept)) {
+			t.Errorf("%q was lost", kept)
+		}
+	}
+	if !bytes.HasSuffix(out, []byte{0xFF, 0xD9}) {
+		t.Error("the image must end as it did")
+	}
+
+	if _, err := files.Strip(jpegType, photoJPEG()[:20]); err == nil {
+		t.Error("a truncated JPEG must be refused, not stored as is")
+	}
+}
+
+func pngChunk(typ, data string) []byte {
+	n := len(data)
+	b := []byte{byte(n >> 24), byte(n >> 16), byte(n >> 8), byte(n)}
+	b = append(b, typ+data...)
+	return append(b, 0, 0, 0, 0) // the CRC: copied, never checked
+}
+
+func TestStrip_PNGDropsTextChunks(t *testing.T) {
+	var in []byte
+	in = append(in, "\x89PNG\r\n\x1a\n"...)
+	in = append(in, pngChunk("IHDR", "header")...)
+	in = append(in, pngChunk("tEXt", "Author\x00someone")...)
+	in = append(in, pngChunk("eXIf", "GPS")...)
+	in = append(in, pngChunk("IDAT", "pixels")...)
+	in = append(in, pngChunk("IEND", "")...)
+
+	out, err := files.Strip(pngType, in)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if bytes.Contains(out, []byte("someone")) || bytes.Contains(out, []byte("GPS")) {
+		t.Errorf("metadata survived: %q", out)
+	}
+	if !bytes.Contains(out, []byte("IHDRheader")) || !bytes.Contains(out, []byte("IDATpixels")) ||
+		!bytes.HasSuffix(out, pngChunk("IEND", "")) {
+		t.Errorf("image chunks were lost: %q", out)
+	}
+}
+
+func webpChunk(typ, data string) []byte {
+	n := len(data)
+	b := append([]byte(typ), byte(n), byte(n>>8), byte(n>>16), byte(n>>24))
+	b = append(b, data...)
+	if n%2 == 1 {
+		b = append(b, 0)
+	}
+	return b
+}
+
+func TestStrip_WebPDropsEXIFAndXMP(t *testing.T) {
+	var body []byte
+	body = append(body, "WEBP"...)
+	body = append(body, webpChunk("VP8X", "\x0c\x00\x00\x00\x00\x00\x00\x00\x00\x00")...)
+	body = append(body, webpChunk("VP8 ", "pixels")...)
+	body = append(body, webpChunk("EXIF", "GPS")...)
+	body = append(body, webpChunk("XMP ", "<x:xmpmeta/>")...)
+	n := len(body)
+	in := append([]byte{'R', 'I', 'F', 'F', byte(n), byte(n >> 8), byte(n >> 16), byte(n >> 24)}, body...)
+
+	out, err := files.Strip(filetype.Type{Ext: ".webp", MIME: "image/webp"}, in)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if bytes.Contains(out, []byte("GPS")) || bytes.Contains(out, []byte("xmpmeta")) {
+		t.Errorf("metadata survived: %q", out)
+	}
+	if size := int(out[4]) | int(out[5])<<8; size != len(out)-8 {
+		t.Errorf("RIFF size = %d, want %d", size, len(out)-8)
+	}
+	if flags := out[20]; flags&0x0c != 0 {
+		t.Errorf("VP8X still announces EXIF/XMP: flags %#x", flags)
+	}
+}
+
+// transformStore mounts a Store configured by configure and returns its bucket and a PUT.
+func transformStore(t *testing.T, configure func(*files.Store)) (*r2.Bucket, func(body []byte) *edgetest.Context) {
+	t.Helper()
+	r, b := newFilesStore(t, configure)
+	return b, func(body []byte) *edgetest.Context {
+		return edgetest.Serve(r, edgetest.NewRequest("PUT", "/api/files/").
+			WithUser("u1").
+			WithBody(body).
+			WithHeader("Content-Length", fmt.Convert(len(body)).String()))
+	}
+}
+
+func TestFilesTransform_UploadIsStrippedBeforeItIsStored(t *testing.T) {
+	b, put := transformStore(t, func(s *files.Store) { s.StripMetadata() })
+
+	c := put(photoJPEG())
+	c.ExpectStatus(t, 201)
+	stored, _, err := b.Get(string(c.ResponseBody()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if bytes.Contains(stored, []byte("GPS")) {
+		t.Error("the stored photo still carries its position")
+	}
+}
+
+func TestFilesTransform_HookCanRefuseButNotSmuggle(t *testing.T) {
+	_, put := transformStore(t, func(s *files.Store) {
+		s.Transform(func(_ filetype.Type, data []byte) ([]byte, error) {
+			if bytes.Contains(data, []byte("serial")) {
+				return nil, fmt.Err("photo carries a serial number")
+			}
+			return []byte("%PDF-1.7 not an image"), nil
+		})
+	})
+
+	put(photoJPEG()).ExpectStatus(t, 422)
+	put(logoPNG).ExpectStatus(t, 415) // turned into a PDF, which the Store does not accept
+}
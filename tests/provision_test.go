@@ -0,0 +1,138 @@
+//go:build !wasm
+
+package goflare_test
+
+import (
+	"encoding/json"
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/tinywasm/goflare"
+)
+
+func TestProvision_CreatesMissingAdoptsExisting(t *testing.T) {
+	var created []string
+	server := MockHTTPServer(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		p := r.URL.Path
+		switch {
+		// D1: no database of that name yet → created.
+		case r.Method == http.MethodGet && p == "/accounts/acc/d1/database":
+			if r.URL.Query().Get("name") != "shop" {
+				t.Errorf("D1 looked up by %q", r.URL.Query().Get("name"))
+			}
+			w.Write([]byte(`{"success":true,"result":[]}`))
+		case r.Method == http.MethodPost && p == "/accounts/acc/d1/database":
+			var body map[string]string
+			json.NewDecoder(r.Body).Decode(&body)
+			created = append(created, "d1:"+body["name"])
+			w.Write([]byte(`{"success":true,"result":{"uuid":"d1-uuid"}}`))
+
+		// R2: the bucket exists → adopted.
+		case r.Method == http.MethodGet && p == "/accounts/acc/r2/buckets/shop-files":
+			w.Write([]byte(`{"success":true,"result":{"name":"shop-files"}}`))
+
+		// KV: a namespace with the expected title exists → its ID is adopted.
+		case r.Method == http.MethodGet && p == "/accounts/acc/storage/kv/namespaces":
+			w.Write([]byte(`{"success":true,"result":[{"id":"other","title":"x"},{"id":"kv-id","title":"shop-SESSIONS"}]}`))
+
+		default:
+			if r.Method == http.MethodPost {
+				created = append(created, p)
+			}
+			w.WriteHeader(http.StatusNotFound)
+			w.Write([]byte(`{"success":false,"errors":[{"code":10000,"message":"not found"}]}`))
+		}
+	})
+	defer server.Close()
+
+	cfg := &goflare.Config{
+		ProjectName:     "shop",
+		AccountID:       "acc",
+		D1DatabaseName:  "DB",
+		R2BucketID:      "shop-files",
+		KVNamespaceName: "SESSIONS",
+	}
+	g := goflare.New(cfg)
+	client := &goflare.CfClient{Token: "tok", BaseURL: server.URL, HttpClient: http.DefaultClient}
+
+	res, err := g.Provision(client)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if strings.Join(created, ",") != "d1:shop" {
+		t.Errorf("created = %v, want only the missing D1 database", created)
+	}
+	if len(res) != 3 || !res[0].Created || res[1].Created || res[2].Created {
+		t.Errorf("results = %+v", res)
+	}
+	if cfg.D1DatabaseID != "d1-uuid" || cfg.R2BucketID != "shop-files" || cfg.KVNamespaceID != "kv-id" {
+		t.Errorf("IDs not filled in: D1=%q R2=%q KV=%q", cfg.D1DatabaseID, cfg.R2BucketID, cfg.KVNamespaceID)
+	}
+}
+
+func TestProvision_KVListIsReadPastTheFirstPage(t *testing.T) {
+	var pages []string
+	server := MockHTTPServer(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		if r.Method != http.MethodGet || r.URL.Path != "/accounts/acc/storage/kv/namespaces" {
+			t.Errorf("unexpected API call %s %s", r.Method, r.URL.Path)
+			return
+		}
+		page := r.URL.Query().Get("page")
+		pages = append(pages, page)
+		info := `"result_info":{"page":` + page + `,"per_page":100,"count":1,"total_count":2,"total_pages":2}`
+		if page == "1" {
+			w.Write([]byte(`{"success":true,"result":[{"id":"other","title":"x"}],` + info + `}`))
+		} else {
+			w.Write([]byte(`{"success":true,"result":[{"id":"kv-id","title":"shop-SESSIONS"}],` + info + `}`))
+		}
+	})
+	defer server.Close()
+
+	cfg := &goflare.Config{ProjectName: "shop", AccountID: "acc", KVNamespaceName: "SESSIONS"}
+	client := &goflare.CfClient{Token: "tok", BaseURL: server.URL, HttpClient: http.DefaultClient}
+	res, err := goflare.New(cfg).Provision(client)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if strings.Join(pages, ",") != "1,2" || len(res) != 1 || res[0].Created || cfg.KVNamespaceID != "kv-id" {
+		t.Errorf("pages = %v, results = %+v: the namespace on page 2 must be adopted", pages, res)
+	}
+}
+
+func TestProvision_MissingConfiguredIDIsAnError(t *testing.T) {
+	server := MockHTTPServer(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		if r.Method != http.MethodGet {
+			t.Errorf("unexpected API call %s %s", r.Method, r.URL.Path)
+		}
+		w.WriteHeader(http.StatusNotFound)
+		w.Write([]byte(`{"success":false,"errors":[{"code":7404,"message":"not found"}]}`))
+	})
+	defer server.Close()
+
+	cfg := &goflare.Config{ProjectName: "shop", AccountID: "acc", D1DatabaseID: "gone-id"}
+	client := &goflare.CfClient{Token: "tok", BaseURL: server.URL, HttpClient: http.DefaultClient}
+	_, err := goflare.New(cfg).Provision(client)
+	if err == nil || !strings.Contains(err.Error(), "gone-id") {
+		t.Errorf("err = %v, want one naming the missing ID", err)
+	}
+	if cfg.D1DatabaseID != "gone-id" {
+		t.Errorf("D1DatabaseID = %q: a missing ID must not be replaced", cfg.D1DatabaseID)
+	}
+}
+
+func TestProvision_NothingConfiguredNothingTouched(t *testing.T) {
+	server := MockHTTPServer(func(w http.ResponseWriter, r *http.Request) {
+		t.Errorf("unexpected API call %s %s", r.Method, r.URL.Path)
+	})
+	defer server.Close()
+
+	g := goflare.New(&goflare.Config{ProjectName: "shop", AccountID: "acc"})
+	client := &goflare.CfClient{Token: "tok", BaseURL: server.URL, HttpClient: http.DefaultClient}
+	if res, err := g.Provision(client); err != nil || len(res) != 0 {
+		t.Errorf("Provision = %+v, %v", res, err)
+	}
+}
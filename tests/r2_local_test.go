@@ -0,0 +1,251 @@
+//go:build !wasm
+
+package goflare_test
+
+import (
+	"bytes"
+	"crypto/md5"
+	"encoding/hex"
+	"errors"
+	"testing"
+
+	"github.com/tinywasm/goflare/cloudflare"
+	"github.com/tinywasm/goflare/edge"
+	"github.com/tinywasm/goflare/edge/edgetest"
+	"github.com/tinywasm/goflare/files"
+	"github.com/tinywasm/goflare/r2"
+)
+
+func TestR2Local_BinaryRoundtripKeepsContentType(t *testing.T) {
+	b, err := r2.NewLocal(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	original := []byte{0xFF, 0xFE, 0x00, 0x80, 0x21, 0x42}
+	if err := b.Put("dir/test.bin", original, "application/octet-stream"); err != nil {
+		t.Fatalf("Put: %v", err)
+	}
+	got, ct, err := b.Get("dir/test.bin")
+	if err != nil {
+		t.Fatalf("Get: %v", err)
+	}
+	if !bytes.Equal(got, original) {
+		t.Errorf("roundtrip changed the bytes:\nwant %v\ngot  %v", original, got)
+	}
+	if ct != "application/octet-stream" {
+		t.Errorf("content type = %q", ct)
+	}
+}
+
+func TestR2Local_MissingKeyDeleteAndList(t *testing.T) {
+	b, _ := r2.NewLocal(t.TempDir())
+
+	if _, _, err := b.Get("nope"); err == nil {
+		t.Error("Get of a missing key must fail")
+	}
+
+	b.Put("img/a.png", []byte("a"), "image/png")
+	b.Put("img/b.png", []byte("bb"), "image/png")
+	b.Put("doc/c.pdf", []byte("ccc"), "application/pdf")
+
+	list, err := b.List("img/")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(list) != 2 || list[0].Key != "img/a.png" || list[1].Key != "img/b.png" || list[1].Size != 2 {
+		t.Errorf("List(img/) = %+v", list)
+	}
+
+	if err := b.Delete("img/a.png"); err != nil {
+		t.Fatal(err)
+	}
+	if err := b.Delete("img/a.png"); err != nil {
+		t.Errorf("deleting a missing key must not fail, got %v", err)
+	}
+	if list, _ := b.List(""); len(list) != 2 {
+		t.Errorf("after delete, List = %+v, want 2 objects", list)
+	}
+}
+
+func TestR2Local_KeyCannotEscapeTheBucket(t *testing.T) {
+	b, _ := r2.NewLocal(t.TempDir())
+
+	for _, key := range []string{"..", "../x", "a/../../b", ".hidden"} {
+		if err := b.Put(key, []byte("x"), ""); err != nil {
+			t.Fatalf("Put(%q): %v", key, err)
+		}
+		if got, _, err := b.Get(key); err != nil || string(got) != "x" {
+			t.Errorf("Get(%q) = %q, %v", key, got, err)
+		}
+	}
+	if list, _ := b.List(""); len(list) != 4 {
+		t.Errorf("List = %+v, want the 4 keys, each its own object", list)
+	}
+}
+
+func TestR2Local_NewEdgeResolvesUnderStateDir(t *testing.T) {
+	cloudflare.SetStateDir(t.TempDir())
+	t.Cleanup(func() { cloudflare.SetStateDir("") })
+
+	a, _ := r2.NewEdge("FILES")
+	a.Put("k", []byte("v"), "text/plain")
+
+	again, _ := r2.NewEdge("FILES")
+	if got, _, err := again.Get("k"); err != nil || string(got) != "v" {
+		t.Errorf("a binding must reopen the same data: got %q, %v", got, err)
+	}
+	other, _ := r2.NewEdge("OTHER")
+	if _, _, err := other.Get("k"); err == nil {
+		t.Error("another binding name must be another bucket")
+	}
+}
+
+func TestR2Local_ServesThroughFilesStore(t *testing.T) {
+	b, _ := r2.NewLocal(t.TempDir())
+	b.Put("logo.png", logoPNG, "image/png")
+
+	s, err := files.New(b, "/api/files/")
+	if err != nil {
+		t.Fatal(err)
+	}
+	r := edge.NewRouter(edge.Config{})
+	s.Mount(r)
+
+	c := edgetest.Serve(r, edgetest.NewRequest("GET", "/api/files/logo.png"))
+	c.ExpectStatus(t, 200)
+	c.ExpectHeader(t, "Content-Type", "image/png")
+	if !bytes.Equal(c.ResponseBody(), logoPNG) {
+		t.Errorf("served bytes differ")
+	}
+}
+
+func TestR2Local_HeadAndMetadata(t *testing.T) {
+	b, _ := r2.NewLocal(t.TempDir())
+
+	put, err := b.PutWith("report.pdf", []byte("%PDF"), r2.PutOptions{
+		ContentType:        "application/pdf",
+		CacheControl:       "max-age=60",
+		ContentDisposition: `attachment; filename="report.pdf"`,
+		CustomMetadata:     map[string]string{"owner": "u1"},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if sum := md5.Sum([]byte("%PDF")); put.ETag != hex.EncodeToString(sum[:]) {
+		t.Errorf("ETag = %q, want the hex MD5 of the bytes", put.ETag)
+	}
+
+	head, err := b.Head("report.pdf")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if head.ETag != put.ETag || head.Size != 4 || head.Uploaded == 0 ||
+		head.CacheControl != "max-age=60" || head.ContentDisposition == "" ||
+		head.CustomMetadata["owner"] != "u1" {
+		t.Errorf("Head = %+v", head)
+	}
+
+	if _, err := b.Head("missing"); !errors.Is(err, r2.ErrNotFound) {
+		t.Errorf("Head of a missing key: %v, want ErrNotFound", err)
+	}
+	if _, _, err := b.Get("missing"); !errors.Is(err, r2.ErrNotFound) {
+		t.Errorf("Get of a missing key: %v, want ErrNotFound", err)
+	}
+}
+
+func TestR2Local_ConditionalPutAndGet(t *testing.T) {
+	b, _ := r2.NewLocal(t.TempDir())
+	ifAbsent := r2.PutOptions{OnlyIf: r2.Conditions{EtagDoesNotMatch: "*"}}
+
+	first, err := b.PutWith("k", []byte("v1"), ifAbsent)
+	if err != nil {
+		t.Fatalf("create-if-absent on a free key: %v", err)
+	}
+	if _, err := b.PutWith("k", []byte("v2"), ifAbsent); !errors.Is(err, r2.ErrPreconditionFailed) {
+		t.Errorf("create-if-absent on a taken key: %v, want ErrPreconditionFailed", err)
+	}
+
+	stale := r2.PutOptions{OnlyIf: r2.Conditions{EtagMatches: "not-the-etag"}}
+	if _, err := b.PutWith("k", []byte("v2"), stale); !errors.Is(err, r2.ErrPreconditionFailed) {
+		t.Errorf("put on a stale ETag: %v, want ErrPreconditionFailed", err)
+	}
+	fresh := r2.PutOptions{OnlyIf: r2.Conditions{EtagMatches: `"` + first.ETag + `"`}}
+	second, err := b.PutWith("k", []byte("v2"), fresh)
+	if err != nil {
+		t.Fatalf("put on the current ETag: %v", err)
+	}
+
+	_, info, err := b.GetIf("k", r2.Conditions{EtagDoesNotMatch: second.ETag})
+	if !errors.Is(err, r2.ErrPreconditionFailed) || info.ETag != second.ETag {
+		t.Errorf("GetIf with the current ETag: %v, %+v — want ErrPreconditionFailed and the metadata", err, info)
+	}
+	data, _, err := b.GetIf("k", r2.Conditions{EtagDoesNotMatch: first.ETag})
+	if err != nil || string(data) != "v2" {
+		t.Errorf("GetIf with an old ETag = %q, %v", data, err)
+	}
+}
+
+func TestR2Local_ChecksumMismatchWritesNothing(t *testing.T) {
+	b, _ := r2.NewLocal(t.TempDir())
+
+	_, err := b.PutWith("k", []byte("abc"), r2.PutOptions{MD5: "00000000000000000000000000000000"})
+	if !errors.Is(err, r2.ErrChecksumMismatch) {
+		t.Errorf("wrong MD5: %v, want ErrChecksumMismatch", err)
+	}
+	if _, err := b.Head("k"); !errors.Is(err, r2.ErrNotFound) {
+		t.Errorf("a rejected write must leave nothing behind, Head = %v", err)
+	}
+	_, err = b.PutWith("k", []byte("abc"), r2.PutOptions{
+		SHA256: "ba7816bf8f01cfea414140de5dae2223b00361a396177a9cb410ff61f20015ad",
+	})
+	if err != nil {
+		t.Errorf("right SHA-256: %v", err)
+	}
+}
+
+func TestR2Local_ListFollowsCursorAndGroupsByDelimiter(t *testing.T) {
+	b, _ := r2.NewLocal(t.TempDir())
+	for _, k := range []string{"a/1", "a/2", "b/x/1", "b/x/2", "b/y", "c", "d"} {
+		b.Put(k, []byte(k), "text/plain")
+	}
+
+	page, err := b.ListPage(r2.ListOptions{Limit: 3})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(page.Objects) != 3 || !page.Truncated || page.Cursor == "" {
+		t.Fatalf("first page = %+v, want 3 objects and a cursor", page)
+	}
+	page, _ = b.ListPage(r2.ListOptions{Limit: 3, Cursor: page.Cursor})
+	if len(page.Objects) != 3 || page.Objects[0].Key != "b/x/2" {
+		t.Errorf("second page = %+v, want to resume at b/x/2", page)
+	}
+
+	var seen []string
+	err = b.Each(r2.ListOptions{Limit: 2}, func(o r2.ObjectInfo) error {
+		seen = append(seen, o.Key)
+		return nil
+	})
+	if err != nil || len(seen) != 7 {
+		t.Errorf("Each over pages of 2 saw %v, %v — want all 7 keys", seen, err)
+	}
+
+	dir, _ := b.ListPage(r2.ListOptions{Prefix: "b/", Delimiter: "/"})
+	if len(dir.Objects) != 1 || dir.Objects[0].Key != "b/y" ||
+		len(dir.DelimitedPrefixes) != 1 || dir.DelimitedPrefixes[0] != "b/x/" {
+		t.Errorf("delimited listing of b/ = %+v", dir)
+	}
+
+	top, _ := b.ListPage(r2.ListOptions{Delimiter: "/", Limit: 2})
+	if !top.Truncated || len(top.DelimitedPrefixes) != 2 {
+		t.Fatalf("first delimited page = %+v, want a/ and b/", top)
+	}
+	rest, _ := b.ListPage(r2.ListOptions{Delimiter: "/", Cursor: top.Cursor})
+	if len(rest.Objects) != 2 || len(rest.DelimitedPrefixes) != 0 || rest.Truncated {
+		t.Errorf("next delimited page = %+v, want c and d only", rest)
+	}
+}
+
+// logoPNG is the start of a PNG: enough bytes to prove a binary body survives the trip.
+var logoPNG = []byte{0x89, 'P', 'N', 'G', 0x0D, 0x0A, 0x1A, 0x0A, 0x00, 0xFF}
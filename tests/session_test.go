@@ -0,0 +1,126 @@
+//go:build !wasm
+
+package goflare_test
+
+import (
+	"testing"
+
+	"github.com/tinywasm/goflare/edge"
+	"github.com/tinywasm/goflare/edge/edgetest"
+	"github.com/tinywasm/goflare/internal/clock"
+	"github.com/tinywasm/goflare/session"
+	"github.com/tinywasm/router"
+)
+
+// sessionRouter mounts a login that issues a session for the user named in the body, a
+// logout, and a route that answers who the caller is.
+func sessionRouter(t *testing.T, sess *session.Sessions) router.Router {
+	t.Helper()
+	r := edge.NewRouter(edge.Config{Authn: sess.Authn(), Authorize: allowAll})
+	r.Post("/login", func(ctx router.Context) {
+		if err := sess.Issue(ctx, string(ctx.Body())); err != nil {
+			t.Fatal(err)
+		}
+	}).Public()
+	r.Post("/logout", func(ctx router.Context) { sess.Clear(ctx) }).Public()
+	r.Get("/me", func(ctx router.Context) { ctx.Write([]byte(ctx.UserID())) }).Authenticated()
+	return r
+}
+
+// login returns the session cookie issued for user.
+func login(t *testing.T, r router.Router, user string) router.Cookie {
+	t.Helper()
+	c := edgetest.Serve(r, edgetest.NewRequest("POST", "/login").WithBody([]byte(user)))
+	c.ExpectStatus(t, 200)
+	cookies := c.SetCookies()
+	if len(cookies) != 1 {
+		t.Fatalf("login set %d cookies, want 1", len(cookies))
+	}
+	return cookies[0]
+}
+
+func me(r router.Router, cookie router.Cookie) *edgetest.Context {
+	return edgetest.Serve(r, edgetest.NewRequest("GET", "/me").WithCookie(cookie.Name, cookie.Value))
+}
+
+func TestSession_IssuedCookieAuthenticates(t *testing.T) {
+	t.Setenv("SESSION_SECRET", "current")
+	sess, err := session.New("SESSION_SECRET")
+	if err != nil {
+		t.Fatal(err)
+	}
+	r := sessionRouter(t, sess)
+
+	c := login(t, r, "user; 1")
+	if c.Name != session.DefaultName || c.Path != "/" || !c.Secure || !c.HttpOnly ||
+		c.SameSite != router.SameSiteLax || c.MaxAge != session.DefaultTTL {
+		t.Errorf("cookie attributes = %+v", c)
+	}
+	got := me(r, c)
+	got.ExpectStatus(t, 200)
+	if string(got.ResponseBody()) != "user; 1" {
+		t.Errorf("UserID = %q", got.ResponseBody())
+	}
+
+	edgetest.Serve(r, edgetest.NewRequest("GET", "/me")).ExpectStatus(t, 403)
+	tampered := c
+	tampered.Value = "7532" + c.Value[4:] // another user ID, the same signature
+	me(r, tampered).ExpectStatus(t, 403)
+
+	out := edgetest.Serve(r, edgetest.NewRequest("POST", "/logout"))
+	if cookies := out.SetCookies(); len(cookies) != 1 || cookies[0].MaxAge >= 0 || cookies[0].Value != "" {
+		t.Errorf("logout must delete the cookie, set %+v", cookies)
+	}
+}
+
+func TestSession_ExpiredCookieIsAnonymous(t *testing.T) {
+	t.Setenv("SESSION_SECRET", "current")
+	sess, _ := session.New("SESSION_SECRET")
+	r := sessionRouter(t, sess.TTL(60))
+	c := login(t, r, "u1")
+
+	me(r, c).ExpectStatus(t, 200)
+	clock.Advance(61)
+	t.Cleanup(func() { clock.Advance(-61) })
+	me(r, c).ExpectStatus(t, 403)
+}
+
+func TestSession_NonPositiveTTLIsRefused(t *testing.T) {
+	t.Setenv("SESSION_SECRET", "current")
+	sess, _ := session.New("SESSION_SECRET")
+	ctx := edgetest.NewRequest("POST", "/login")
+	if err := sess.TTL(0).Issue(ctx, "u1"); err == nil {
+		t.Error("Issue started a session with a TTL of 0")
+	}
+}
+
+func TestSession_RotationKeepsOldSessionsAndResignsThem(t *testing.T) {
+	t.Setenv("SESSION_SECRET", "old")
+	sess, _ := session.New("SESSION_SECRET")
+	old := login(t, sessionRouter(t, sess), "u1")
+
+	t.Setenv("SESSION_SECRET", "new")
+	t.Setenv("SESSION_SECRET_PREV", "old")
+	rotated, _ := session.New("SESSION_SECRET", "SESSION_SECRET_PREV")
+	r := sessionRouter(t, rotated)
+
+	c := me(r, old)
+	c.ExpectStatus(t, 200)
+	renewed := c.SetCookies()
+	if len(renewed) != 1 || renewed[0].Value == old.Value {
+		t.Fatalf("a session under the previous secret must be re-signed, set %+v", renewed)
+	}
+	t.Setenv("SESSION_SECRET_PREV", "")
+	me(r, renewed[0]).ExpectStatus(t, 200)
+	me(r, old).ExpectStatus(t, 403) // the rotation is over
+}
+
+func TestSession_MissingSecretFailsClosed(t *testing.T) {
+	t.Setenv("SESSION_SECRET", "current")
+	sess, _ := session.New("SESSION_SECRET")
+	r := sessionRouter(t, sess)
+	c := login(t, r, "u1")
+
+	t.Setenv("SESSION_SECRET", "")
+	me(r, c).ExpectStatus(t, 500)
+}
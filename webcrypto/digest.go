@@ -0,0 +1,23 @@
+//go:build wasm
+
+package webcrypto
+
+import (
+	"syscall/js"
+
+	"github.com/tinywasm/await"
+	"github.com/tinywasm/fmt"
+)
+
+// SHA256 returns the SHA-256 digest of data, computed by crypto.subtle.
+func SHA256(data []byte) ([]byte, error) {
+	subtle := js.Global().Get("crypto").Get("subtle")
+	sum, err := await.Promise(subtle.Call("digest", "SHA-256", bytesToJS(data)))
+	if err != nil {
+		return nil, fmt.Errf("webcrypto: digest: %s", err.Error())
+	}
+	ua := js.Global().Get("Uint8Array").New(sum)
+	out := make([]byte, ua.Get("byteLength").Int())
+	js.CopyBytesToGo(out, ua)
+	return out, nil
+}
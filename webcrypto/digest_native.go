@@ -0,0 +1,11 @@
+//go:build !wasm
+
+package webcrypto
+
+import "crypto/sha256"
+
+// SHA256 returns the SHA-256 digest of data.
+func SHA256(data []byte) ([]byte, error) {
+	sum := sha256.Sum256(data)
+	return sum[:], nil
+}
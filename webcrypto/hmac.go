@@ -0,0 +1,42 @@
+//go:build wasm
+
+package webcrypto
+
+import (
+	"syscall/js"
+
+	"github.com/tinywasm/await"
+	"github.com/tinywasm/fmt"
+)
+
+// HMAC returns the HMAC-SHA256 of msg under key, computed by crypto.subtle.
+func HMAC(key, msg []byte) ([]byte, error) {
+	if len(key) == 0 {
+		return nil, ErrNoKey
+	}
+	subtle := js.Global().Get("crypto").Get("subtle")
+	algo := js.Global().Get("Object").New()
+	algo.Set("name", "HMAC")
+	algo.Set("hash", "SHA-256")
+	usages := js.Global().Get("Array").New()
+	usages.Call("push", "sign")
+
+	k, err := await.Promise(subtle.Call("importKey", "raw", bytesToJS(key), algo, false, usages))
+	if err != nil {
+		return nil, fmt.Errf("webcrypto: import key: %s", err.Error())
+	}
+	sig, err := await.Promise(subtle.Call("sign", "HMAC", k, bytesToJS(msg)))
+	if err != nil {
+		return nil, fmt.Errf("webcrypto: sign: %s", err.Error())
+	}
+	ua := js.Global().Get("Uint8Array").New(sig)
+	out := make([]byte, ua.Get("byteLength").Int())
+	js.CopyBytesToGo(out, ua)
+	return out, nil
+}
+
+func bytesToJS(b []byte) js.Value {
+	ua := js.Global().Get("Uint8Array").New(len(b))
+	js.CopyBytesToJS(ua, b)
+	return ua
+}
@@ -0,0 +1,18 @@
+//go:build !wasm
+
+package webcrypto
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+)
+
+// HMAC returns the HMAC-SHA256 of msg under key.
+func HMAC(key, msg []byte) ([]byte, error) {
+	if len(key) == 0 {
+		return nil, ErrNoKey
+	}
+	m := hmac.New(sha256.New, key)
+	m.Write(msg)
+	return m.Sum(nil), nil
+}
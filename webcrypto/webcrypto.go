@@ -0,0 +1,69 @@
+// Package webcrypto is the little cryptography goflare needs, on both targets: HMAC-SHA256
+// to sign what the server hands out and later takes back (signed URLs, session cookies), and
+// SHA-256 to name content by its bytes.
+//
+// At the edge it is the Workers runtime's SubtleCrypto, so the wasm binary carries no hash
+// implementation of its own; natively it is the standard library. Both produce the same bytes,
+// so a URL signed by the devserver verifies at the edge with the same secret, and back.
+package webcrypto
+
+import "github.com/tinywasm/fmt"
+
+const errPrefix = "webcrypto: "
+
+// ErrNoKey: an HMAC was asked for with an empty key. A signature under no secret is one
+// anybody can forge, so it is refused rather than computed.
+var ErrNoKey = fmt.Err(errPrefix + "empty key")
+
+// Equal reports whether a and b are the same bytes, in time that depends only on their
+// length: comparing a signature with == tells an attacker how many leading bytes were right.
+func Equal(a, b []byte) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	var diff byte
+	for i := range a {
+		diff |= a[i] ^ b[i]
+	}
+	return diff == 0
+}
+
+const hexDigits = "0123456789abcdef"
+
+// Hex is the lowercase hex encoding of b, without importing encoding/hex into the wasm build.
+func Hex(b []byte) string {
+	out := make([]byte, len(b)*2)
+	for i, c := range b {
+		out[i*2] = hexDigits[c>>4]
+		out[i*2+1] = hexDigits[c&0x0f]
+	}
+	return string(out)
+}
+
+// Unhex decodes what Hex produced; ok is false for anything else — odd length, a non-hex
+// character, uppercase included.
+func Unhex(s string) (b []byte, ok bool) {
+	if len(s)%2 != 0 {
+		return nil, false
+	}
+	b = make([]byte, len(s)/2)
+	for i := range b {
+		hi, ok1 := nibble(s[i*2])
+		lo, ok2 := nibble(s[i*2+1])
+		if !ok1 || !ok2 {
+			return nil, false
+		}
+		b[i] = hi<<4 | lo
+	}
+	return b, true
+}
+
+func nibble(c byte) (byte, bool) {
+	switch {
+	case '0' <= c && c <= '9':
+		return c - '0', true
+	case 'a' <= c && c <= 'f':
+		return c - 'a' + 10, true
+	}
+	return 0, false
+}
@@ -12,12 +12,18 @@ import (
 type Request struct {
 	Method  string
 	URL     string
-	Headers map[string]string
+	Headers map[string]string // by lowercase name, as the Fetch API's Headers yields them; read with Header
 	jsReq   js.Value
 	body    []byte
 	hasBody bool
 }
 
+// Header returns the request header name, matched case-insensitively as HTTP requires:
+// Header("Content-Type") reads what the runtime holds as "content-type".
+func (r *Request) Header(name string) string {
+	return r.Headers[fmt.ToLower(name)]
+}
+
 // Body returns the raw request body bytes.
 // It reads the body lazily on the first call.
 func (r *Request) Body() []byte {
@@ -67,6 +73,13 @@ func (r *Request) Body() []byte {
 	return r.body
 }
 
+// BodyStream returns the body as the ReadableStream it arrived as (null when there is none),
+// for piping it onward without copying it into wasm memory. It is the body itself: once it
+// is consumed, Body has nothing left to read, and the other way round.
+func (r *Request) BodyStream() js.Value {
+	return r.jsReq.Get("body")
+}
+
 // newRequest reads a JS Fetch Request into a Go Request.
 func newRequest(jsReq js.Value) (*Request, error) {
 	r := &Request{
@@ -86,7 +99,7 @@ func newRequest(jsReq js.Value) (*Request, error) {
 				break
 			}
 			val := next.Get("value")
-			r.Headers[val.Index(0).String()] = val.Index(1).String()
+			r.Headers[fmt.ToLower(val.Index(0).String())] = val.Index(1).String()
 		}
 	}
 
@@ -11,6 +11,7 @@ type Response struct {
 	status  int
 	headers map[string]string
 	buf     []byte
+	stream  js.Value // set by WriteStream; replaces buf
 }
 
 func newResponse() *Response {
@@ -39,6 +40,13 @@ func (w *Response) WriteString(s string) (int, error) {
 	return len(s), nil
 }
 
+// WriteStream makes body — a ReadableStream — the response body, piped to the client as it
+// is read instead of copied through wasm memory. It replaces anything written with Write.
+func (w *Response) WriteStream(body js.Value) {
+	w.stream = body
+	w.buf = nil
+}
+
 // build converts the Go response to a JS Response object.
 func (w *Response) build() js.Value {
 	h := js.Global().Get("Headers").New()
@@ -50,6 +58,10 @@ func (w *Response) build() js.Value {
 	init.Set("status", w.status)
 	init.Set("headers", h)
 
+	if w.stream.Truthy() {
+		return js.Global().Get("Response").New(w.stream, init)
+	}
+
 	// Binary-safe body transfer: copy bytes to a Uint8Array
 	// rather than passing a string (which corrupts non-UTF8 data).
 	b := w.buf